@@ -0,0 +1,258 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// An ApplyFunc is called by Apply with a cursor positioned on a node. Its
+// result decides how the traversal goes on; see Apply.
+type ApplyFunc func(*Cursor) bool
+
+// Apply traverses the tree rooted at root in the same order as Walk and
+// returns the root, which pre may have replaced. Unlike Walk, it also
+// visits missing children, as a nil node.
+//
+// pre, if not nil, is called on a node before its children. Returning
+// false skips the children and the call of post for that node. post, if
+// not nil, is called after the children, and returning false stops the
+// whole traversal.
+//
+// pre can edit the tree through the cursor. After a Replace, the children
+// of the new node are traversed instead of the old ones. Nodes added by
+// InsertBefore or InsertAfter are not traversed at all.
+func Apply(root Node, pre, post ApplyFunc) Node {
+	top := &struct{ Node }{root}
+	r := &rewriter{pre: pre, post: post}
+	r.visit(&Cursor{field: reflect.ValueOf(&top.Node).Elem()}, root)
+	return top.Node
+}
+
+// A Cursor is the position of a node in the tree during Apply: the node
+// itself, its parent, and the field of the parent that holds it. The root
+// has no parent.
+type Cursor struct {
+	parent Node
+	name   string
+	node   Node
+	field  reflect.Value // the field holding node, or the slice if list is set
+	list   *listPos
+}
+
+// listPos tracks Apply's progress through a slice of nodes, which the
+// cursor may edit as it goes.
+type listPos struct {
+	index int // of the current node
+	next  int // index of the node to visit after it
+}
+
+// Node returns the current node.
+func (c *Cursor) Node() Node { return c.node }
+
+// Parent returns the node whose field holds the current node, or nil at
+// the root.
+func (c *Cursor) Parent() Node { return c.parent }
+
+// Name returns the name of the parent's field that holds the current
+// node, such as "Left" or "Statements".
+func (c *Cursor) Name() string { return c.name }
+
+// Index returns the index of the current node in its parent's field if
+// that field is a slice, and -1 otherwise.
+func (c *Cursor) Index() int {
+	if c.list == nil {
+		return -1
+	}
+	return c.list.index
+}
+
+// Replace puts n where the current node is. A nil n clears the field.
+func (c *Cursor) Replace(n Node) {
+	slot := c.field
+	if c.list != nil {
+		slot = slot.Index(c.list.index)
+	}
+	slot.Set(valueFor(n, slot.Type()))
+	c.node = n
+}
+
+// Delete removes the current node from the slice that holds it. It panics
+// if the node is not in a slice.
+func (c *Cursor) Delete() {
+	s, i := c.slice("Delete")
+	last := s.Len() - 1
+	reflect.Copy(s.Slice(i, last), s.Slice(i+1, last+1))
+	s.Index(last).Set(reflect.Zero(s.Type().Elem()))
+	s.SetLen(last)
+	c.list.next = i
+}
+
+// InsertBefore adds n to the slice that holds the current node, just
+// before it. It panics if the node is not in a slice.
+func (c *Cursor) InsertBefore(n Node) {
+	s, i := c.slice("InsertBefore")
+	insertAt(s, i, n)
+	c.list.index++
+	c.list.next++
+}
+
+// InsertAfter adds n to the slice that holds the current node, just after
+// it. It panics if the node is not in a slice.
+func (c *Cursor) InsertAfter(n Node) {
+	s, i := c.slice("InsertAfter")
+	insertAt(s, i+1, n)
+	c.list.next++
+}
+
+func (c *Cursor) slice(method string) (reflect.Value, int) {
+	if c.list == nil {
+		panic(fmt.Sprintf("ast.Cursor.%s: %s is not in a slice", method, c.name))
+	}
+	return c.field, c.list.index
+}
+
+// insertAt inserts n into the slice s at index i.
+func insertAt(s reflect.Value, i int, n Node) {
+	s.Set(reflect.Append(s, reflect.Zero(s.Type().Elem())))
+	reflect.Copy(s.Slice(i+1, s.Len()), s.Slice(i, s.Len()))
+	s.Index(i).Set(valueFor(n, s.Type().Elem()))
+}
+
+// valueFor returns n as a value that can be stored in a field of type t.
+func valueFor(n Node, t reflect.Type) reflect.Value {
+	if n == nil {
+		return reflect.Zero(t)
+	}
+	return reflect.ValueOf(n)
+}
+
+// nodeIn returns the node stored in v, with nil pointers as a plain nil.
+func nodeIn(v reflect.Value) Node {
+	n, _ := v.Interface().(Node)
+	if isNil(n) {
+		return nil
+	}
+	return n
+}
+
+type rewriter struct {
+	pre, post ApplyFunc
+	stopped   bool
+}
+
+// child visits the node in the field of parent that ptr points to.
+func (r *rewriter) child(parent Node, name string, ptr interface{}) {
+	field := reflect.ValueOf(ptr).Elem()
+	r.visit(&Cursor{parent: parent, name: name, field: field}, nodeIn(field))
+}
+
+// children visits each node in the slice field of parent that ptr points
+// to. The length is read again after every node since pre may change it.
+func (r *rewriter) children(parent Node, name string, ptr interface{}) {
+	s := reflect.ValueOf(ptr).Elem()
+	pos := &listPos{}
+	for pos.index < s.Len() && !r.stopped {
+		pos.next = pos.index + 1
+		r.visit(&Cursor{parent: parent, name: name, field: s, list: pos}, nodeIn(s.Index(pos.index)))
+		pos.index = pos.next
+	}
+}
+
+func (r *rewriter) visit(c *Cursor, n Node) {
+	if r.stopped {
+		return
+	}
+	c.node = n
+	if r.pre != nil && !r.pre(c) {
+		return
+	}
+
+	switch n := c.node.(type) {
+	case nil:
+		// nothing below
+
+	case *Program:
+		r.children(n, "Statements", &n.Statements)
+
+	// Statements
+	case *LetStatement:
+		r.child(n, "Name", &n.Name)
+		r.child(n, "Value", &n.Value)
+	case *VarDecl:
+		r.child(n, "Type", &n.Type)
+		r.child(n, "Name", &n.Name)
+		r.child(n, "Value", &n.Value)
+	case *ReturnStatement:
+		r.child(n, "ReturnValue", &n.ReturnValue)
+	case *ExpressionStatement:
+		r.child(n, "Expression", &n.Expression)
+	case *BlockStatement:
+		r.children(n, "Statements", &n.Statements)
+
+	// Expressions
+	case *Identifier, *IntegerLiteral, *FloatLiteral, *Boolean, *Null, *StringLiteral:
+		// leaves
+	case *PrefixExpression:
+		r.child(n, "Right", &n.Right)
+	case *InfixExpression:
+		r.child(n, "Left", &n.Left)
+		r.child(n, "Right", &n.Right)
+	case *PostfixExpression:
+		r.child(n, "Left", &n.Left)
+	case *InterpolatedString:
+		r.children(n, "Parts", &n.Parts)
+	case *IfExpression:
+		r.child(n, "Condition", &n.Condition)
+		r.child(n, "Consequence", &n.Consequence)
+		r.child(n, "Alternative", &n.Alternative)
+	case *Parameter:
+		r.child(n, "Type", &n.Type)
+		r.child(n, "Name", &n.Name)
+	case *FunctionLiteral:
+		r.children(n, "Parameters", &n.Parameters)
+		r.child(n, "ReturnType", &n.ReturnType)
+		r.child(n, "Body", &n.Body)
+	case *CallExpression:
+		r.child(n, "Function", &n.Function)
+		r.children(n, "Arguments", &n.Arguments)
+	case *AssignExpression:
+		r.child(n, "Target", &n.Target)
+		r.child(n, "Value", &n.Value)
+	case *ArrayLiteral:
+		r.children(n, "Elements", &n.Elements)
+	case *HashLiteral:
+		r.children(n, "Pairs", &n.Pairs)
+	case *HashPair:
+		r.child(n, "Key", &n.Key)
+		r.child(n, "Value", &n.Value)
+	case *IndexExpression:
+		r.child(n, "Left", &n.Left)
+		r.child(n, "Index", &n.Index)
+	case *SliceExpression:
+		r.child(n, "Left", &n.Left)
+		r.child(n, "Low", &n.Low)
+		r.child(n, "High", &n.High)
+
+	// Types
+	case *NamedType:
+		// leaf
+	case *GenericType:
+		r.child(n, "Base", &n.Base)
+		r.children(n, "Args", &n.Args)
+	case *NullableType:
+		r.child(n, "Elem", &n.Elem)
+	case *ErrorUnionType:
+		r.child(n, "Error", &n.Error)
+		r.child(n, "Value", &n.Value)
+	case *FunctionType:
+		r.children(n, "Params", &n.Params)
+		r.child(n, "Result", &n.Result)
+
+	default:
+		panic(fmt.Sprintf("ast.Apply: unexpected node type %T", n))
+	}
+
+	if !r.stopped && r.post != nil && !r.post(c) {
+		r.stopped = true
+	}
+}
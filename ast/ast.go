@@ -3,11 +3,15 @@ package ast
 import (
 	"bytes"
 	"chimp/token"
+	"reflect"
+	"strings"
 )
 
 type Node interface {
 	TokenLiteral() string
 	String() string
+	Pos() token.Position // position of the first character of the node
+	End() token.Position // position immediately after the node
 }
 
 type Statement interface {
@@ -22,24 +26,51 @@ type Expression interface {
 
 type Program struct {
 	Statements []Statement
+	Comments   []*Comment // in source order
 }
 
 func (p *Program) String() string {
 	var out bytes.Buffer
-	for _, s := range p.Statements {
-		out.WriteString(s.String())
-	}
+	writeStatements(&out, p.Statements)
 
 	return out.String()
 }
+
+// writeStatements prints a list of statements separated by spaces. An
+// expression statement prints without its semicolon unless another
+// statement follows, where leaving it out would run the two together.
+func writeStatements(out *bytes.Buffer, stmts []Statement) {
+	for i, s := range stmts {
+		if i > 0 {
+			out.WriteString(" ")
+		}
+		out.WriteString(s.String())
+		if _, ok := s.(*ExpressionStatement); ok && i < len(stmts)-1 {
+			out.WriteString(";")
+		}
+	}
+}
 func (p *Program) TokenLiteral() string {
 	if len(p.Statements) > 0 {
 		return p.Statements[0].TokenLiteral()
 	}
 	return ""
 }
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+func (p *Program) End() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[len(p.Statements)-1].End()
+	}
+	return token.Position{}
+}
 
 type LetStatement struct {
+	Doc   *CommentGroup // the /// comments above it, or nil
 	Token token.Token
 	Name  *Identifier
 	Value Expression
@@ -47,6 +78,8 @@ type LetStatement struct {
 
 func (ls *LetStatement) statementNode()       {}
 func (ls *LetStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *LetStatement) Pos() token.Position  { return ls.Token.Pos }
+func (ls *LetStatement) End() token.Position  { return declEnd(ls.Token, ls.Name, ls.Value) }
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
@@ -63,71 +96,29 @@ func (ls *LetStatement) String() string {
 	return out.String()
 }
 
-type IntStatement struct {
-	Token token.Token
-	Name  *Identifier
-	Value Expression
-}
-
-func (is *IntStatement) statementNode()       {}
-func (is *IntStatement) TokenLiteral() string { return is.Token.Literal }
-func (is *IntStatement) String() string {
-	var out bytes.Buffer
-
-	out.WriteString(is.TokenLiteral() + " ")
-	out.WriteString(is.Name.String())
-	out.WriteString(" = ")
-
-	if is.Value != nil {
-		out.WriteString(is.Value.String())
-	}
-
-	out.WriteString(";")
-
-	return out.String()
-}
-
-type BoolStatement struct {
-	Token token.Token
+// VarDecl declares a variable with an explicit type: int x = 5;
+type VarDecl struct {
+	Doc   *CommentGroup // the /// comments above it, or nil
+	Type  TypeExpr
 	Name  *Identifier
 	Value Expression
 }
 
-func (bs *BoolStatement) statementNode()       {}
-func (bs *BoolStatement) TokenLiteral() string { return bs.Token.Literal }
-func (bs *BoolStatement) String() string {
-	var out bytes.Buffer
-
-	out.WriteString(bs.TokenLiteral() + " ")
-	out.WriteString(bs.Name.String())
-	out.WriteString(" = ")
-
-	if bs.Value != nil {
-		out.WriteString(bs.Value.String())
-	}
-
-	out.WriteString(";")
-
-	return out.String()
+func (vd *VarDecl) statementNode()       {}
+func (vd *VarDecl) TokenLiteral() string { return vd.Type.TokenLiteral() }
+func (vd *VarDecl) Pos() token.Position  { return vd.Type.Pos() }
+func (vd *VarDecl) End() token.Position {
+	return declEnd(token.Token{End: vd.Type.End()}, vd.Name, vd.Value)
 }
-
-type StringStatement struct {
-	Token token.Token
-	Name  *Identifier
-	Value Expression
-}
-
-func (ss *StringStatement) statementNode()       {}
-func (ss *StringStatement) TokenLiteral() string { return ss.Token.Literal }
-func (ss *StringStatement) String() string {
+func (vd *VarDecl) String() string {
 	var out bytes.Buffer
 
-	out.WriteString(ss.TokenLiteral() + " ")
-	out.WriteString(ss.Name.String())
+	out.WriteString(vd.Type.String() + " ")
+	out.WriteString(vd.Name.String())
 	out.WriteString(" = ")
 
-	if ss.Value != nil {
-		out.WriteString(ss.Value.String())
+	if vd.Value != nil {
+		out.WriteString(vd.Value.String())
 	}
 
 	out.WriteString(";")
@@ -142,13 +133,15 @@ type ReturnStatement struct {
 
 func (rs *ReturnStatement) statementNode()       {}
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatement) Pos() token.Position  { return rs.Token.Pos }
+func (rs *ReturnStatement) End() token.Position  { return nodeEnd(rs.Token, rs.ReturnValue) }
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 
-	out.WriteString(rs.TokenLiteral() + " ")
+	out.WriteString(rs.TokenLiteral())
 
 	if rs.ReturnValue != nil {
-		out.WriteString(rs.ReturnValue.String())
+		out.WriteString(" " + rs.ReturnValue.String())
 	}
 
 	out.WriteString(";")
@@ -163,6 +156,8 @@ type ExpressionStatement struct {
 
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Position  { return es.Token.Pos }
+func (es *ExpressionStatement) End() token.Position  { return nodeEnd(es.Token, es.Expression) }
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -178,6 +173,530 @@ type Identifier struct {
 
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
+func (i *Identifier) Pos() token.Position  { return i.Token.Pos }
+func (i *Identifier) End() token.Position  { return i.Token.End }
 func (i *Identifier) String() string {
 	return i.Value
 }
+
+type IntegerLiteral struct {
+	Token token.Token
+	Value int64
+}
+
+func (il *IntegerLiteral) expressionNode()      {}
+func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.Position  { return il.Token.Pos }
+func (il *IntegerLiteral) End() token.Position  { return il.Token.End }
+func (il *IntegerLiteral) String() string       { return il.Token.Literal }
+
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode()      {}
+func (fl *FloatLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FloatLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FloatLiteral) End() token.Position  { return fl.Token.End }
+func (fl *FloatLiteral) String() string       { return fl.Token.Literal }
+
+type Boolean struct {
+	Token token.Token
+	Value bool
+}
+
+func (b *Boolean) expressionNode()      {}
+func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
+func (b *Boolean) Pos() token.Position  { return b.Token.Pos }
+func (b *Boolean) End() token.Position  { return b.Token.End }
+func (b *Boolean) String() string       { return b.Token.Literal }
+
+type Null struct {
+	Token token.Token
+}
+
+func (n *Null) expressionNode()      {}
+func (n *Null) TokenLiteral() string { return n.Token.Literal }
+func (n *Null) Pos() token.Position  { return n.Token.Pos }
+func (n *Null) End() token.Position  { return n.Token.End }
+func (n *Null) String() string       { return n.Token.Literal }
+
+type PrefixExpression struct {
+	Token    token.Token
+	Operator string
+	Right    Expression
+}
+
+func (pe *PrefixExpression) expressionNode()      {}
+func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Position  { return pe.Token.Pos }
+func (pe *PrefixExpression) End() token.Position  { return nodeEnd(pe.Token, pe.Right) }
+func (pe *PrefixExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(pe.Operator)
+	out.WriteString(pe.Right.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+type InfixExpression struct {
+	Token    token.Token
+	Left     Expression
+	Operator string
+	Right    Expression
+}
+
+func (ie *InfixExpression) expressionNode()      {}
+func (ie *InfixExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *InfixExpression) Pos() token.Position  { return nodePos(ie.Token, ie.Left) }
+func (ie *InfixExpression) End() token.Position  { return nodeEnd(ie.Token, ie.Right) }
+func (ie *InfixExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ie.Left.String())
+	out.WriteString(" " + ie.Operator + " ")
+	out.WriteString(ie.Right.String())
+	out.WriteString(")")
+
+	return out.String()
+}
+
+type PostfixExpression struct {
+	Token    token.Token
+	Left     Expression
+	Operator string
+}
+
+func (pe *PostfixExpression) expressionNode()      {}
+func (pe *PostfixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PostfixExpression) Pos() token.Position  { return nodePos(pe.Token, pe.Left) }
+func (pe *PostfixExpression) End() token.Position  { return pe.Token.End }
+func (pe *PostfixExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(pe.Left.String())
+	out.WriteString(pe.Operator)
+	out.WriteString(")")
+
+	return out.String()
+}
+
+// nodePos returns the start of n, falling back to the start of tok when n
+// is missing because of a parse error.
+func nodePos(tok token.Token, n Node) token.Position {
+	if isNil(n) {
+		return tok.Pos
+	}
+	return n.Pos()
+}
+
+// nodeEnd returns the end of n, falling back to the end of tok when n is
+// missing because of a parse error.
+func nodeEnd(tok token.Token, n Node) token.Position {
+	if isNil(n) {
+		return tok.End
+	}
+	return n.End()
+}
+
+// isNil reports whether n is missing, either as a nil interface or as a
+// nil pointer of some node type.
+func isNil(n Node) bool {
+	return n == nil || reflect.ValueOf(n).IsNil()
+}
+
+func declEnd(tok token.Token, name *Identifier, value Expression) token.Position {
+	if value != nil {
+		return nodeEnd(tok, value)
+	}
+	if name != nil {
+		return name.End()
+	}
+	return tok.End
+}
+
+type StringLiteral struct {
+	Token token.Token
+	Value string
+}
+
+func (sl *StringLiteral) expressionNode()      {}
+func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Pos }
+func (sl *StringLiteral) End() token.Position  { return sl.Token.End }
+func (sl *StringLiteral) String() string {
+	if sl.Token.Type == token.RAW_STRING {
+		return "`" + sl.Value + "`"
+	}
+	return `"` + escapeString(sl.Value) + `"`
+}
+
+// InterpolatedString is a string containing ${...} expressions. Parts
+// alternates between *StringLiteral pieces of text and the interpolated
+// expressions, and evaluates to their concatenation.
+type InterpolatedString struct {
+	Token token.Token // the STRING_HEAD token
+	Parts []Expression
+}
+
+func (is *InterpolatedString) expressionNode()      {}
+func (is *InterpolatedString) TokenLiteral() string { return is.Token.Literal }
+func (is *InterpolatedString) Pos() token.Position  { return is.Token.Pos }
+func (is *InterpolatedString) End() token.Position {
+	if len(is.Parts) > 0 {
+		return nodeEnd(is.Token, is.Parts[len(is.Parts)-1])
+	}
+	return is.Token.End
+}
+func (is *InterpolatedString) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(`"`)
+	for _, part := range is.Parts {
+		if text, ok := part.(*StringLiteral); ok {
+			out.WriteString(escapeString(text.Value))
+			continue
+		}
+		out.WriteString("${")
+		if part != nil {
+			out.WriteString(part.String())
+		}
+		out.WriteString("}")
+	}
+	out.WriteString(`"`)
+
+	return out.String()
+}
+
+type BlockStatement struct {
+	Token      token.Token // the '{' token
+	Statements []Statement
+	Rbrace     token.Token
+}
+
+func (bs *BlockStatement) statementNode()       {}
+func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BlockStatement) End() token.Position {
+	if bs.Rbrace.End.IsValid() {
+		return bs.Rbrace.End
+	}
+	if len(bs.Statements) > 0 {
+		return bs.Statements[len(bs.Statements)-1].End()
+	}
+	return bs.Token.End
+}
+func (bs *BlockStatement) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("{ ")
+	writeStatements(&out, bs.Statements)
+	if len(bs.Statements) > 0 {
+		out.WriteString(" ")
+	}
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// IfExpression evaluates to the value of the last expression statement in
+// whichever branch runs.
+type IfExpression struct {
+	Token       token.Token
+	Condition   Expression
+	Consequence *BlockStatement
+	Alternative *BlockStatement
+}
+
+func (ie *IfExpression) expressionNode()      {}
+func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Pos() token.Position  { return ie.Token.Pos }
+func (ie *IfExpression) End() token.Position {
+	if ie.Alternative != nil {
+		return ie.Alternative.End()
+	}
+	if ie.Consequence != nil {
+		return ie.Consequence.End()
+	}
+	return nodeEnd(ie.Token, ie.Condition)
+}
+func (ie *IfExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("if ")
+	out.WriteString(ie.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(ie.Consequence.String())
+
+	if ie.Alternative != nil {
+		out.WriteString(" else ")
+		if elseIf := ie.ElseIf(); elseIf != nil {
+			out.WriteString(elseIf.String())
+		} else {
+			out.WriteString(ie.Alternative.String())
+		}
+	}
+
+	return out.String()
+}
+
+// ElseIf returns the if expression of an 'else if' branch, or nil if the
+// alternative is an ordinary block. The parser wraps 'else if' in a block
+// whose token is the 'else' keyword.
+func (ie *IfExpression) ElseIf() *IfExpression {
+	alt := ie.Alternative
+	if alt == nil || alt.Token.Type != token.ELSE || len(alt.Statements) != 1 {
+		return nil
+	}
+	if es, ok := alt.Statements[0].(*ExpressionStatement); ok {
+		elseIf, _ := es.Expression.(*IfExpression)
+		return elseIf
+	}
+	return nil
+}
+
+// Parameter is a function parameter. Type is nil for an untyped parameter.
+type Parameter struct {
+	Type TypeExpr
+	Name *Identifier
+}
+
+func (p *Parameter) TokenLiteral() string { return p.Name.TokenLiteral() }
+func (p *Parameter) Pos() token.Position {
+	if p.Type != nil {
+		return p.Type.Pos()
+	}
+	return p.Name.Pos()
+}
+func (p *Parameter) End() token.Position { return p.Name.End() }
+func (p *Parameter) String() string {
+	if p.Type != nil {
+		return p.Type.String() + " " + p.Name.String()
+	}
+	return p.Name.String()
+}
+
+type FunctionLiteral struct {
+	Token      token.Token
+	Parameters []*Parameter
+	ReturnType TypeExpr // nil when not declared
+	Body       *BlockStatement
+}
+
+func (fl *FunctionLiteral) expressionNode()      {}
+func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position  { return fl.Token.Pos }
+func (fl *FunctionLiteral) End() token.Position {
+	if fl.Body != nil {
+		return fl.Body.End()
+	}
+	return fl.Token.End
+}
+func (fl *FunctionLiteral) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range fl.Parameters {
+		params = append(params, p.String())
+	}
+
+	out.WriteString(fl.TokenLiteral())
+	out.WriteString("(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") ")
+	if fl.ReturnType != nil {
+		out.WriteString(fl.ReturnType.String() + " ")
+	}
+	out.WriteString(fl.Body.String())
+
+	return out.String()
+}
+
+type CallExpression struct {
+	Token     token.Token // the '(' token
+	Function  Expression
+	Arguments []Expression
+	Rparen    token.Token
+}
+
+func (ce *CallExpression) expressionNode()      {}
+func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Position  { return nodePos(ce.Token, ce.Function) }
+func (ce *CallExpression) End() token.Position {
+	if ce.Rparen.End.IsValid() {
+		return ce.Rparen.End
+	}
+	return ce.Token.End
+}
+func (ce *CallExpression) String() string {
+	var out bytes.Buffer
+
+	args := []string{}
+	for _, a := range ce.Arguments {
+		args = append(args, a.String())
+	}
+
+	out.WriteString(ce.Function.String())
+	out.WriteString("(")
+	out.WriteString(strings.Join(args, ", "))
+	out.WriteString(")")
+
+	return out.String()
+}
+
+type AssignExpression struct {
+	Token  token.Token // the '=' token
+	Target Expression
+	Value  Expression
+}
+
+func (ae *AssignExpression) expressionNode()      {}
+func (ae *AssignExpression) TokenLiteral() string { return ae.Token.Literal }
+func (ae *AssignExpression) Pos() token.Position  { return nodePos(ae.Token, ae.Target) }
+func (ae *AssignExpression) End() token.Position  { return nodeEnd(ae.Token, ae.Value) }
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString(ae.Target.String())
+	out.WriteString(" " + ae.TokenLiteral() + " ")
+	out.WriteString(ae.Value.String())
+
+	return out.String()
+}
+
+type ArrayLiteral struct {
+	Token    token.Token // the '[' token
+	Elements []Expression
+	Rbracket token.Token
+}
+
+func (al *ArrayLiteral) expressionNode()      {}
+func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Pos }
+func (al *ArrayLiteral) End() token.Position  { return closeEnd(al.Token, al.Rbracket) }
+func (al *ArrayLiteral) String() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, el := range al.Elements {
+		elements = append(elements, el.String())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+// HashLiteral is a hash map written out key by key. Pairs are in source
+// order.
+type HashLiteral struct {
+	Token  token.Token // the '{' token
+	Pairs  []*HashPair
+	Rbrace token.Token
+}
+
+func (hl *HashLiteral) expressionNode()      {}
+func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Pos }
+func (hl *HashLiteral) End() token.Position  { return closeEnd(hl.Token, hl.Rbrace) }
+func (hl *HashLiteral) String() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.String())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// HashPair is a key and its value in a hash literal.
+type HashPair struct {
+	Key   Expression
+	Colon token.Token
+	Value Expression
+}
+
+func (hp *HashPair) TokenLiteral() string { return hp.Colon.Literal }
+func (hp *HashPair) Pos() token.Position  { return nodePos(hp.Colon, hp.Key) }
+func (hp *HashPair) End() token.Position  { return nodeEnd(hp.Colon, hp.Value) }
+func (hp *HashPair) String() string {
+	return hp.Key.String() + ": " + hp.Value.String()
+}
+
+type IndexExpression struct {
+	Token    token.Token // the '[' token
+	Left     Expression
+	Index    Expression
+	Rbracket token.Token
+}
+
+func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position  { return nodePos(ie.Token, ie.Left) }
+func (ie *IndexExpression) End() token.Position  { return closeEnd(ie.Token, ie.Rbracket) }
+func (ie *IndexExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(ie.Left.String())
+	out.WriteString("[")
+	out.WriteString(ie.Index.String())
+	out.WriteString("])")
+
+	return out.String()
+}
+
+// SliceExpression is a[low:high]. Low and High are nil when left out,
+// and the slice then runs from the start or to the end.
+type SliceExpression struct {
+	Token    token.Token // the '[' token
+	Left     Expression
+	Low      Expression
+	High     Expression
+	Rbracket token.Token
+}
+
+func (se *SliceExpression) expressionNode()      {}
+func (se *SliceExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SliceExpression) Pos() token.Position  { return nodePos(se.Token, se.Left) }
+func (se *SliceExpression) End() token.Position  { return closeEnd(se.Token, se.Rbracket) }
+func (se *SliceExpression) String() string {
+	var out bytes.Buffer
+
+	out.WriteString("(")
+	out.WriteString(se.Left.String())
+	out.WriteString("[")
+	if se.Low != nil {
+		out.WriteString(se.Low.String())
+	}
+	out.WriteString(":")
+	if se.High != nil {
+		out.WriteString(se.High.String())
+	}
+	out.WriteString("])")
+
+	return out.String()
+}
+
+// closeEnd returns the end of the closing token of a bracketed node,
+// falling back to the end of its opening token when a parse error left it
+// unset.
+func closeEnd(open, close token.Token) token.Position {
+	if close.End.IsValid() {
+		return close.End
+	}
+	return open.End
+}
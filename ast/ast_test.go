@@ -28,3 +28,92 @@ func TestString(t *testing.T) {
 		t.Errorf("Expected '%s'; Got '%s'", expString, program.String())
 	}
 }
+
+func ident(name string) *Identifier {
+	return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+}
+
+func named(name string) *NamedType {
+	return &NamedType{Token: token.Token{Type: token.IDENT, Literal: name}, Name: name}
+}
+
+func TestTypeExprStrings(t *testing.T) {
+	tests := []struct {
+		typ      TypeExpr
+		expected string
+	}{
+		{named("Point"), "Point"},
+		{&GenericType{Base: named("Map"), Args: []TypeExpr{named("string"), &GenericType{Base: named("List"), Args: []TypeExpr{named("int")}}}}, "Map<string, List<int>>"},
+		{&NullableType{Elem: named("int")}, "?int"},
+		{&ErrorUnionType{Error: named("IOError"), Value: named("string")}, "IOError!string"},
+		{&ErrorUnionType{Value: &NullableType{Elem: named("int")}}, "!?int"},
+		{&FunctionType{Params: []TypeExpr{named("int"), named("bool")}, Result: named("string")}, "fn(int, bool) string"},
+		{&FunctionType{}, "fn()"},
+	}
+
+	for _, tt := range tests {
+		if tt.typ.String() != tt.expected {
+			t.Errorf("expected=%q. got=%q", tt.expected, tt.typ.String())
+		}
+	}
+}
+
+func TestExpressionStrings(t *testing.T) {
+	tests := []struct {
+		node     Node
+		expected string
+	}{
+		{
+			&ReturnStatement{Token: token.Token{Type: token.RETURN, Literal: "return"}},
+			"return;",
+		},
+		{
+			&PrefixExpression{Token: token.Token{Type: token.MINUS, Literal: "-"}, Operator: "-",
+				Right: &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "5"}, Value: 5}},
+			"(-5)",
+		},
+		{
+			&PostfixExpression{Token: token.Token{Type: token.INCREMENT, Literal: "++"}, Operator: "++",
+				Left: ident("i")},
+			"(i++)",
+		},
+		{
+			&StringLiteral{Token: token.Token{Type: token.STRING, Literal: "say \"hi\"\n"}, Value: "say \"hi\"\n"},
+			`"say \"hi\"\n"`,
+		},
+		{
+			&IfExpression{
+				Token:     token.Token{Type: token.IF, Literal: "if"},
+				Condition: &Boolean{Token: token.Token{Type: token.TRUE, Literal: "true"}, Value: true},
+				Consequence: &BlockStatement{Statements: []Statement{
+					&ExpressionStatement{Expression: ident("a")},
+					&ExpressionStatement{Expression: ident("b")},
+				}},
+				Alternative: &BlockStatement{Statements: []Statement{
+					&ExpressionStatement{Expression: &Null{Token: token.Token{Type: token.NULL, Literal: "null"}}},
+				}},
+			},
+			"if true { a; b } else { null }",
+		},
+		{
+			&CallExpression{
+				Function: &FunctionLiteral{
+					Token:      token.Token{Type: token.FUNCTION, Literal: "fn"},
+					Parameters: []*Parameter{{Type: named("int"), Name: ident("x")}, {Name: ident("y")}},
+					ReturnType: named("int"),
+					Body: &BlockStatement{Statements: []Statement{
+						&ReturnStatement{Token: token.Token{Type: token.RETURN, Literal: "return"}, ReturnValue: ident("x")},
+					}},
+				},
+				Arguments: []Expression{ident("a"), ident("b")},
+			},
+			"fn(int x, y) int { return x; }(a, b)",
+		},
+	}
+
+	for _, tt := range tests {
+		if tt.node.String() != tt.expected {
+			t.Errorf("expected=%q. got=%q", tt.expected, tt.node.String())
+		}
+	}
+}
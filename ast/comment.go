@@ -0,0 +1,220 @@
+package ast
+
+import (
+	"chimp/token"
+	"sort"
+	"strings"
+)
+
+// Comment is a // or /* */ comment. The parser only keeps comments when
+// its lexer is in ScanComments mode, and gathers them in Program.Comments
+// rather than in the tree, so Walk does not visit them.
+type Comment struct {
+	Token token.Token // the COMMENT token
+	Text  string      // the comment, including its // or /* */
+}
+
+func (c *Comment) TokenLiteral() string { return c.Token.Literal }
+func (c *Comment) Pos() token.Position  { return c.Token.Pos }
+func (c *Comment) End() token.Position  { return c.Token.End }
+func (c *Comment) String() string       { return c.Text }
+
+// IsDoc reports whether c is a /// doc comment.
+func (c *Comment) IsDoc() bool {
+	return strings.HasPrefix(c.Text, "///") && !strings.HasPrefix(c.Text, "////")
+}
+
+// CommentGroup is a run of comments with no code or blank lines between
+// them.
+type CommentGroup struct {
+	List []*Comment
+}
+
+func (g *CommentGroup) TokenLiteral() string { return g.List[0].TokenLiteral() }
+func (g *CommentGroup) Pos() token.Position  { return g.List[0].Pos() }
+func (g *CommentGroup) End() token.Position  { return g.List[len(g.List)-1].End() }
+func (g *CommentGroup) String() string {
+	texts := []string{}
+	for _, c := range g.List {
+		texts = append(texts, c.Text)
+	}
+	return strings.Join(texts, "\n")
+}
+
+// Text returns the text of the comments without their comment markers
+// and the first space after them, one line per line of comment. A nil
+// group has no text.
+func (g *CommentGroup) Text() string {
+	if g == nil {
+		return ""
+	}
+	lines := []string{}
+	for _, c := range g.List {
+		text := c.Text
+		switch {
+		case strings.HasPrefix(text, "///"):
+			text = text[3:]
+		case strings.HasPrefix(text, "//"):
+			text = text[2:]
+		default:
+			text = strings.TrimSuffix(text[2:], "*/")
+		}
+		for _, line := range strings.Split(text, "\n") {
+			lines = append(lines, strings.TrimRight(strings.TrimPrefix(line, " "), " \t\r"))
+		}
+	}
+
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// A CommentMap maps a node to the comment groups associated with it.
+type CommentMap map[Node][]*CommentGroup
+
+// NewCommentMap groups the comments of program and associates each group
+// with a node, for tools that move or rewrite nodes and want their
+// comments to go with them. A group g is associated with node n if:
+//
+//   - g starts on the line n ends on, after n; or
+//   - g starts on the line after n ends, and is followed by a blank line
+//     or by the end of the block; or
+//   - g ends on the line before n starts, or on the line n starts on.
+//
+// Only nodes in the same block as g are considered, and the outermost of
+// several nodes starting or ending at the same place is chosen. Any other
+// group, such as one set apart by blank lines, is associated with the
+// innermost node around it, or with program at the top level.
+func NewCommentMap(program *Program) CommentMap {
+	var nodes []Node
+	Inspect(program, func(n Node) bool {
+		if n != nil && n != Node(program) && n.Pos().IsValid() {
+			nodes = append(nodes, n)
+		}
+		return true
+	})
+
+	cmap := CommentMap{}
+	for _, g := range groupComments(program.Comments, nodes) {
+		n := associate(g, nodes)
+		if n == nil {
+			n = program
+		}
+		cmap[n] = append(cmap[n], g)
+	}
+	return cmap
+}
+
+// groupComments splits comments into groups at blank lines and code. A
+// comment that trails code on its line starts a group of its own.
+func groupComments(comments []*Comment, nodes []Node) []*CommentGroup {
+	starts := []int{}
+	ends := map[int]int{} // line -> offset of the first node end on it
+	for _, n := range nodes {
+		starts = append(starts, n.Pos().Offset)
+		end := n.End()
+		if off, ok := ends[end.Line]; !ok || end.Offset < off {
+			ends[end.Line] = end.Offset
+		}
+	}
+	sort.Ints(starts)
+
+	// codeBetween reports whether a node starts between offsets from and to.
+	codeBetween := func(from, to int) bool {
+		i := sort.SearchInts(starts, from)
+		return i < len(starts) && starts[i] < to
+	}
+	trailing := func(c *Comment) bool {
+		off, ok := ends[c.Pos().Line]
+		return ok && off <= c.Pos().Offset
+	}
+
+	var groups []*CommentGroup
+	for i, c := range comments {
+		if i > 0 && !trailing(c) {
+			prev := comments[i-1]
+			g := groups[len(groups)-1]
+			sameLine := c.Pos().Line == prev.End().Line
+			if (sameLine || c.Pos().Line == prev.End().Line+1 && !trailing(g.List[0])) &&
+				!codeBetween(prev.End().Offset, c.Pos().Offset) {
+				g.List = append(g.List, c)
+				continue
+			}
+		}
+		groups = append(groups, &CommentGroup{List: []*Comment{c}})
+	}
+	return groups
+}
+
+func associate(g *CommentGroup, nodes []Node) Node {
+	var prev, next, enclosing Node
+	for _, n := range nodes {
+		switch pos, end := n.Pos().Offset, n.End().Offset; {
+		case pos <= g.Pos().Offset && end >= g.End().Offset:
+			enclosing = n
+		case end <= g.Pos().Offset:
+			if prev == nil || end > prev.End().Offset {
+				prev = n
+			}
+		case pos >= g.End().Offset:
+			if next == nil || pos < next.Pos().Offset {
+				next = n
+			}
+		}
+	}
+	if enclosing != nil {
+		if prev != nil && prev.End().Offset <= enclosing.Pos().Offset {
+			prev = nil
+		}
+		if next != nil && next.Pos().Offset >= enclosing.End().Offset {
+			next = nil
+		}
+	}
+
+	switch {
+	case prev != nil && prev.End().Line == g.Pos().Line:
+		return prev
+	case prev != nil && prev.End().Line+1 == g.Pos().Line &&
+		(next == nil || next.Pos().Line > g.End().Line+1):
+		return prev
+	case next != nil && next.Pos().Line <= g.End().Line+1:
+		return next
+	}
+	return enclosing
+}
+
+// Update replaces old with new in the map, moving old's comments to new,
+// and returns new.
+func (cmap CommentMap) Update(old, new Node) Node {
+	if list := cmap[old]; len(list) > 0 {
+		delete(cmap, old)
+		cmap[new] = append(cmap[new], list...)
+	}
+	return new
+}
+
+// Filter returns a new comment map holding the entries of cmap for node
+// and the nodes below it.
+func (cmap CommentMap) Filter(node Node) CommentMap {
+	filtered := CommentMap{}
+	Inspect(node, func(n Node) bool {
+		if list := cmap[n]; n != nil && len(list) > 0 {
+			filtered[n] = list
+		}
+		return true
+	})
+	return filtered
+}
+
+// Comments returns all the comment groups in the map, in source order.
+func (cmap CommentMap) Comments() []*CommentGroup {
+	list := []*CommentGroup{}
+	for _, groups := range cmap {
+		list = append(list, groups...)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Pos().Offset < list[j].Pos().Offset
+	})
+	return list
+}
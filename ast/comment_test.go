@@ -0,0 +1,131 @@
+package ast_test
+
+import (
+	"chimp/ast"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// describeNode names a node for comparing comment map entries.
+func describeNode(n ast.Node) string {
+	switch n := n.(type) {
+	case *ast.Program:
+		return "Program"
+	case *ast.ExpressionStatement:
+		return "stmt " + n.String()
+	case *ast.LetStatement:
+		return "let " + n.Name.Value
+	}
+	return fmt.Sprintf("%T %s", n, n)
+}
+
+func TestCommentMap(t *testing.T) {
+	input := `// header
+
+let a = 1; // after a
+// before b
+let b = 2;
+// after b
+
+c
+let f = fn() { // opens the block
+    d
+    // end of block
+}
+/* last */
+
+// detached
+
+g`
+
+	expected := map[string]string{
+		"// header":          "Program",
+		"// after a":         "let a",
+		"// before b":        "let b",
+		"// after b":         "let b",
+		"// opens the block": "stmt d",
+		"// end of block":    "stmt d",
+		"/* last */":         "let f",
+		"// detached":        "Program",
+	}
+
+	program := parse(t, input)
+	cmap := ast.NewCommentMap(program)
+
+	got := map[string]string{}
+	for n, groups := range cmap {
+		for _, g := range groups {
+			got[g.String()] = describeNode(n)
+		}
+	}
+
+	for comment, node := range expected {
+		if got[comment] != node {
+			t.Errorf("%q: expected=%q. got=%q", comment, node, got[comment])
+		}
+	}
+	if len(got) != len(expected) {
+		t.Errorf("groups: expected=%d. got=%d: %v", len(expected), len(got), got)
+	}
+}
+
+func TestCommentGroups(t *testing.T) {
+	input := `// one
+// still one
+/* and one */
+
+// two
+x // three
+// four`
+
+	program := parse(t, input)
+	groups := ast.NewCommentMap(program).Comments()
+
+	expected := []string{"// one\n// still one\n/* and one */", "// two", "// three", "// four"}
+	if len(groups) != len(expected) {
+		t.Fatalf("groups: expected=%d. got=%d", len(expected), len(groups))
+	}
+	for i, exp := range expected {
+		if groups[i].String() != exp {
+			t.Errorf("groups[%d]: expected=%q. got=%q", i, exp, groups[i].String())
+		}
+	}
+}
+
+func TestCommentGroupText(t *testing.T) {
+	program := parse(t, "/// Adds two numbers.\n///\n///   Indented.   \n/* block\n   text */\nlet a = 1;")
+	groups := ast.NewCommentMap(program).Comments()
+
+	expected := "Adds two numbers.\n\n  Indented.\nblock\n  text"
+	if text := groups[0].Text(); text != expected {
+		t.Errorf("expected=%q. got=%q", expected, text)
+	}
+}
+
+func TestCommentMapUpdate(t *testing.T) {
+	program := parse(t, "let a = 1; // a\nlet b = 2; // b")
+	cmap := ast.NewCommentMap(program)
+
+	ast.Apply(program, func(c *ast.Cursor) bool {
+		if let, ok := c.Node().(*ast.LetStatement); ok && let.Name.Value == "a" {
+			renamed := &ast.LetStatement{Token: let.Token, Name: &ast.Identifier{Token: let.Name.Token, Value: "z"}, Value: let.Value}
+			c.Replace(cmap.Update(let, renamed))
+		}
+		return true
+	}, nil)
+
+	first := program.Statements[0]
+	if len(cmap[first]) != 1 || cmap[first][0].String() != "// a" {
+		t.Errorf("comments of the replacement: expected=%q. got=%v", "// a", cmap[first])
+	}
+
+	filtered := cmap.Filter(program.Statements[1])
+	var texts []string
+	for _, g := range filtered.Comments() {
+		texts = append(texts, g.String())
+	}
+	if strings.Join(texts, " ") != "// b" {
+		t.Errorf("filtered: expected=%q. got=%q", "// b", strings.Join(texts, " "))
+	}
+}
@@ -0,0 +1,38 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// escapeString returns s as the body of a double-quoted Chimp string
+// literal, escaping anything the lexer would not read back verbatim.
+func escapeString(s string) string {
+	var out strings.Builder
+
+	runes := []rune(s)
+	for i, char := range runes {
+		switch {
+		case char == '"' || char == '\\':
+			out.WriteRune('\\')
+			out.WriteRune(char)
+		case char == '$' && i+1 < len(runes) && runes[i+1] == '{':
+			out.WriteString(`\$`)
+		case char == '\n':
+			out.WriteString(`\n`)
+		case char == '\t':
+			out.WriteString(`\t`)
+		case char == '\r':
+			out.WriteString(`\r`)
+		case char == 0:
+			out.WriteString(`\0`)
+		case !unicode.IsPrint(char):
+			out.WriteString(fmt.Sprintf(`\u{%x}`, char))
+		default:
+			out.WriteRune(char)
+		}
+	}
+
+	return out.String()
+}
@@ -0,0 +1,239 @@
+package ast
+
+import (
+	"bytes"
+	"chimp/token"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// nodeTypes maps the name of every node type to its struct type.
+var nodeTypes = map[string]reflect.Type{}
+
+func init() {
+	for _, n := range []Node{
+		&Program{},
+		&LetStatement{}, &VarDecl{}, &ReturnStatement{}, &ExpressionStatement{}, &BlockStatement{},
+		&Identifier{}, &IntegerLiteral{}, &FloatLiteral{}, &Boolean{}, &Null{},
+		&PrefixExpression{}, &InfixExpression{}, &PostfixExpression{},
+		&StringLiteral{}, &InterpolatedString{}, &IfExpression{}, &Parameter{},
+		&FunctionLiteral{}, &CallExpression{}, &AssignExpression{},
+		&ArrayLiteral{}, &HashLiteral{}, &HashPair{}, &IndexExpression{}, &SliceExpression{},
+		&NamedType{}, &GenericType{}, &NullableType{}, &ErrorUnionType{}, &FunctionType{},
+		&Comment{}, &CommentGroup{},
+	} {
+		t := reflect.TypeOf(n).Elem()
+		nodeTypes[t.Name()] = t
+	}
+}
+
+var (
+	nodeType  = reflect.TypeOf((*Node)(nil)).Elem()
+	tokenType = reflect.TypeOf(token.Token{})
+)
+
+// MarshalJSON encodes node and everything below it as JSON.
+//
+// Each node is an object whose "node" member names its type, followed by
+// its fields in declaration order, named as in Go but starting with a
+// lower-case letter. Tokens carry their type, literal and start and end
+// positions. Missing children and empty lists are null. The outermost
+// object also has a "file" member with the filename of its positions.
+func MarshalJSON(node Node) ([]byte, error) {
+	e := &encoder{}
+	if err := e.node(node, nodePos(token.Token{}, node).Filename); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+type encoder struct {
+	buf bytes.Buffer
+}
+
+func (e *encoder) node(n Node, file string) error {
+	if isNil(n) {
+		e.buf.WriteString("null")
+		return nil
+	}
+
+	v := reflect.ValueOf(n).Elem()
+	t := v.Type()
+	if nodeTypes[t.Name()] != t {
+		return fmt.Errorf("ast: cannot marshal %T", n)
+	}
+
+	e.buf.WriteString(`{"node":`)
+	e.value(t.Name())
+	if file != "" {
+		e.buf.WriteString(`,"file":`)
+		e.value(file)
+	}
+	for i := 0; i < t.NumField(); i++ {
+		e.buf.WriteString(",")
+		e.value(jsonName(t.Field(i).Name))
+		e.buf.WriteString(":")
+		if err := e.field(v.Field(i)); err != nil {
+			return err
+		}
+	}
+	e.buf.WriteString("}")
+
+	return nil
+}
+
+func (e *encoder) field(v reflect.Value) error {
+	switch {
+	case v.Type().Implements(nodeType):
+		n, _ := v.Interface().(Node)
+		return e.node(n, "")
+	case v.Kind() == reflect.Slice:
+		if v.IsNil() {
+			e.buf.WriteString("null")
+			return nil
+		}
+		e.buf.WriteString("[")
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				e.buf.WriteString(",")
+			}
+			if err := e.field(v.Index(i)); err != nil {
+				return err
+			}
+		}
+		e.buf.WriteString("]")
+		return nil
+	default:
+		e.value(v.Interface())
+		return nil
+	}
+}
+
+// value writes a token or a scalar, which always encode.
+func (e *encoder) value(x interface{}) {
+	b, _ := json.Marshal(x)
+	e.buf.Write(b)
+}
+
+// UnmarshalJSON decodes a node encoded by MarshalJSON. Positions take the
+// filename from the outermost object's "file" member.
+func UnmarshalJSON(data []byte) (Node, error) {
+	d := &decoder{}
+	return d.node(data, true)
+}
+
+type decoder struct {
+	file string
+}
+
+func (d *decoder) node(data json.RawMessage, root bool) (Node, error) {
+	if isNull(data) {
+		return nil, nil
+	}
+
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, fmt.Errorf("ast: expected a node object")
+	}
+
+	var name string
+	if err := json.Unmarshal(obj["node"], &name); err != nil || name == "" {
+		return nil, fmt.Errorf(`ast: object has no "node" member naming its type`)
+	}
+	t, ok := nodeTypes[name]
+	if !ok {
+		return nil, fmt.Errorf("ast: unknown node type %q", name)
+	}
+
+	if raw, ok := obj["file"]; ok && root {
+		if err := json.Unmarshal(raw, &d.file); err != nil {
+			return nil, fmt.Errorf("ast: file: %w", err)
+		}
+	}
+
+	v := reflect.New(t)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		raw, ok := obj[jsonName(f.Name)]
+		if !ok {
+			continue
+		}
+		if err := d.field(v.Elem().Field(i), raw); err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", name, jsonName(f.Name), err)
+		}
+	}
+
+	return v.Interface().(Node), nil
+}
+
+func (d *decoder) field(v reflect.Value, raw json.RawMessage) error {
+	switch {
+	case v.Type().Implements(nodeType):
+		n, err := d.node(raw, false)
+		if err != nil || n == nil {
+			return err
+		}
+		nv := reflect.ValueOf(n)
+		if !nv.Type().AssignableTo(v.Type()) {
+			return fmt.Errorf("%s is not %s", nv.Elem().Type().Name(), article(v.Type()))
+		}
+		v.Set(nv)
+	case v.Kind() == reflect.Slice:
+		if isNull(raw) {
+			return nil
+		}
+		var elems []json.RawMessage
+		if err := json.Unmarshal(raw, &elems); err != nil {
+			return fmt.Errorf("expected a list")
+		}
+		s := reflect.MakeSlice(v.Type(), len(elems), len(elems))
+		for i, elem := range elems {
+			if err := d.field(s.Index(i), elem); err != nil {
+				return fmt.Errorf("[%d]: %w", i, err)
+			}
+		}
+		v.Set(s)
+	case v.Type() == tokenType:
+		var tok token.Token
+		if err := json.Unmarshal(raw, &tok); err != nil {
+			return fmt.Errorf("expected a token")
+		}
+		if tok.Pos.IsValid() {
+			tok.Pos.Filename = d.file
+		}
+		if tok.End.IsValid() {
+			tok.End.Filename = d.file
+		}
+		v.Set(reflect.ValueOf(tok))
+	default:
+		if err := json.Unmarshal(raw, v.Addr().Interface()); err != nil {
+			return fmt.Errorf("expected %s", v.Type())
+		}
+	}
+	return nil
+}
+
+func isNull(data json.RawMessage) bool {
+	return len(data) == 0 || string(bytes.TrimSpace(data)) == "null"
+}
+
+// jsonName returns the JSON member name of a node field.
+func jsonName(field string) string {
+	r, size := utf8.DecodeRuneInString(field)
+	return string(unicode.ToLower(r)) + field[size:]
+}
+
+// article names the node type t for an error message: "an Expression".
+func article(t reflect.Type) string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if strings.ContainsRune("AEIOU", rune(t.Name()[0])) {
+		return "an " + t.Name()
+	}
+	return "a " + t.Name()
+}
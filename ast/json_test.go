@@ -0,0 +1,129 @@
+package ast_test
+
+import (
+	"bytes"
+	"chimp/ast"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	program := everyNode(t)
+
+	data, err := ast.MarshalJSON(program)
+	if err != nil {
+		t.Fatalf("MarshalJSON: %s", err)
+	}
+	if !json.Valid(data) {
+		t.Fatalf("MarshalJSON produced invalid JSON: %s", data)
+	}
+
+	node, err := ast.UnmarshalJSON(data)
+	if err != nil {
+		t.Fatalf("UnmarshalJSON: %s", err)
+	}
+	if !reflect.DeepEqual(node, program) {
+		t.Errorf("round trip changed the program.\nexpected=%s\ngot=     %s", program, node)
+	}
+
+	again, err := ast.MarshalJSON(node)
+	if err != nil {
+		t.Fatalf("MarshalJSON: %s", err)
+	}
+	if !bytes.Equal(again, data) {
+		t.Errorf("re-encoding differs.\nfirst= %s\nsecond=%s", data, again)
+	}
+}
+
+func TestJSONPositions(t *testing.T) {
+	program := parse(t, "let x = 1;")
+
+	data, err := ast.MarshalJSON(program.Statements[0])
+	if err != nil {
+		t.Fatalf("MarshalJSON: %s", err)
+	}
+	expected := `{"node":"LetStatement","file":"walktest","doc":null,"token":{"type":"LET","literal":"let","pos":{"offset":0,"line":1,"column":1},"end":{"offset":3,"line":1,"column":4}},"name":{"node":"Identifier"`
+	if !strings.HasPrefix(string(data), expected) {
+		t.Errorf("unexpected encoding: %s", data)
+	}
+
+	node, err := ast.UnmarshalJSON(data)
+	if err != nil {
+		t.Fatalf("UnmarshalJSON: %s", err)
+	}
+	let := node.(*ast.LetStatement)
+	if let.Value.Pos() != program.Statements[0].(*ast.LetStatement).Value.Pos() {
+		t.Errorf("value position: expected=%v. got=%v", program.Statements[0].(*ast.LetStatement).Value.Pos(), let.Value.Pos())
+	}
+	if let.Name.Pos().Filename != "walktest" {
+		t.Errorf("filename: expected=%q. got=%q", "walktest", let.Name.Pos().Filename)
+	}
+}
+
+func TestUnmarshalJSONErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`[1]`, "ast: expected a node object"},
+		{`{"value":"x"}`, `ast: object has no "node" member naming its type`},
+		{`{"node":"WhileLoop"}`, `ast: unknown node type "WhileLoop"`},
+		{`{"node":"Program","statements":[{"node":"Identifier","value":"x"}]}`, "Program.statements: [0]: Identifier is not a Statement"},
+		{`{"node":"LetStatement","name":{"node":"NamedType","name":"int"}}`, "LetStatement.name: NamedType is not an Identifier"},
+		{`{"node":"IntegerLiteral","value":"five"}`, "IntegerLiteral.value: expected int64"},
+		{`{"node":"CallExpression","arguments":{}}`, "CallExpression.arguments: expected a list"},
+	}
+
+	for _, tt := range tests {
+		_, err := ast.UnmarshalJSON([]byte(tt.input))
+		if err == nil {
+			t.Errorf("%s: expected an error", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("%s: expected=%q. got=%q", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestSexpr(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"a + 1", "(Program (ExpressionStatement (InfixExpression + (Identifier a) (IntegerLiteral 1))))"},
+		{`?int n = "s";`, `(Program (VarDecl (NullableType (NamedType int)) (Identifier n) (StringLiteral "s")))`},
+		{"if x { y }", "(Program (ExpressionStatement (IfExpression (Identifier x) (BlockStatement (ExpressionStatement (Identifier y))))))"},
+	}
+
+	for _, tt := range tests {
+		got := ast.Sexpr(parse(t, tt.input))
+		if got != tt.expected {
+			t.Errorf("%q: expected=%q. got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestFprint(t *testing.T) {
+	var out bytes.Buffer
+	if err := ast.Fprint(&out, parse(t, "let x = -a;\nf(1, true)")); err != nil {
+		t.Fatalf("Fprint: %s", err)
+	}
+
+	expected := `Program @1:1
+├── Statements[0]: LetStatement @1:1
+│   ├── Name: Identifier x @1:5
+│   └── Value: PrefixExpression - @1:9
+│       └── Right: Identifier a @1:10
+└── Statements[1]: ExpressionStatement @2:1
+    └── Expression: CallExpression @2:1
+        ├── Function: Identifier f @2:1
+        ├── Arguments[0]: IntegerLiteral 1 @2:3
+        └── Arguments[1]: Boolean true @2:6
+`
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
@@ -0,0 +1,114 @@
+package ast
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// child is a node below another one, labelled with the field holding it.
+type child struct {
+	label string
+	node  Node
+}
+
+// describe splits the fields of n into its scalars, such as an operator or
+// a literal's value, and its children, leaving out missing children.
+func describe(n Node) (scalars []string, children []child) {
+	v := reflect.ValueOf(n).Elem()
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		f, fv := t.Field(i), v.Field(i)
+		switch {
+		case fv.Type() == tokenType:
+		case fv.Type().Implements(nodeType):
+			if c, _ := fv.Interface().(Node); !isNil(c) {
+				children = append(children, child{f.Name, c})
+			}
+		case fv.Kind() == reflect.Slice:
+			for j := 0; j < fv.Len(); j++ {
+				if c, _ := fv.Index(j).Interface().(Node); !isNil(c) {
+					children = append(children, child{fmt.Sprintf("%s[%d]", f.Name, j), c})
+				}
+			}
+		case fv.Kind() == reflect.String:
+			if quoted(n) {
+				scalars = append(scalars, strconv.Quote(fv.String()))
+			} else {
+				scalars = append(scalars, fv.String())
+			}
+		default:
+			scalars = append(scalars, fmt.Sprint(fv.Interface()))
+		}
+	}
+
+	return scalars, children
+}
+
+// quoted reports whether the text of n is printed as a Go string.
+func quoted(n Node) bool {
+	switch n.(type) {
+	case *StringLiteral, *Comment:
+		return true
+	}
+	return false
+}
+
+// Sexpr returns node as an S-expression. Each list holds the node type,
+// its scalars and then its children:
+//
+//	(InfixExpression + (Identifier a) (IntegerLiteral 1))
+func Sexpr(node Node) string {
+	var out strings.Builder
+	writeSexpr(&out, node)
+	return out.String()
+}
+
+func writeSexpr(out *strings.Builder, n Node) {
+	scalars, children := describe(n)
+
+	out.WriteString("(")
+	out.WriteString(reflect.TypeOf(n).Elem().Name())
+	for _, s := range scalars {
+		out.WriteString(" " + s)
+	}
+	for _, c := range children {
+		out.WriteString(" ")
+		writeSexpr(out, c.node)
+	}
+	out.WriteString(")")
+}
+
+// Fprint prints node to w as an indented tree, one node per line with the
+// field holding it, its scalars and its position.
+func Fprint(w io.Writer, node Node) error {
+	bw := bufio.NewWriter(w)
+	printTree(bw, "", "", node)
+	return bw.Flush()
+}
+
+func printTree(w *bufio.Writer, label, indent string, n Node) {
+	scalars, children := describe(n)
+
+	w.WriteString(label)
+	w.WriteString(reflect.TypeOf(n).Elem().Name())
+	for _, s := range scalars {
+		w.WriteString(" " + s)
+	}
+	if pos := n.Pos(); pos.IsValid() {
+		fmt.Fprintf(w, " @%d:%d", pos.Line, pos.Column)
+	}
+	w.WriteString("\n")
+
+	for i, c := range children {
+		branch, next := "├── ", "│   "
+		if i == len(children)-1 {
+			branch, next = "└── ", "    "
+		}
+		printTree(w, indent+branch+c.label+": ", indent+next, c.node)
+	}
+}
@@ -0,0 +1,120 @@
+package ast
+
+import (
+	"bytes"
+	"chimp/token"
+	"strings"
+)
+
+// TypeExpr is a type as written in the source, in a declaration or a
+// function signature.
+type TypeExpr interface {
+	Node
+	typeExprNode()
+}
+
+// NamedType is a builtin or user-defined type name: int, Point.
+type NamedType struct {
+	Token token.Token
+	Name  string
+}
+
+func (nt *NamedType) typeExprNode()        {}
+func (nt *NamedType) TokenLiteral() string { return nt.Token.Literal }
+func (nt *NamedType) Pos() token.Position  { return nt.Token.Pos }
+func (nt *NamedType) End() token.Position  { return nt.Token.End }
+func (nt *NamedType) String() string       { return nt.Name }
+
+// GenericType instantiates a generic type: List<int>, Map<string, T>.
+type GenericType struct {
+	Base   *NamedType
+	Args   []TypeExpr
+	Rangle token.Token // the closing '>', which may be half of a '>>'
+}
+
+func (gt *GenericType) typeExprNode()        {}
+func (gt *GenericType) TokenLiteral() string { return gt.Base.TokenLiteral() }
+func (gt *GenericType) Pos() token.Position  { return gt.Base.Pos() }
+func (gt *GenericType) End() token.Position  { return gt.Rangle.End }
+func (gt *GenericType) String() string {
+	var out bytes.Buffer
+
+	args := []string{}
+	for _, a := range gt.Args {
+		args = append(args, a.String())
+	}
+
+	out.WriteString(gt.Base.String())
+	out.WriteString("<")
+	out.WriteString(strings.Join(args, ", "))
+	out.WriteString(">")
+
+	return out.String()
+}
+
+// NullableType is ?T, either a T or null.
+type NullableType struct {
+	Token token.Token // the '?' token
+	Elem  TypeExpr
+}
+
+func (nt *NullableType) typeExprNode()        {}
+func (nt *NullableType) TokenLiteral() string { return nt.Token.Literal }
+func (nt *NullableType) Pos() token.Position  { return nt.Token.Pos }
+func (nt *NullableType) End() token.Position  { return nodeEnd(nt.Token, nt.Elem) }
+func (nt *NullableType) String() string       { return "?" + nt.Elem.String() }
+
+// ErrorUnionType is E!T, either a T or an error of type E. Error is nil in
+// !T, whose error type is inferred.
+type ErrorUnionType struct {
+	Error TypeExpr
+	Token token.Token // the '!' token
+	Value TypeExpr
+}
+
+func (et *ErrorUnionType) typeExprNode()        {}
+func (et *ErrorUnionType) TokenLiteral() string { return et.Token.Literal }
+func (et *ErrorUnionType) Pos() token.Position  { return nodePos(et.Token, et.Error) }
+func (et *ErrorUnionType) End() token.Position  { return nodeEnd(et.Token, et.Value) }
+func (et *ErrorUnionType) String() string {
+	if et.Error != nil {
+		return et.Error.String() + "!" + et.Value.String()
+	}
+	return "!" + et.Value.String()
+}
+
+// FunctionType is the type of a function: fn(int, bool) string. Result is
+// nil for a function that returns nothing.
+type FunctionType struct {
+	Token  token.Token // the 'fn' token
+	Params []TypeExpr
+	Rparen token.Token
+	Result TypeExpr
+}
+
+func (ft *FunctionType) typeExprNode()        {}
+func (ft *FunctionType) TokenLiteral() string { return ft.Token.Literal }
+func (ft *FunctionType) Pos() token.Position  { return ft.Token.Pos }
+func (ft *FunctionType) End() token.Position {
+	if ft.Result != nil {
+		return ft.Result.End()
+	}
+	return ft.Rparen.End
+}
+func (ft *FunctionType) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range ft.Params {
+		params = append(params, p.String())
+	}
+
+	out.WriteString("fn(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(")")
+	if ft.Result != nil {
+		out.WriteString(" " + ft.Result.String())
+	}
+
+	return out.String()
+}
@@ -0,0 +1,150 @@
+package ast
+
+import "fmt"
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children of
+// node with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses an AST in depth-first order, starting with a call of
+// v.Visit(node). Missing optional children, such as the alternative of an
+// if without else, are skipped, and so are comments, including the doc
+// comments of declarations.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(v, n.Statements)
+
+	// Statements
+	case *LetStatement:
+		walk(v, n.Name)
+		walk(v, n.Value)
+	case *VarDecl:
+		walk(v, n.Type)
+		walk(v, n.Name)
+		walk(v, n.Value)
+	case *ReturnStatement:
+		walk(v, n.ReturnValue)
+	case *ExpressionStatement:
+		walk(v, n.Expression)
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+
+	// Expressions
+	case *Identifier, *IntegerLiteral, *FloatLiteral, *Boolean, *Null, *StringLiteral:
+		// leaves
+	case *PrefixExpression:
+		walk(v, n.Right)
+	case *InfixExpression:
+		walk(v, n.Left)
+		walk(v, n.Right)
+	case *PostfixExpression:
+		walk(v, n.Left)
+	case *InterpolatedString:
+		walkExpressions(v, n.Parts)
+	case *IfExpression:
+		walk(v, n.Condition)
+		walk(v, n.Consequence)
+		walk(v, n.Alternative)
+	case *Parameter:
+		walk(v, n.Type)
+		walk(v, n.Name)
+	case *FunctionLiteral:
+		for _, p := range n.Parameters {
+			walk(v, p)
+		}
+		walk(v, n.ReturnType)
+		walk(v, n.Body)
+	case *CallExpression:
+		walk(v, n.Function)
+		walkExpressions(v, n.Arguments)
+	case *AssignExpression:
+		walk(v, n.Target)
+		walk(v, n.Value)
+	case *ArrayLiteral:
+		walkExpressions(v, n.Elements)
+	case *HashLiteral:
+		for _, pair := range n.Pairs {
+			walk(v, pair)
+		}
+	case *HashPair:
+		walk(v, n.Key)
+		walk(v, n.Value)
+	case *IndexExpression:
+		walk(v, n.Left)
+		walk(v, n.Index)
+	case *SliceExpression:
+		walk(v, n.Left)
+		walk(v, n.Low)
+		walk(v, n.High)
+
+	// Types
+	case *NamedType:
+		// leaf
+	case *GenericType:
+		walk(v, n.Base)
+		walkTypes(v, n.Args)
+	case *NullableType:
+		walk(v, n.Elem)
+	case *ErrorUnionType:
+		walk(v, n.Error)
+		walk(v, n.Value)
+	case *FunctionType:
+		walkTypes(v, n.Params)
+		walk(v, n.Result)
+
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+// walk walks n unless it is missing.
+func walk(v Visitor, n Node) {
+	if !isNil(n) {
+		Walk(v, n)
+	}
+}
+
+func walkStatements(v Visitor, list []Statement) {
+	for _, s := range list {
+		walk(v, s)
+	}
+}
+
+func walkExpressions(v Visitor, list []Expression) {
+	for _, e := range list {
+		walk(v, e)
+	}
+}
+
+func walkTypes(v Visitor, list []TypeExpr) {
+	for _, t := range list {
+		walk(v, t)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order: it starts by calling
+// f(node); node must not be nil. If f returns true, Inspect invokes f
+// recursively for each of the children of node, followed by a call of
+// f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
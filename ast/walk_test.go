@@ -0,0 +1,260 @@
+package ast_test
+
+import (
+	"chimp/ast"
+	"chimp/lexer"
+	"chimp/parser"
+	"chimp/token"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	l := lexer.New(input, "walktest")
+	l.Mode = lexer.ScanComments
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if errs := append(l.Errors, p.Errors()...); len(errs) > 0 {
+		t.Fatalf("%q: parse errors: %v", input, errs)
+	}
+	return program
+}
+
+// everyNode parses a program using every kind of node.
+func everyNode(t *testing.T) *ast.Program {
+	program := parse(t, `
+/// The sum.
+let x = -a + b; // trailing
+int y = 1.5;
+?List<int> n = null;
+E!fn(int) string f = g;
+!T h = k;
+let fn1 = fn(int p, q) bool { return p == q; };
+if x { "s ${y}" } else { true }
+x = add(1, "plain");
+let arr = [1, {"k": 2}][0][1:];
+i++;
+`)
+	return program
+}
+
+func TestInspectCoversEveryNode(t *testing.T) {
+	expected := []string{
+		"*ast.ArrayLiteral", "*ast.AssignExpression", "*ast.BlockStatement", "*ast.Boolean",
+		"*ast.CallExpression", "*ast.ErrorUnionType", "*ast.ExpressionStatement",
+		"*ast.FloatLiteral", "*ast.FunctionLiteral", "*ast.FunctionType",
+		"*ast.GenericType", "*ast.HashLiteral", "*ast.HashPair", "*ast.Identifier",
+		"*ast.IfExpression", "*ast.IndexExpression",
+		"*ast.InfixExpression", "*ast.IntegerLiteral", "*ast.InterpolatedString",
+		"*ast.LetStatement", "*ast.NamedType", "*ast.Null", "*ast.NullableType",
+		"*ast.Parameter", "*ast.PostfixExpression", "*ast.PrefixExpression",
+		"*ast.Program", "*ast.ReturnStatement", "*ast.SliceExpression", "*ast.StringLiteral",
+		"*ast.VarDecl",
+	}
+
+	seen := map[string]bool{}
+	depth := 0
+	ast.Inspect(everyNode(t), func(n ast.Node) bool {
+		if n == nil {
+			depth--
+			return false
+		}
+		depth++
+		seen[fmt.Sprintf("%T", n)] = true
+		return true
+	})
+
+	got := []string{}
+	for name := range seen {
+		got = append(got, name)
+	}
+	sort.Strings(got)
+	if strings.Join(got, " ") != strings.Join(expected, " ") {
+		t.Errorf("visited node types:\nexpected=%v\ngot=     %v", expected, got)
+	}
+	if depth != 0 {
+		t.Errorf("Visit(nil) calls do not balance: depth=%d", depth)
+	}
+}
+
+func TestApplyCoversEveryNode(t *testing.T) {
+	program := everyNode(t)
+
+	walked := 0
+	ast.Inspect(program, func(n ast.Node) bool {
+		if n != nil {
+			walked++
+		}
+		return true
+	})
+
+	applied := 0
+	ast.Apply(program, func(c *ast.Cursor) bool {
+		if c.Node() != nil {
+			applied++
+		}
+		return true
+	}, nil)
+
+	if applied != walked {
+		t.Errorf("Apply visited %d nodes, Walk visited %d", applied, walked)
+	}
+}
+
+func TestInspectOrder(t *testing.T) {
+	program := parse(t, "let x = a + f(b); int y = 1;")
+
+	var got []string
+	ast.Inspect(program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case nil:
+		case *ast.Identifier:
+			got = append(got, n.Value)
+		default:
+			got = append(got, strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast."))
+		}
+		return true
+	})
+
+	expected := "Program LetStatement x InfixExpression a CallExpression f b VarDecl NamedType y IntegerLiteral"
+	if strings.Join(got, " ") != expected {
+		t.Errorf("expected=%q. got=%q", expected, strings.Join(got, " "))
+	}
+}
+
+func TestInspectPrune(t *testing.T) {
+	program := parse(t, "let f = fn(a) { b }; c")
+
+	var idents []string
+	ast.Inspect(program, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.Identifier:
+			idents = append(idents, n.Value)
+		}
+		return true
+	})
+
+	if strings.Join(idents, " ") != "f c" {
+		t.Errorf("expected=%q. got=%q", "f c", strings.Join(idents, " "))
+	}
+}
+
+func TestApplyReplace(t *testing.T) {
+	program := parse(t, "let x = a + b * a; if a { a } else { f(a) }")
+
+	ast.Apply(program, func(c *ast.Cursor) bool {
+		if id, ok := c.Node().(*ast.Identifier); ok && id.Value == "a" {
+			c.Replace(&ast.IntegerLiteral{Token: token.Token{Type: token.INT, Literal: "1"}, Value: 1})
+		}
+		return true
+	}, nil)
+
+	expected := "let x = (1 + (b * 1)); if 1 { 1 } else { f(1) }"
+	if program.String() != expected {
+		t.Errorf("expected=%q. got=%q", expected, program.String())
+	}
+}
+
+func TestApplyReplaceRoot(t *testing.T) {
+	program := parse(t, "a")
+
+	result := ast.Apply(program, func(c *ast.Cursor) bool {
+		if _, ok := c.Node().(*ast.Program); ok {
+			c.Replace(parse(t, "b"))
+			return false
+		}
+		return true
+	}, nil)
+
+	if result.String() != "b" {
+		t.Errorf("expected=%q. got=%q", "b", result.String())
+	}
+}
+
+func TestApplyDelete(t *testing.T) {
+	program := parse(t, "let a = 1; b; let c = 2; let d = 3; if b { let e = 4; e }")
+
+	ast.Apply(program, func(c *ast.Cursor) bool {
+		if _, ok := c.Node().(*ast.LetStatement); ok {
+			c.Delete()
+		}
+		return true
+	}, nil)
+
+	expected := "b; if b { e }"
+	if program.String() != expected {
+		t.Errorf("expected=%q. got=%q", expected, program.String())
+	}
+}
+
+func TestApplyInsert(t *testing.T) {
+	program := parse(t, "a; b; c")
+
+	visited := []string{}
+	ast.Apply(program, func(c *ast.Cursor) bool {
+		stmt, ok := c.Node().(*ast.ExpressionStatement)
+		if !ok {
+			return true
+		}
+		name := stmt.Expression.String()
+		visited = append(visited, name)
+		switch name {
+		case "a":
+			c.InsertBefore(parse(t, "before").Statements[0])
+		case "b":
+			c.InsertAfter(parse(t, "after").Statements[0])
+		}
+		return true
+	}, nil)
+
+	expected := "before; a; b; after; c"
+	if program.String() != expected {
+		t.Errorf("expected=%q. got=%q", expected, program.String())
+	}
+	if strings.Join(visited, " ") != "a b c" {
+		t.Errorf("inserted nodes were walked: visited=%v", visited)
+	}
+}
+
+func TestApplyCursor(t *testing.T) {
+	program := parse(t, "f(x, y)")
+
+	var got []string
+	ast.Apply(program, func(c *ast.Cursor) bool {
+		if id, ok := c.Node().(*ast.Identifier); ok {
+			got = append(got, fmt.Sprintf("%s:%s[%d]", id.Value, c.Name(), c.Index()))
+			if _, ok := c.Parent().(*ast.CallExpression); !ok {
+				t.Errorf("%s: parent: expected=*ast.CallExpression. got=%T", id.Value, c.Parent())
+			}
+		}
+		return true
+	}, nil)
+
+	expected := "f:Function[-1] x:Arguments[0] y:Arguments[1]"
+	if strings.Join(got, " ") != expected {
+		t.Errorf("expected=%q. got=%q", expected, strings.Join(got, " "))
+	}
+}
+
+func TestApplyAbort(t *testing.T) {
+	program := parse(t, "a; b; c")
+
+	visited := []string{}
+	ast.Apply(program, nil, func(c *ast.Cursor) bool {
+		if id, ok := c.Node().(*ast.Identifier); ok {
+			visited = append(visited, id.Value)
+			return id.Value != "b"
+		}
+		return true
+	})
+
+	if strings.Join(visited, " ") != "a b" {
+		t.Errorf("expected=%q. got=%q", "a b", strings.Join(visited, " "))
+	}
+}
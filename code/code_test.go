@@ -0,0 +1,138 @@
+package code
+
+import (
+	"chimp/token"
+	"testing"
+)
+
+func TestMake(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+		expected []byte
+	}{
+		{OpConstant, []int{65534}, []byte{byte(OpConstant), 255, 254}},
+		{OpAdd, []int{}, []byte{byte(OpAdd)}},
+		{OpGetLocal, []int{255}, []byte{byte(OpGetLocal), 255}},
+		{OpClosure, []int{65534, 255}, []byte{byte(OpClosure), 255, 254, 255}},
+	}
+
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+
+		if len(instruction) != len(tt.expected) {
+			t.Errorf("instruction has wrong length. expected=%d, got=%d",
+				len(tt.expected), len(instruction))
+		}
+
+		for i, b := range tt.expected {
+			if instruction[i] != tt.expected[i] {
+				t.Errorf("wrong byte at pos %d. expected=%d, got=%d",
+					i, b, instruction[i])
+			}
+		}
+	}
+}
+
+func TestMakeOperandOutOfRange(t *testing.T) {
+	tests := []struct {
+		op       Opcode
+		operands []int
+	}{
+		{OpConstant, []int{65536}},
+		{OpGetLocal, []int{256}},
+		{OpClosure, []int{0, 256}},
+		{OpJump, []int{-1}},
+	}
+
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Make(%d, %v) did not panic", tt.op, tt.operands)
+				}
+			}()
+			Make(tt.op, tt.operands...)
+		}()
+	}
+}
+
+func TestInstructionsString(t *testing.T) {
+	instructions := []Instructions{
+		Make(OpAdd),
+		Make(OpGetLocal, 1),
+		Make(OpConstant, 2),
+		Make(OpConstant, 65535),
+		Make(OpClosure, 65535, 255),
+	}
+
+	expected := `0000 OpAdd
+0001 OpGetLocal 1
+0003 OpConstant 2
+0006 OpConstant 65535
+0009 OpClosure 65535 255
+`
+
+	concatted := Instructions{}
+	for _, ins := range instructions {
+		concatted = append(concatted, ins...)
+	}
+
+	if concatted.String() != expected {
+		t.Errorf("instructions wrongly formatted.\nexpected=%q\ngot=%q",
+			expected, concatted.String())
+	}
+}
+
+func TestReadOperands(t *testing.T) {
+	tests := []struct {
+		op        Opcode
+		operands  []int
+		bytesRead int
+	}{
+		{OpConstant, []int{65535}, 2},
+		{OpGetLocal, []int{255}, 1},
+		{OpClosure, []int{65535, 255}, 3},
+	}
+
+	for _, tt := range tests {
+		instruction := Make(tt.op, tt.operands...)
+
+		def, err := Lookup(byte(tt.op))
+		if err != nil {
+			t.Fatalf("definition not found: %q\n", err)
+		}
+
+		operandsRead, n := ReadOperands(def, instruction[1:])
+		if n != tt.bytesRead {
+			t.Fatalf("n wrong. expected=%d, got=%d", tt.bytesRead, n)
+		}
+
+		for i, expected := range tt.operands {
+			if operandsRead[i] != expected {
+				t.Errorf("operand wrong. expected=%d, got=%d", expected, operandsRead[i])
+			}
+		}
+	}
+}
+
+func TestPosTableLookup(t *testing.T) {
+	table := PosTable{
+		{Offset: 0, Pos: token.Position{Line: 1, Column: 1}},
+		{Offset: 4, Pos: token.Position{Line: 2, Column: 3}},
+		{Offset: 9, Pos: token.Position{Line: 3, Column: 5}},
+	}
+
+	tests := []struct {
+		offset int
+		line   int
+	}{
+		{0, 1}, {3, 1}, {4, 2}, {8, 2}, {9, 3}, {20, 3},
+	}
+
+	for _, tt := range tests {
+		if got := table.Lookup(tt.offset).Line; got != tt.line {
+			t.Errorf("Lookup(%d): expected line=%d, got=%d", tt.offset, tt.line, got)
+		}
+	}
+}
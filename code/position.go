@@ -0,0 +1,27 @@
+package code
+
+import "chimp/token"
+
+// PosEntry records that the instructions from Offset onwards were compiled
+// from the source at Pos.
+type PosEntry struct {
+	Offset int
+	Pos    token.Position
+}
+
+// PosTable maps instruction offsets back to source positions, so that
+// runtime errors can point at the code that caused them. Entries are
+// sorted by offset.
+type PosTable []PosEntry
+
+// Lookup returns the position of the instruction at offset.
+func (t PosTable) Lookup(offset int) token.Position {
+	var pos token.Position
+	for _, e := range t {
+		if e.Offset > offset {
+			break
+		}
+		pos = e.Pos
+	}
+	return pos
+}
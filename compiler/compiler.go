@@ -0,0 +1,730 @@
+package compiler
+
+import (
+	"chimp/ast"
+	"chimp/code"
+	"chimp/object"
+	"chimp/token"
+	"fmt"
+)
+
+type Compiler struct {
+	constants []object.Object
+
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	// pos is the position of the node being compiled. Every instruction
+	// is tagged with it.
+	pos token.Position
+
+	// hoisted holds the symbols defined ahead of their declarations by
+	// hoist.
+	hoisted map[*ast.Identifier]Symbol
+
+	// err is the first limit of the instruction set that the program
+	// went past. emit records it, and Compile returns it.
+	err error
+}
+
+// CompilationScope holds the instructions of one function while it is
+// being compiled.
+type CompilationScope struct {
+	instructions        code.Instructions
+	positions           code.PosTable
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+}
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+type Bytecode struct {
+	Instructions code.Instructions
+	Positions    code.PosTable
+	Constants    []object.Object
+}
+
+func New() *Compiler {
+	mainScope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+
+	symbolTable := NewSymbolTable()
+	for i, b := range object.Builtins {
+		symbolTable.DefineBuiltin(i, b.Name)
+	}
+
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: symbolTable,
+		scopes:      []CompilationScope{mainScope},
+		scopeIndex:  0,
+		hoisted:     map[*ast.Identifier]Symbol{},
+	}
+}
+
+// NewWithState returns a compiler that continues from the globals and
+// constants of an earlier one, as the REPL needs.
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	compiler := New()
+	compiler.symbolTable = s
+	compiler.constants = constants
+	return compiler
+}
+
+var binaryOps = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"**": code.OpPow,
+	"&":  code.OpBitAnd,
+	"|":  code.OpBitOr,
+	"^":  code.OpBitXor,
+	"<<": code.OpShl,
+	">>": code.OpShr,
+	"^^": code.OpBoolXor,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	"<":  code.OpLessThan,
+	"<=": code.OpLessEqual,
+	">":  code.OpGreaterThan,
+	">=": code.OpGreaterEqual,
+}
+
+var prefixOps = map[string]code.Opcode{
+	"-": code.OpMinus,
+	"!": code.OpBang,
+	"~": code.OpBitNot,
+}
+
+func (c *Compiler) Compile(node ast.Node) error {
+	outer := c.pos
+	c.pos = node.Pos()
+	defer func() { c.pos = outer }()
+
+	switch node := node.(type) {
+	case *ast.Program:
+		if err := c.compileProgram(node); err != nil {
+			return err
+		}
+
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+
+	case *ast.BlockStatement:
+		c.enterBlock()
+		defer c.leaveBlock()
+		for i, s := range node.Statements {
+			c.hoist(node.Statements, i)
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+
+	case *ast.LetStatement:
+		return c.declare(node.Name, node.Value)
+
+	case *ast.VarDecl:
+		return c.declare(node.Name, node.Value)
+
+	case *ast.ReturnStatement:
+		if node.ReturnValue == nil {
+			c.emit(code.OpNull)
+		} else if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+
+	case *ast.IntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Integer{Value: node.Value}))
+
+	case *ast.FloatLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.Float{Value: node.Value}))
+
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(&object.String{Value: node.Value}))
+
+	case *ast.InterpolatedString:
+		for _, part := range node.Parts {
+			if err := c.Compile(part); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpInterpolate, len(node.Parts))
+
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.Null:
+		c.emit(code.OpNull)
+
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		if !ok {
+			return fmt.Errorf("%s: undefined variable %s", node.Pos(), node.Value)
+		}
+		c.loadSymbol(symbol)
+
+	case *ast.AssignExpression:
+		return c.compileAssign(node)
+
+	case *ast.PrefixExpression:
+		op, ok := prefixOps[node.Operator]
+		if !ok {
+			return fmt.Errorf("%s: unknown operator %s", node.Pos(), node.Operator)
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		c.emit(op)
+
+	case *ast.InfixExpression:
+		return c.compileInfix(node)
+
+	case *ast.PostfixExpression:
+		return c.compilePostfix(node)
+
+	case *ast.IfExpression:
+		return c.compileIf(node)
+
+	case *ast.FunctionLiteral:
+		return c.compileFunction(node, "")
+
+	case *ast.CallExpression:
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+		for _, a := range node.Arguments {
+			if err := c.Compile(a); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpCall, len(node.Arguments))
+
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.HashLiteral:
+		for _, pair := range node.Pairs {
+			if err := c.Compile(pair.Key); err != nil {
+				return err
+			}
+			if err := c.Compile(pair.Value); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Pairs)*2)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+
+	case *ast.SliceExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		for _, bound := range []ast.Expression{node.Low, node.High} {
+			if bound == nil {
+				c.emit(code.OpNull)
+			} else if err := c.Compile(bound); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpSlice)
+
+	default:
+		return fmt.Errorf("%s: cannot compile %T", node.Pos(), node)
+	}
+
+	return c.err
+}
+
+// compileProgram compiles the top level like the body of a function,
+// except that the value of a final expression statement is returned as
+// the result of the program.
+func (c *Compiler) compileProgram(program *ast.Program) error {
+	for i, s := range program.Statements {
+		c.hoist(program.Statements, i)
+		if es, ok := s.(*ast.ExpressionStatement); ok && i == len(program.Statements)-1 {
+			if err := c.Compile(es.Expression); err != nil {
+				return err
+			}
+			c.pos = s.Pos()
+			c.emit(code.OpReturnValue)
+			return nil
+		}
+		if err := c.Compile(s); err != nil {
+			return err
+		}
+		c.pos = s.Pos()
+	}
+
+	c.emit(code.OpReturn)
+	return nil
+}
+
+// declare binds name to value. A function literal may call itself, so its
+// name is defined before its body is compiled; any other initializer still
+// sees the outer meaning of the name.
+func (c *Compiler) declare(name *ast.Identifier, value ast.Expression) error {
+	var symbol Symbol
+
+	if fl, ok := value.(*ast.FunctionLiteral); ok {
+		symbol, ok = c.hoisted[name]
+		if !ok {
+			symbol = c.symbolTable.Define(name.Value)
+		}
+		if err := c.compileFunction(fl, name.Value); err != nil {
+			return err
+		}
+	} else {
+		if err := c.Compile(value); err != nil {
+			return err
+		}
+		symbol = c.symbolTable.Define(name.Value)
+	}
+
+	c.storeSymbol(symbol)
+	return nil
+}
+
+// hoist defines the names of the functions declared one after another
+// from list[i] on, when list[i] starts such a run, so that they can call
+// each other. Nothing runs between their declarations, so none of them
+// can be called before all of them are defined.
+func (c *Compiler) hoist(list []ast.Statement, i int) {
+	if i > 0 && funcDeclName(list[i-1]) != nil {
+		return
+	}
+	for _, stmt := range list[i:] {
+		name := funcDeclName(stmt)
+		if name == nil {
+			return
+		}
+		c.hoisted[name] = c.symbolTable.Define(name.Value)
+	}
+}
+
+// funcDeclName returns the name stmt binds a function literal to, or nil
+// if it does not declare a function.
+func funcDeclName(stmt ast.Statement) *ast.Identifier {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		if _, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+			return stmt.Name
+		}
+	case *ast.VarDecl:
+		if _, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+			return stmt.Name
+		}
+	}
+	return nil
+}
+
+func (c *Compiler) compileAssign(node *ast.AssignExpression) error {
+	symbol, err := c.resolveTarget(node.Target)
+	if err != nil {
+		return err
+	}
+
+	if err := c.Compile(node.Value); err != nil {
+		return err
+	}
+	c.storeSymbol(symbol)
+	c.loadSymbol(symbol)
+	return nil
+}
+
+// compilePostfix compiles x++ and x--. The value of x from before is left
+// on the stack.
+func (c *Compiler) compilePostfix(node *ast.PostfixExpression) error {
+	symbol, err := c.resolveTarget(node.Left)
+	if err != nil {
+		return err
+	}
+
+	c.loadSymbol(symbol)
+	c.loadSymbol(symbol)
+	if node.Operator == "--" {
+		c.emit(code.OpDecrement)
+	} else {
+		c.emit(code.OpIncrement)
+	}
+	c.storeSymbol(symbol)
+	return nil
+}
+
+// resolveTarget returns the variable that target, the left side of an
+// assignment, names.
+func (c *Compiler) resolveTarget(target ast.Expression) (Symbol, error) {
+	ident, ok := target.(*ast.Identifier)
+	if !ok {
+		return Symbol{}, fmt.Errorf("%s: cannot assign to %s", target.Pos(), target)
+	}
+	symbol, ok := c.symbolTable.Resolve(ident.Value)
+	if !ok {
+		return Symbol{}, fmt.Errorf("%s: undefined variable %s", ident.Pos(), ident.Value)
+	}
+	if symbol.Scope == BuiltinScope {
+		return Symbol{}, fmt.Errorf("%s: cannot assign to builtin %s", ident.Pos(), ident.Value)
+	}
+	return symbol, nil
+}
+
+func (c *Compiler) compileInfix(node *ast.InfixExpression) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+
+	// The logical operators and ?? only evaluate their right operand when
+	// the left one does not settle the result.
+	switch node.Operator {
+	case "&&":
+		jumpFalse := c.emit(code.OpJumpNotTruthy, 9999)
+		if err := c.compileTruthiness(node.Right); err != nil {
+			return err
+		}
+		jumpEnd := c.emit(code.OpJump, 9999)
+		c.changeOperand(jumpFalse, len(c.currentInstructions()))
+		c.emit(code.OpFalse)
+		c.changeOperand(jumpEnd, len(c.currentInstructions()))
+		return nil
+
+	case "||":
+		jumpRight := c.emit(code.OpJumpNotTruthy, 9999)
+		c.emit(code.OpTrue)
+		jumpEnd := c.emit(code.OpJump, 9999)
+		c.changeOperand(jumpRight, len(c.currentInstructions()))
+		if err := c.compileTruthiness(node.Right); err != nil {
+			return err
+		}
+		c.changeOperand(jumpEnd, len(c.currentInstructions()))
+		return nil
+
+	case "??":
+		jumpEnd := c.emit(code.OpJumpNotNull, 9999)
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		c.changeOperand(jumpEnd, len(c.currentInstructions()))
+		return nil
+	}
+
+	op, ok := binaryOps[node.Operator]
+	if !ok {
+		return fmt.Errorf("%s: unknown operator %s", node.Pos(), node.Operator)
+	}
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+	c.emit(op)
+	return nil
+}
+
+func (c *Compiler) compileTruthiness(node ast.Expression) error {
+	if err := c.Compile(node); err != nil {
+		return err
+	}
+	c.emit(code.OpBool)
+	return nil
+}
+
+func (c *Compiler) compileIf(node *ast.IfExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.compileBlockValue(node.Consequence); err != nil {
+		return err
+	}
+
+	jumpPos := c.emit(code.OpJump, 9999)
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else if err := c.compileBlockValue(node.Alternative); err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+// compileBlockValue compiles the branch of an if expression, leaving the
+// value of its last expression statement on the stack, or null.
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	c.enterBlock()
+	defer c.leaveBlock()
+
+	for i, s := range block.Statements {
+		c.hoist(block.Statements, i)
+		es, ok := s.(*ast.ExpressionStatement)
+		if ok && i == len(block.Statements)-1 {
+			return c.Compile(es.Expression)
+		}
+		if err := c.Compile(s); err != nil {
+			return err
+		}
+	}
+
+	c.emit(code.OpNull)
+	return nil
+}
+
+// compileFunction compiles fl into a constant and emits the instructions
+// that close over its free variables. name is the name the function is
+// bound to, if any.
+func (c *Compiler) compileFunction(fl *ast.FunctionLiteral, name string) error {
+	c.enterScope()
+
+	for _, p := range fl.Parameters {
+		c.symbolTable.Define(p.Name.Value)
+	}
+
+	for i, s := range fl.Body.Statements {
+		c.hoist(fl.Body.Statements, i)
+		if err := c.Compile(s); err != nil {
+			c.leaveScope()
+			return err
+		}
+	}
+
+	// Falling off the end of a function returns null.
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.NumDefinitions()
+	instructions, positions := c.leaveScope()
+
+	for _, s := range freeSymbols {
+		switch s.Scope {
+		case LocalScope:
+			c.emit(code.OpCaptureLocal, s.Index)
+		case FreeScope:
+			c.emit(code.OpCaptureFree, s.Index)
+		}
+	}
+
+	compiledFn := &object.CompiledFunction{
+		Instructions:  instructions,
+		Positions:     positions,
+		NumLocals:     numLocals,
+		NumParameters: len(fl.Parameters),
+		Name:          name,
+	}
+
+	fnIndex := c.addConstant(compiledFn)
+	c.emit(code.OpClosure, fnIndex, len(freeSymbols))
+
+	return nil
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, s.Index)
+	}
+}
+
+func (c *Compiler) storeSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpSetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpSetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpSetFree, s.Index)
+	}
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	def, _ := code.Lookup(byte(op))
+	for i, o := range operands {
+		if !code.Fits(def.OperandWidths[i], o) {
+			c.fail(op, i)
+			operands[i] = 0
+		}
+	}
+
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+
+	c.setLastInstruction(op, pos)
+
+	return pos
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	scope := &c.scopes[c.scopeIndex]
+	posNewInstruction := len(scope.instructions)
+
+	if n := len(scope.positions); n == 0 || scope.positions[n-1].Pos != c.pos {
+		scope.positions = append(scope.positions, code.PosEntry{Offset: posNewInstruction, Pos: c.pos})
+	}
+	scope.instructions = append(scope.instructions, ins...)
+
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	def, _ := code.Lookup(byte(op))
+	if !code.Fits(def.OperandWidths[0], operand) {
+		c.fail(op, 0)
+		return
+	}
+	newInstruction := code.Make(op, operand)
+
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+// fail records that operand i of op went past the limit its width sets.
+func (c *Compiler) fail(op code.Opcode, i int) {
+	if c.err != nil {
+		return
+	}
+
+	var limit string
+	switch op {
+	case code.OpGetLocal, code.OpSetLocal, code.OpCaptureLocal:
+		limit = "too many local variables"
+	case code.OpGetGlobal, code.OpSetGlobal:
+		limit = "too many globals"
+	case code.OpGetFree, code.OpSetFree, code.OpCaptureFree:
+		limit = "too many free variables"
+	case code.OpConstant:
+		limit = "too many constants"
+	case code.OpClosure:
+		if i == 0 {
+			limit = "too many constants"
+		} else {
+			limit = "too many free variables"
+		}
+	case code.OpJump, code.OpJumpNotTruthy, code.OpJumpNotNull:
+		limit = "function too large"
+	case code.OpCall:
+		limit = "too many arguments"
+	case code.OpArray, code.OpHash:
+		limit = "collection literal too large"
+	case code.OpInterpolate:
+		limit = "interpolated string too large"
+	default:
+		def, _ := code.Lookup(byte(op))
+		limit = "operand of " + def.Name + " out of range"
+	}
+	c.err = fmt.Errorf("%s: %s", c.pos, limit)
+}
+
+func (c *Compiler) enterScope() {
+	scope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+	c.scopes = append(c.scopes, scope)
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() (code.Instructions, code.PosTable) {
+	scope := c.scopes[c.scopeIndex]
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return scope.instructions, scope.positions
+}
+
+func (c *Compiler) enterBlock() {
+	c.symbolTable = NewBlockSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveBlock() {
+	c.symbolTable = c.symbolTable.Outer
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Positions:    c.scopes[c.scopeIndex].positions,
+		Constants:    c.constants,
+	}
+}
+
+// SymbolTable returns the compiler's global symbol table, to be handed to
+// NewWithState.
+func (c *Compiler) SymbolTable() *SymbolTable {
+	return c.symbolTable
+}
@@ -0,0 +1,125 @@
+package compiler
+
+import (
+	"chimp/code"
+	"chimp/object"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Disassemble prints a listing of bytecode to w: every instruction with
+// its offset and operands, the value of each constant it refers to, and
+// the source line it was compiled from, taken from source if it is not
+// empty. The functions in the constant pool follow the code that creates
+// them.
+func Disassemble(w io.Writer, bytecode *Bytecode, source string) {
+	d := &disassembler{w: w, constants: bytecode.Constants}
+	if source != "" {
+		d.lines = strings.Split(source, "\n")
+	}
+
+	main := &object.CompiledFunction{Instructions: bytecode.Instructions, Positions: bytecode.Positions}
+	d.function("main", main)
+}
+
+type disassembler struct {
+	w         io.Writer
+	constants []object.Object
+	lines     []string
+}
+
+func (d *disassembler) function(title string, fn *object.CompiledFunction) {
+	fmt.Fprintf(d.w, "== %s ==\n", title)
+	if title != "main" {
+		fmt.Fprintf(d.w, "params: %d, locals: %d\n", fn.NumParameters, fn.NumLocals)
+	}
+
+	var nested []int
+	line := 0
+
+	ins := fn.Instructions
+	for i := 0; i < len(ins); {
+		def, err := code.Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(d.w, "%04d ERROR: %s\n", i, err)
+			i++
+			continue
+		}
+
+		if pos := fn.Positions.Lookup(i); pos.Line != line {
+			line = pos.Line
+			d.sourceLine(line)
+		}
+
+		operands, read := code.ReadOperands(def, ins[i+1:])
+
+		var text strings.Builder
+		text.WriteString(def.Name)
+		for _, o := range operands {
+			text.WriteString(" " + strconv.Itoa(o))
+		}
+
+		comment := ""
+		switch code.Opcode(ins[i]) {
+		case code.OpConstant:
+			comment = d.describe(operands[0])
+		case code.OpClosure:
+			comment = d.describe(operands[0])
+			nested = append(nested, operands[0])
+		case code.OpGetBuiltin:
+			if operands[0] < len(object.Builtins) {
+				comment = object.Builtins[operands[0]].Name
+			}
+		}
+
+		if comment != "" {
+			fmt.Fprintf(d.w, "%04d %-24s ; %s\n", i, text.String(), comment)
+		} else {
+			fmt.Fprintf(d.w, "%04d %s\n", i, text.String())
+		}
+
+		i += 1 + read
+	}
+
+	for _, index := range nested {
+		fn, ok := d.constants[index].(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+		fmt.Fprintln(d.w)
+		d.function(fmt.Sprintf("%s (constant %d)", functionName(fn), index), fn)
+	}
+}
+
+func (d *disassembler) sourceLine(line int) {
+	if line < 1 || line > len(d.lines) {
+		fmt.Fprintf(d.w, "     ; line %d\n", line)
+		return
+	}
+	fmt.Fprintf(d.w, "     ; %d | %s\n", line, strings.TrimSpace(d.lines[line-1]))
+}
+
+// describe shows the constant at index the way it would appear in source.
+func (d *disassembler) describe(index int) string {
+	if index < 0 || index >= len(d.constants) {
+		return "<invalid constant>"
+	}
+
+	switch c := d.constants[index].(type) {
+	case *object.String:
+		return strconv.Quote(c.Value)
+	case *object.CompiledFunction:
+		return functionName(c)
+	default:
+		return c.Inspect()
+	}
+}
+
+func functionName(fn *object.CompiledFunction) string {
+	if fn.Name == "" {
+		return "fn <anonymous>"
+	}
+	return "fn " + fn.Name
+}
@@ -0,0 +1,61 @@
+package compiler
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDisassemble(t *testing.T) {
+	input := `let greeting = "hi";
+let add = fn(a) {
+    return fn(b) { return a + b + 1; };
+};
+add(2)(len(greeting));`
+
+	compiler := New()
+	if err := compiler.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	var out bytes.Buffer
+	Disassemble(&out, compiler.Bytecode(), input)
+
+	expected := `== main ==
+     ; 1 | let greeting = "hi";
+0000 OpConstant 0             ; "hi"
+0003 OpSetGlobal 0
+     ; 2 | let add = fn(a) {
+0006 OpClosure 3 0            ; fn add
+0010 OpSetGlobal 1
+     ; 5 | add(2)(len(greeting));
+0013 OpGetGlobal 1
+0016 OpConstant 4             ; 2
+0019 OpCall 1
+0021 OpGetBuiltin 0           ; len
+0023 OpGetGlobal 0
+0026 OpCall 1
+0028 OpCall 1
+0030 OpReturnValue
+
+== fn add (constant 3) ==
+params: 1, locals: 1
+     ; 3 | return fn(b) { return a + b + 1; };
+0000 OpCaptureLocal 0
+0002 OpClosure 2 1            ; fn <anonymous>
+0006 OpReturnValue
+
+== fn <anonymous> (constant 2) ==
+params: 1, locals: 1
+     ; 3 | return fn(b) { return a + b + 1; };
+0000 OpGetFree 0
+0002 OpGetLocal 0
+0004 OpAdd
+0005 OpConstant 1             ; 1
+0008 OpAdd
+0009 OpReturnValue
+`
+
+	if out.String() != expected {
+		t.Errorf("wrong disassembly.\nexpected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
@@ -0,0 +1,450 @@
+package compiler
+
+import (
+	"bufio"
+	"bytes"
+	"chimp/code"
+	"chimp/object"
+	"chimp/token"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// A .chpc object file holds a compiled program:
+//
+//	magic          "CHPC"
+//	version        uint16, big endian
+//	length         uint32, big endian, the length of the rest of the file
+//	checksum       uint32, big endian, the CRC-32 of the rest of the file
+//	filename       string, the name positions refer to
+//	source path    string, the source file relative to the object file
+//	source hash    32 bytes, the SHA-256 of the source
+//	constants      uvarint count, then one tagged constant each
+//	main program   instructions and line table
+//
+// Strings and byte slices are written as a uvarint length followed by the
+// bytes. A line table is a uvarint count followed by the instruction
+// offset, line, column and byte offset of each entry, all as uvarints.
+const FormatVersion = 5
+
+var objMagic = []byte("CHPC")
+
+// MagicLen is the number of leading bytes IsObjectFile looks at.
+const MagicLen = len("CHPC")
+
+const (
+	constInteger byte = iota + 1
+	constFloat
+	constString
+	constFunction
+)
+
+// ObjectFile is the decoded contents of a .chpc file.
+type ObjectFile struct {
+	Filename   string
+	SourcePath string
+	SourceHash [sha256.Size]byte
+	Bytecode   *Bytecode
+}
+
+// IsObjectFile reports whether data starts like an object file.
+func IsObjectFile(data []byte) bool {
+	return bytes.HasPrefix(data, objMagic)
+}
+
+func HashSource(src []byte) [sha256.Size]byte {
+	return sha256.Sum256(src)
+}
+
+func WriteObjectFile(w io.Writer, f *ObjectFile) error {
+	var payload bytes.Buffer
+	ow := &objWriter{w: bufio.NewWriter(&payload)}
+
+	ow.string(f.Filename)
+	ow.string(f.SourcePath)
+	ow.bytes(f.SourceHash[:])
+
+	ow.uvarint(uint64(len(f.Bytecode.Constants)))
+	for _, c := range f.Bytecode.Constants {
+		ow.constant(c)
+	}
+
+	ow.instructions(f.Bytecode.Instructions)
+	ow.positions(f.Bytecode.Positions)
+
+	if ow.err != nil {
+		return ow.err
+	}
+	if err := ow.w.Flush(); err != nil {
+		return err
+	}
+
+	header := append([]byte{}, objMagic...)
+	header = binary.BigEndian.AppendUint16(header, FormatVersion)
+	header = binary.BigEndian.AppendUint32(header, uint32(payload.Len()))
+	header = binary.BigEndian.AppendUint32(header, crc32.ChecksumIEEE(payload.Bytes()))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(payload.Bytes())
+	return err
+}
+
+type objWriter struct {
+	w   *bufio.Writer
+	err error
+}
+
+func (ow *objWriter) bytes(b []byte) {
+	if ow.err == nil {
+		_, ow.err = ow.w.Write(b)
+	}
+}
+
+func (ow *objWriter) uint16(n uint16) {
+	ow.bytes(binary.BigEndian.AppendUint16(nil, n))
+}
+
+func (ow *objWriter) uvarint(n uint64) {
+	ow.bytes(binary.AppendUvarint(nil, n))
+}
+
+func (ow *objWriter) varint(n int64) {
+	ow.bytes(binary.AppendVarint(nil, n))
+}
+
+func (ow *objWriter) string(s string) {
+	ow.uvarint(uint64(len(s)))
+	ow.bytes([]byte(s))
+}
+
+func (ow *objWriter) instructions(ins code.Instructions) {
+	ow.uvarint(uint64(len(ins)))
+	ow.bytes(ins)
+}
+
+func (ow *objWriter) positions(table code.PosTable) {
+	ow.uvarint(uint64(len(table)))
+	for _, e := range table {
+		ow.uvarint(uint64(e.Offset))
+		ow.uvarint(uint64(e.Pos.Line))
+		ow.uvarint(uint64(e.Pos.Column))
+		ow.uvarint(uint64(e.Pos.Offset))
+	}
+}
+
+func (ow *objWriter) constant(obj object.Object) {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		ow.bytes([]byte{constInteger})
+		ow.varint(obj.Value)
+	case *object.Float:
+		ow.bytes([]byte{constFloat})
+		ow.bytes(binary.BigEndian.AppendUint64(nil, math.Float64bits(obj.Value)))
+	case *object.String:
+		ow.bytes([]byte{constString})
+		ow.string(obj.Value)
+	case *object.CompiledFunction:
+		ow.bytes([]byte{constFunction})
+		ow.string(obj.Name)
+		ow.uvarint(uint64(obj.NumLocals))
+		ow.uvarint(uint64(obj.NumParameters))
+		ow.instructions(obj.Instructions)
+		ow.positions(obj.Positions)
+	default:
+		if ow.err == nil {
+			ow.err = fmt.Errorf("cannot serialize constant of type %s", obj.Type())
+		}
+	}
+}
+
+var (
+	errTruncated = errors.New("object file is truncated")
+	errCorrupt   = errors.New("object file is corrupt")
+)
+
+// ReadObjectFile decodes an object file. It rejects files that were not
+// written by a compatible version of Chimp.
+func ReadObjectFile(r io.Reader) (*ObjectFile, error) {
+	or := &objReader{r: bufio.NewReader(r)}
+
+	magic := or.bytes(len(objMagic))
+	if or.err != nil || !bytes.Equal(magic, objMagic) {
+		return nil, errors.New("not a Chimp object file")
+	}
+	if version := or.uint16(); or.err == nil && version != FormatVersion {
+		return nil, fmt.Errorf("object file has format version %d, but this chimp reads version %d; rebuild it",
+			version, FormatVersion)
+	}
+
+	// The rest of the file is checked against its checksum before any of
+	// it is decoded.
+	length := or.uint32()
+	sum := or.uint32()
+	if or.err != nil {
+		return nil, or.err
+	}
+	payload, err := io.ReadAll(io.LimitReader(or.r, int64(length)))
+	if err != nil || len(payload) != int(length) {
+		return nil, errTruncated
+	}
+	if crc32.ChecksumIEEE(payload) != sum {
+		return nil, errCorrupt
+	}
+	or.r = bufio.NewReader(bytes.NewReader(payload))
+
+	f := &ObjectFile{Bytecode: &Bytecode{}}
+	f.Filename = or.string()
+	f.SourcePath = or.string()
+	copy(f.SourceHash[:], or.bytes(sha256.Size))
+
+	n := or.count()
+	for i := 0; i < n && or.err == nil; i++ {
+		f.Bytecode.Constants = append(f.Bytecode.Constants, or.constant(f.Filename))
+	}
+
+	f.Bytecode.Instructions = or.instructions()
+	f.Bytecode.Positions = or.positions(f.Filename)
+
+	if or.err != nil {
+		return nil, or.err
+	}
+	if !verify(f.Bytecode) {
+		return nil, errCorrupt
+	}
+	return f, nil
+}
+
+type objReader struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (or *objReader) fail(err error) {
+	if or.err == nil {
+		or.err = err
+	}
+}
+
+func (or *objReader) bytes(n int) []byte {
+	if or.err != nil {
+		return nil
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(or.r, b); err != nil {
+		or.fail(errTruncated)
+		return nil
+	}
+	return b
+}
+
+func (or *objReader) byte() byte {
+	if b := or.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (or *objReader) uint16() uint16 {
+	if b := or.bytes(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (or *objReader) uint32() uint32 {
+	if b := or.bytes(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (or *objReader) uvarint() uint64 {
+	if or.err != nil {
+		return 0
+	}
+	n, err := binary.ReadUvarint(or.r)
+	if err != nil {
+		or.fail(errTruncated)
+	}
+	return n
+}
+
+func (or *objReader) varint() int64 {
+	if or.err != nil {
+		return 0
+	}
+	n, err := binary.ReadVarint(or.r)
+	if err != nil {
+		or.fail(errTruncated)
+	}
+	return n
+}
+
+// count reads a length, refusing ones too large to be genuine.
+func (or *objReader) count() int {
+	n := or.uvarint()
+	if n > math.MaxInt32 {
+		or.fail(errCorrupt)
+		return 0
+	}
+	return int(n)
+}
+
+func (or *objReader) string() string {
+	return string(or.bytes(or.count()))
+}
+
+func (or *objReader) instructions() code.Instructions {
+	ins := code.Instructions(or.bytes(or.count()))
+
+	// Opcodes that this chimp does not know mean the file came from a
+	// different instruction set.
+	for i := 0; i < len(ins) && or.err == nil; {
+		def, err := code.Lookup(ins[i])
+		if err != nil {
+			or.fail(fmt.Errorf("object file is incompatible: %s", err))
+			break
+		}
+		i++
+		for _, w := range def.OperandWidths {
+			i += w
+		}
+		if i > len(ins) {
+			or.fail(errCorrupt)
+		}
+	}
+
+	return ins
+}
+
+func (or *objReader) positions(filename string) code.PosTable {
+	n := or.count()
+	table := code.PosTable{}
+	for i := 0; i < n && or.err == nil; i++ {
+		var e code.PosEntry
+		e.Offset = int(or.uvarint())
+		e.Pos = token.Position{Filename: filename}
+		e.Pos.Line = int(or.uvarint())
+		e.Pos.Column = int(or.uvarint())
+		e.Pos.Offset = int(or.uvarint())
+		table = append(table, e)
+	}
+	return table
+}
+
+func (or *objReader) constant(filename string) object.Object {
+	switch tag := or.byte(); tag {
+	case constInteger:
+		return &object.Integer{Value: or.varint()}
+	case constFloat:
+		var bits uint64
+		if b := or.bytes(8); b != nil {
+			bits = binary.BigEndian.Uint64(b)
+		}
+		return &object.Float{Value: math.Float64frombits(bits)}
+	case constString:
+		return &object.String{Value: or.string()}
+	case constFunction:
+		fn := &object.CompiledFunction{}
+		fn.Name = or.string()
+		fn.NumLocals = or.count()
+		fn.NumParameters = or.count()
+		fn.Instructions = or.instructions()
+		fn.Positions = or.positions(filename)
+		return fn
+	default:
+		or.fail(fmt.Errorf("object file is corrupt: unknown constant tag %d", tag))
+		return nil
+	}
+}
+
+// verify checks that every operand in bc refers to something that exists:
+// a constant, a local or free variable, a builtin, or the start of an
+// instruction. The VM trusts the operands it runs.
+func verify(bc *Bytecode) bool {
+	// Each function needs as many free variables as the highest index it
+	// uses, and each closure made of it must capture that many.
+	needFree := map[*object.CompiledFunction]int{}
+	type closure struct {
+		fn      *object.CompiledFunction
+		numFree int
+	}
+	var closures []closure
+
+	check := func(ins code.Instructions, numLocals int, fn *object.CompiledFunction) bool {
+		starts := map[int]bool{len(ins): true}
+		for i := 0; i < len(ins); {
+			starts[i] = true
+			def, _ := code.Lookup(ins[i])
+			_, n := code.ReadOperands(def, ins[i+1:])
+			i += 1 + n
+		}
+
+		for i := 0; i < len(ins); {
+			op := code.Opcode(ins[i])
+			def, _ := code.Lookup(ins[i])
+			operands, n := code.ReadOperands(def, ins[i+1:])
+			i += 1 + n
+
+			switch op {
+			case code.OpConstant:
+				if operands[0] >= len(bc.Constants) {
+					return false
+				}
+			case code.OpClosure:
+				if operands[0] >= len(bc.Constants) {
+					return false
+				}
+				inner, ok := bc.Constants[operands[0]].(*object.CompiledFunction)
+				if !ok {
+					return false
+				}
+				closures = append(closures, closure{inner, operands[1]})
+			case code.OpJump, code.OpJumpNotTruthy, code.OpJumpNotNull:
+				if !starts[operands[0]] {
+					return false
+				}
+			case code.OpGetLocal, code.OpSetLocal, code.OpCaptureLocal:
+				if operands[0] >= numLocals {
+					return false
+				}
+			case code.OpGetFree, code.OpSetFree, code.OpCaptureFree:
+				if fn == nil {
+					return false
+				}
+				if operands[0] >= needFree[fn] {
+					needFree[fn] = operands[0] + 1
+				}
+			case code.OpGetBuiltin:
+				if operands[0] >= len(object.Builtins) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+
+	for _, c := range bc.Constants {
+		if fn, ok := c.(*object.CompiledFunction); ok {
+			if fn.NumParameters > fn.NumLocals || !check(fn.Instructions, fn.NumLocals, fn) {
+				return false
+			}
+		}
+	}
+	if !check(bc.Instructions, 0, nil) {
+		return false
+	}
+
+	for _, cl := range closures {
+		if cl.numFree < needFree[cl.fn] {
+			return false
+		}
+	}
+	return true
+}
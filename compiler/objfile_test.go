@@ -0,0 +1,198 @@
+package compiler
+
+import (
+	"bytes"
+	"chimp/code"
+	"chimp/object"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func compileObjectFile(t *testing.T, input string) *ObjectFile {
+	t.Helper()
+
+	compiler := New()
+	if err := compiler.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	return &ObjectFile{
+		Filename:   "comptest",
+		SourcePath: "comptest.chp",
+		SourceHash: HashSource([]byte(input)),
+		Bytecode:   compiler.Bytecode(),
+	}
+}
+
+func TestObjectFileRoundTrip(t *testing.T) {
+	input := `
+let scale = 2.5;
+let greet = fn(string name) { return "hi ${name}"; };
+let add = fn(int a) { return fn(int b) { return a + b - 100; }; };
+add(1)(2);`
+	f := compileObjectFile(t, input)
+
+	var buf bytes.Buffer
+	if err := WriteObjectFile(&buf, f); err != nil {
+		t.Fatalf("WriteObjectFile: %s", err)
+	}
+	if !IsObjectFile(buf.Bytes()) {
+		t.Fatalf("IsObjectFile: expected=true. got=false")
+	}
+
+	got, err := ReadObjectFile(&buf)
+	if err != nil {
+		t.Fatalf("ReadObjectFile: %s", err)
+	}
+
+	if got.Filename != f.Filename || got.SourcePath != f.SourcePath || got.SourceHash != f.SourceHash {
+		t.Errorf("header: expected=%q %q %x. got=%q %q %x", f.Filename, f.SourcePath, f.SourceHash,
+			got.Filename, got.SourcePath, got.SourceHash)
+	}
+	if !reflect.DeepEqual(got.Bytecode.Instructions, f.Bytecode.Instructions) {
+		t.Errorf("instructions: expected=%q. got=%q", f.Bytecode.Instructions, got.Bytecode.Instructions)
+	}
+	if !reflect.DeepEqual(got.Bytecode.Positions, f.Bytecode.Positions) {
+		t.Errorf("positions: expected=%v. got=%v", f.Bytecode.Positions, got.Bytecode.Positions)
+	}
+	if len(got.Bytecode.Constants) != len(f.Bytecode.Constants) {
+		t.Fatalf("constants: expected %d. got=%d", len(f.Bytecode.Constants), len(got.Bytecode.Constants))
+	}
+	for i, c := range f.Bytecode.Constants {
+		if fn, ok := c.(*object.CompiledFunction); ok {
+			if !reflect.DeepEqual(fn, got.Bytecode.Constants[i]) {
+				t.Errorf("constant %d: expected=%+v. got=%+v", i, fn, got.Bytecode.Constants[i])
+			}
+			continue
+		}
+		if c.Inspect() != got.Bytecode.Constants[i].Inspect() || c.Type() != got.Bytecode.Constants[i].Type() {
+			t.Errorf("constant %d: expected=%s. got=%s", i, c.Inspect(), got.Bytecode.Constants[i].Inspect())
+		}
+	}
+}
+
+func TestObjectFileErrors(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteObjectFile(&buf, compileObjectFile(t, "let f = fn(x) { return x; }; f(1);")); err != nil {
+		t.Fatalf("WriteObjectFile: %s", err)
+	}
+	valid := append([]byte{}, buf.Bytes()...)
+
+	future := append([]byte{}, valid...)
+	future[len(objMagic)+1] = FormatVersion + 1
+
+	buf.Reset()
+	bad := &ObjectFile{Bytecode: &Bytecode{Instructions: code.Instructions{0xff}}}
+	if err := WriteObjectFile(&buf, bad); err != nil {
+		t.Fatalf("WriteObjectFile: %s", err)
+	}
+	unknownOp := buf.Bytes()
+
+	tests := []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{"source file", []byte("let x = 1;"), "not a Chimp object file"},
+		{"empty", []byte{}, "not a Chimp object file"},
+		{"future version", future, fmt.Sprintf("object file has format version %d, but this chimp reads version %d; rebuild it",
+			FormatVersion+1, FormatVersion)},
+		{"truncated", valid[:len(valid)-5], "object file is truncated"},
+		{"unknown opcode", unknownOp, "object file is incompatible: opcode 255 undefined"},
+	}
+
+	for _, tt := range tests {
+		_, err := ReadObjectFile(bytes.NewReader(tt.data))
+		if err == nil {
+			t.Errorf("%s: expected error %q", tt.name, tt.expected)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("%s: expected=%q. got=%q", tt.name, tt.expected, err.Error())
+		}
+	}
+}
+
+func TestObjectFileCorrupted(t *testing.T) {
+	var buf bytes.Buffer
+	input := "let f = fn(x) { return fn(y) { return x + y; }; }; f(1)(2);"
+	if err := WriteObjectFile(&buf, compileObjectFile(t, input)); err != nil {
+		t.Fatalf("WriteObjectFile: %s", err)
+	}
+	valid := buf.Bytes()
+
+	// Everything after the version is covered by the length or checksum.
+	for i := len(objMagic) + 2; i < len(valid); i++ {
+		data := append([]byte{}, valid...)
+		data[i] ^= 0x5a
+		if _, err := ReadObjectFile(bytes.NewReader(data)); err == nil {
+			t.Errorf("byte %d flipped: expected an error", i)
+		}
+	}
+}
+
+func TestObjectFileBadOperands(t *testing.T) {
+	fn := &object.CompiledFunction{
+		Instructions: concatInstructions([]code.Instructions{
+			code.Make(code.OpGetFree, 1),
+			code.Make(code.OpReturnValue),
+		}),
+		NumLocals: 1,
+	}
+
+	tests := []struct {
+		name      string
+		ins       []code.Instructions
+		constants []object.Object
+	}{
+		{"constant index", []code.Instructions{code.Make(code.OpConstant, 1)}, []object.Object{&object.Integer{Value: 1}}},
+		{"closure of a non-function", []code.Instructions{code.Make(code.OpClosure, 0, 0)}, []object.Object{&object.Integer{Value: 1}}},
+		{"too few free variables", []code.Instructions{code.Make(code.OpClosure, 0, 1)}, []object.Object{fn}},
+		{"jump target", []code.Instructions{code.Make(code.OpJump, 2), code.Make(code.OpNull)}, nil},
+		{"local in main", []code.Instructions{code.Make(code.OpGetLocal, 0)}, nil},
+		{"builtin index", []code.Instructions{code.Make(code.OpGetBuiltin, 255)}, nil},
+	}
+
+	for _, tt := range tests {
+		f := &ObjectFile{Bytecode: &Bytecode{
+			Instructions: concatInstructions(tt.ins),
+			Constants:    tt.constants,
+		}}
+
+		var buf bytes.Buffer
+		if err := WriteObjectFile(&buf, f); err != nil {
+			t.Fatalf("%s: WriteObjectFile: %s", tt.name, err)
+		}
+		_, err := ReadObjectFile(&buf)
+		if err == nil || err.Error() != "object file is corrupt" {
+			t.Errorf("%s: expected=%q. got=%v", tt.name, "object file is corrupt", err)
+		}
+	}
+}
+
+func TestObjectFileKeepsPositions(t *testing.T) {
+	f := compileObjectFile(t, "let a = 1;\nlet b = a / 0;")
+
+	var buf bytes.Buffer
+	if err := WriteObjectFile(&buf, f); err != nil {
+		t.Fatalf("WriteObjectFile: %s", err)
+	}
+	got, err := ReadObjectFile(&buf)
+	if err != nil {
+		t.Fatalf("ReadObjectFile: %s", err)
+	}
+
+	ins := got.Bytecode.Instructions.String()
+	if !strings.Contains(ins, "OpDiv") {
+		t.Fatalf("expected an OpDiv. got=%s", ins)
+	}
+	div := 6 + 3 + 3
+	if code.Opcode(got.Bytecode.Instructions[div]) != code.OpDiv {
+		t.Fatalf("expected OpDiv at %d", div)
+	}
+	if pos := got.Bytecode.Positions.Lookup(div); pos.String() != "comptest:2:9" {
+		t.Errorf("position of OpDiv: expected=comptest:2:9. got=%s", pos)
+	}
+}
@@ -0,0 +1,134 @@
+package compiler
+
+import "testing"
+
+func TestDefine(t *testing.T) {
+	expected := map[string]Symbol{
+		"a": {Name: "a", Scope: GlobalScope, Index: 0},
+		"b": {Name: "b", Scope: GlobalScope, Index: 1},
+		"c": {Name: "c", Scope: LocalScope, Index: 0},
+		"d": {Name: "d", Scope: LocalScope, Index: 1},
+		"e": {Name: "e", Scope: LocalScope, Index: 0},
+		"f": {Name: "f", Scope: LocalScope, Index: 1},
+	}
+
+	global := NewSymbolTable()
+	for _, name := range []string{"a", "b"} {
+		if sym := global.Define(name); sym != expected[name] {
+			t.Errorf("expected %s=%+v, got=%+v", name, expected[name], sym)
+		}
+	}
+
+	firstLocal := NewEnclosedSymbolTable(global)
+	for _, name := range []string{"c", "d"} {
+		if sym := firstLocal.Define(name); sym != expected[name] {
+			t.Errorf("expected %s=%+v, got=%+v", name, expected[name], sym)
+		}
+	}
+
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+	for _, name := range []string{"e", "f"} {
+		if sym := secondLocal.Define(name); sym != expected[name] {
+			t.Errorf("expected %s=%+v, got=%+v", name, expected[name], sym)
+		}
+	}
+}
+
+func TestBlockScopes(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	block := NewBlockSymbolTable(global)
+	if sym := block.Define("a"); sym != (Symbol{Name: "a", Scope: GlobalScope, Index: 1}) {
+		t.Errorf("block in global scope: got=%+v", sym)
+	}
+	if global.NumDefinitions() != 2 {
+		t.Errorf("global definitions: expected=2, got=%d", global.NumDefinitions())
+	}
+
+	local := NewEnclosedSymbolTable(global)
+	local.Define("x")
+	inner := NewBlockSymbolTable(NewBlockSymbolTable(local))
+	if sym := inner.Define("y"); sym != (Symbol{Name: "y", Scope: LocalScope, Index: 1}) {
+		t.Errorf("nested block: got=%+v", sym)
+	}
+	if sym, _ := inner.Resolve("x"); sym != (Symbol{Name: "x", Scope: LocalScope, Index: 0}) {
+		t.Errorf("resolving through blocks: got=%+v", sym)
+	}
+	if local.NumDefinitions() != 2 {
+		t.Errorf("local definitions: expected=2, got=%d", local.NumDefinitions())
+	}
+}
+
+func TestResolveFree(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	firstLocal := NewEnclosedSymbolTable(global)
+	firstLocal.Define("c")
+
+	secondLocal := NewEnclosedSymbolTable(NewBlockSymbolTable(firstLocal))
+	secondLocal.Define("e")
+
+	tests := []struct {
+		name     string
+		expected Symbol
+	}{
+		{"a", Symbol{Name: "a", Scope: GlobalScope, Index: 0}},
+		{"c", Symbol{Name: "c", Scope: FreeScope, Index: 0}},
+		{"e", Symbol{Name: "e", Scope: LocalScope, Index: 0}},
+	}
+
+	for _, tt := range tests {
+		result, ok := secondLocal.Resolve(tt.name)
+		if !ok {
+			t.Errorf("name %s not resolvable", tt.name)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", tt.name, tt.expected, result)
+		}
+	}
+
+	if len(secondLocal.FreeSymbols) != 1 || secondLocal.FreeSymbols[0].Name != "c" ||
+		secondLocal.FreeSymbols[0].Scope != LocalScope {
+		t.Errorf("wrong free symbols: %+v", secondLocal.FreeSymbols)
+	}
+
+	if _, ok := secondLocal.Resolve("z"); ok {
+		t.Errorf("name z resolved, but was expected not to")
+	}
+}
+
+func TestDefineResolveBuiltins(t *testing.T) {
+	global := NewSymbolTable()
+	firstLocal := NewEnclosedSymbolTable(global)
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+
+	expected := []Symbol{
+		{Name: "a", Scope: BuiltinScope, Index: 0},
+		{Name: "c", Scope: BuiltinScope, Index: 1},
+		{Name: "e", Scope: BuiltinScope, Index: 2},
+	}
+
+	for i, v := range expected {
+		global.DefineBuiltin(i, v.Name)
+	}
+
+	for _, table := range []*SymbolTable{global, firstLocal, secondLocal} {
+		for _, sym := range expected {
+			result, ok := table.Resolve(sym.Name)
+			if !ok {
+				t.Errorf("name %s not resolvable", sym.Name)
+				continue
+			}
+			if result != sym {
+				t.Errorf("expected %s to resolve to %+v, got=%+v", sym.Name, sym, result)
+			}
+		}
+	}
+
+	if len(secondLocal.FreeSymbols) != 0 {
+		t.Errorf("builtins became free symbols: %+v", secondLocal.FreeSymbols)
+	}
+}
@@ -0,0 +1,40 @@
+package diag
+
+// Code identifies a kind of diagnostic. Codes are stable: once published a
+// code keeps its meaning, so tools and tests can match on it. The letter
+// names the phase that reports it.
+type Code string
+
+// Lexer
+const (
+	UnterminatedComment  Code = "L0001"
+	UnterminatedString   Code = "L0002"
+	UnknownEscape        Code = "L0003"
+	InvalidUnicodeEscape Code = "L0004"
+	MalformedNumber      Code = "L0005"
+	InvalidUTF8          Code = "L0006"
+)
+
+// Parser
+const (
+	UnexpectedToken    Code = "P0001"
+	ExpectedExpression Code = "P0002"
+	InvalidNumber      Code = "P0003"
+	InvalidAssignment  Code = "P0004"
+)
+
+// Type checker
+const (
+	TypeMismatch     Code = "T0001"
+	Undefined        Code = "T0002"
+	InvalidOperation Code = "T0003"
+	WrongArgCount    Code = "T0004"
+	NotCallable      Code = "T0005"
+	ReturnMismatch   Code = "T0006"
+	MissingReturn    Code = "T0007"
+	UnknownType      Code = "T0008"
+	CannotInfer      Code = "T0009"
+	UnsupportedType  Code = "T0010"
+	InvalidMapKey    Code = "T0011"
+	InvalidIndex     Code = "T0012"
+)
@@ -0,0 +1,157 @@
+// Package diag defines the diagnostics reported by the Chimp front end.
+package diag
+
+import (
+	"chimp/token"
+	"fmt"
+	"sort"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+	Info
+)
+
+var severityNames = [...]string{
+	Error:   "error",
+	Warning: "warning",
+	Info:    "info",
+}
+
+func (s Severity) String() string {
+	if s >= 0 && int(s) < len(severityNames) {
+		return severityNames[s]
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Span is the half-open range of source [Start, End).
+type Span struct {
+	Start token.Position
+	End   token.Position
+}
+
+// Ranged is anything with a source range, such as an ast.Node.
+type Ranged interface {
+	Pos() token.Position
+	End() token.Position
+}
+
+func SpanOf(r Ranged) Span {
+	return Span{Start: r.Pos(), End: r.End()}
+}
+
+func TokenSpan(tok token.Token) Span {
+	return Span{Start: tok.Pos, End: tok.End}
+}
+
+// At returns the empty span at pos, for diagnostics about something that is
+// missing rather than something that is wrong.
+func At(pos token.Position) Span {
+	return Span{Start: pos, End: pos}
+}
+
+// Note is a secondary message attached to a diagnostic, optionally pointing
+// at another part of the source.
+type Note struct {
+	Span    Span
+	Message string
+}
+
+// Fix is a suggested edit: replace the source in Span with Replacement.
+type Fix struct {
+	Span        Span
+	Replacement string
+	Message     string
+}
+
+type Diagnostic struct {
+	Severity Severity
+	Code     Code
+	Message  string
+	Span     Span
+	Notes    []Note
+	Fix      *Fix
+}
+
+// Error formats the diagnostic as "file:line:col: severity[code]: message".
+func (d Diagnostic) Error() string {
+	return fmt.Sprintf("%s: %s[%s]: %s", d.Span.Start, d.Severity, d.Code, d.Message)
+}
+
+func (d Diagnostic) String() string {
+	return d.Error()
+}
+
+// List is a collection of diagnostics, usually from a single file.
+type List []Diagnostic
+
+// Add appends an error diagnostic and returns a pointer to it, so callers
+// can attach notes or a fix.
+func (l *List) Add(code Code, span Span, format string, args ...interface{}) *Diagnostic {
+	*l = append(*l, Diagnostic{
+		Severity: Error,
+		Code:     code,
+		Message:  fmt.Sprintf(format, args...),
+		Span:     span,
+	})
+	return &(*l)[len(*l)-1]
+}
+
+func (l List) HasErrors() bool {
+	for _, d := range l {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Sort orders the list by file and position, then by severity.
+func (l List) Sort() {
+	sort.SliceStable(l, func(i, j int) bool {
+		a, b := l[i].Span.Start, l[j].Span.Start
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		if a.Offset != b.Offset {
+			return a.Offset < b.Offset
+		}
+		return l[i].Severity < l[j].Severity
+	})
+}
+
+// Dedupe returns the list without repeats of a diagnostic with the same
+// code, message and starting position. It keeps the first of each.
+func (l List) Dedupe() List {
+	type key struct {
+		code Code
+		msg  string
+		pos  token.Position
+	}
+
+	seen := make(map[key]bool)
+	out := List{}
+	for _, d := range l {
+		k := key{d.Code, d.Message, d.Span.Start}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		out = append(out, d)
+	}
+	return out
+}
+
+func (l List) Filter(keep func(Diagnostic) bool) List {
+	out := List{}
+	for _, d := range l {
+		if keep(d) {
+			out = append(out, d)
+		}
+	}
+	return out
+}
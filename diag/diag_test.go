@@ -0,0 +1,55 @@
+package diag
+
+import (
+	"chimp/token"
+
+	"testing"
+)
+
+func pos(file string, line, col, offset int) token.Position {
+	return token.Position{Filename: file, Line: line, Column: col, Offset: offset}
+}
+
+func TestError(t *testing.T) {
+	d := Diagnostic{
+		Severity: Warning,
+		Code:     UnexpectedToken,
+		Message:  "something is off",
+		Span:     At(pos("a.chp", 3, 7, 20)),
+	}
+
+	expected := "a.chp:3:7: warning[P0001]: something is off"
+	if d.Error() != expected {
+		t.Errorf("d.Error(): expected=%q. got=%q", expected, d.Error())
+	}
+}
+
+func TestSortDedupeFilter(t *testing.T) {
+	var list List
+	list.Add(ExpectedExpression, At(pos("b.chp", 1, 1, 0)), "third")
+	list.Add(UnexpectedToken, At(pos("a.chp", 2, 1, 10)), "second")
+	list.Add(UnexpectedToken, At(pos("a.chp", 1, 5, 4)), "first")
+	list.Add(UnexpectedToken, At(pos("a.chp", 2, 1, 10)), "second")
+	list = append(list, Diagnostic{Severity: Warning, Code: MalformedNumber, Span: At(pos("a.chp", 1, 5, 4))})
+
+	list.Sort()
+	list = list.Dedupe()
+
+	expected := []string{"first", "", "second", "third"}
+	if len(list) != len(expected) {
+		t.Fatalf("list: expected %d diagnostics. got=%d (%v)", len(expected), len(list), list)
+	}
+	for i, msg := range expected {
+		if list[i].Message != msg {
+			t.Errorf("list[%d].Message: expected=%q. got=%q", i, msg, list[i].Message)
+		}
+	}
+
+	warnings := list.Filter(func(d Diagnostic) bool { return d.Severity == Warning })
+	if len(warnings) != 1 || warnings.HasErrors() {
+		t.Errorf("warnings: expected 1 warning and no errors. got=%v", warnings)
+	}
+	if !list.HasErrors() {
+		t.Errorf("list.HasErrors(): expected=true. got=false")
+	}
+}
@@ -0,0 +1,205 @@
+package diag
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiRed    = "\x1b[1;31m"
+	ansiYellow = "\x1b[1;33m"
+	ansiCyan   = "\x1b[1;36m"
+	ansiGreen  = "\x1b[1;32m"
+	ansiBlue   = "\x1b[1;34m"
+)
+
+var severityColors = map[Severity]string{
+	Error:   ansiRed,
+	Warning: ansiYellow,
+	Info:    ansiCyan,
+}
+
+// Renderer prints diagnostics in the style of clang and rustc: a
+// "file:line:col" header, the offending source line and a ^~~~ underline
+// beneath the span, followed by any notes and suggested fix.
+type Renderer struct {
+	out     io.Writer
+	color   bool
+	sources map[string][]string
+}
+
+func NewRenderer(out io.Writer, color bool) *Renderer {
+	return &Renderer{out: out, color: color, sources: make(map[string][]string)}
+}
+
+// AddSource registers the text of filename, so that diagnostics in it can
+// be shown with a snippet. Diagnostics in unknown files get only a header.
+func (r *Renderer) AddSource(filename string, src string) {
+	r.sources[filename] = strings.Split(src, "\n")
+}
+
+// RenderAll sorts and dedupes list before rendering each diagnostic.
+func (r *Renderer) RenderAll(list List) {
+	list = append(List{}, list...)
+	list.Sort()
+	for _, d := range list.Dedupe() {
+		r.Render(d)
+	}
+}
+
+func (r *Renderer) Render(d Diagnostic) {
+	fmt.Fprintf(r.out, "%s: %s: %s\n",
+		r.paint(ansiBold, d.Span.Start.String()),
+		r.paint(severityColors[d.Severity], fmt.Sprintf("%s[%s]", d.Severity, d.Code)),
+		r.paint(ansiBold, d.Message))
+
+	width := r.gutterWidth(d)
+	r.snippet(d.Span, width, '^', '~', ansiGreen, "")
+
+	for _, note := range d.Notes {
+		if note.Span.Start.IsValid() && r.snippet(note.Span, width, '-', '-', ansiBlue, note.Message) {
+			continue
+		}
+		r.footer(width, "note", note.Message)
+	}
+
+	if d.Fix != nil {
+		msg := d.Fix.Message
+		if d.Fix.Replacement != "" {
+			msg += fmt.Sprintf(": `%s`", d.Fix.Replacement)
+		}
+		r.footer(width, "help", msg)
+	}
+}
+
+// snippet prints the source line holding span with the span underlined,
+// and reports whether the line was available.
+func (r *Renderer) snippet(span Span, width int, first, rest rune, color, label string) bool {
+	lines, ok := r.sources[span.Start.Filename]
+	if !ok || span.Start.Line < 1 || span.Start.Line > len(lines) {
+		return false
+	}
+	line := strings.TrimRight(lines[span.Start.Line-1], "\r")
+
+	startCol := byteIndex(line, span.Start.Column)
+	endCol := len(line)
+	if span.End.Line == span.Start.Line {
+		endCol = clamp(byteIndex(line, span.End.Column), startCol, len(line))
+	}
+
+	// Keep tabs in the padding so the underline lines up however wide
+	// the terminal draws them.
+	var pad strings.Builder
+	for _, char := range line[:startCol] {
+		if char == '\t' {
+			pad.WriteRune('\t')
+		} else {
+			pad.WriteRune(' ')
+		}
+	}
+
+	marks := string(first)
+	if n := utf8.RuneCountInString(line[startCol:endCol]); n > 1 {
+		marks += strings.Repeat(string(rest), n-1)
+	}
+	if label != "" {
+		marks += " " + label
+	}
+
+	gutter := strings.Repeat(" ", width)
+	fmt.Fprintf(r.out, "%s\n", r.paint(ansiBlue, gutter+" |"))
+	fmt.Fprintf(r.out, "%s%s\n", r.paint(ansiBlue, fmt.Sprintf("%*d | ", width, span.Start.Line)), line)
+	fmt.Fprintf(r.out, "%s%s%s\n", r.paint(ansiBlue, gutter+" | "), pad.String(), r.paint(color, marks))
+
+	return true
+}
+
+func (r *Renderer) footer(width int, label, msg string) {
+	fmt.Fprintf(r.out, "%s%s: %s\n",
+		r.paint(ansiBlue, strings.Repeat(" ", width)+" = "), r.paint(ansiBold, label), msg)
+}
+
+// gutterWidth is the width of the widest line number d will print.
+func (r *Renderer) gutterWidth(d Diagnostic) int {
+	line := d.Span.Start.Line
+	for _, note := range d.Notes {
+		if note.Span.Start.Line > line {
+			line = note.Span.Start.Line
+		}
+	}
+	return len(fmt.Sprint(line))
+}
+
+func (r *Renderer) paint(color, s string) string {
+	if !r.color || color == "" {
+		return s
+	}
+	return color + s + ansiReset
+}
+
+// byteIndex returns the index in line of the character at column col,
+// counting a byte of invalid UTF-8 as one character as the lexer does.
+func byteIndex(line string, col int) int {
+	i := 0
+	for n := 1; n < col && i < len(line); n++ {
+		_, size := utf8.DecodeRuneInString(line[i:])
+		i += size
+	}
+	return i
+}
+
+func clamp(n, lo, hi int) int {
+	if n < lo {
+		return lo
+	}
+	if n > hi {
+		return hi
+	}
+	return n
+}
+
+type ColorMode int
+
+const (
+	ColorAuto ColorMode = iota
+	ColorAlways
+	ColorNever
+)
+
+// ParseColorMode parses the value of a --color=auto|always|never flag.
+func ParseColorMode(s string) (ColorMode, error) {
+	switch s {
+	case "auto":
+		return ColorAuto, nil
+	case "always":
+		return ColorAlways, nil
+	case "never":
+		return ColorNever, nil
+	}
+	return ColorAuto, fmt.Errorf("invalid color mode '%s': expected auto, always or never", s)
+}
+
+// Enabled reports whether output to f should be colored. In auto mode
+// that is when f is a terminal and NO_COLOR is unset.
+func (m ColorMode) Enabled(f *os.File) bool {
+	switch m {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,108 @@
+package diag
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	src := "let x = 1;\n\tlet yy = (x + 2;\n"
+
+	d := Diagnostic{
+		Severity: Error,
+		Code:     UnexpectedToken,
+		Message:  "expected ')', got ';' instead",
+		Span:     Span{Start: pos("r.chp", 2, 17, 27), End: pos("r.chp", 2, 18, 28)},
+		Notes: []Note{
+			{Span: Span{Start: pos("r.chp", 2, 11, 21), End: pos("r.chp", 2, 12, 22)}, Message: "opened here"},
+			{Message: "a note without a span"},
+		},
+		Fix: &Fix{Span: At(pos("r.chp", 2, 17, 27)), Replacement: ")", Message: "insert the missing token"},
+	}
+
+	expected := "r.chp:2:17: error[P0001]: expected ')', got ';' instead\n" +
+		"  |\n" +
+		"2 | \tlet yy = (x + 2;\n" +
+		"  | \t               ^\n" +
+		"  |\n" +
+		"2 | \tlet yy = (x + 2;\n" +
+		"  | \t         - opened here\n" +
+		"  = note: a note without a span\n" +
+		"  = help: insert the missing token: `)`\n"
+
+	var out bytes.Buffer
+	r := NewRenderer(&out, false)
+	r.AddSource("r.chp", src)
+	r.Render(d)
+
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestRenderUnderline(t *testing.T) {
+	d := Diagnostic{
+		Code:    MalformedNumber,
+		Message: "bad",
+		Span:    Span{Start: pos("u.chp", 1, 5, 4), End: pos("u.chp", 1, 9, 8)},
+	}
+
+	var out bytes.Buffer
+	r := NewRenderer(&out, false)
+	r.AddSource("u.chp", "x = 1__0;")
+	r.Render(d)
+
+	expected := "u.chp:1:5: error[L0005]: bad\n" +
+		"  |\n" +
+		"1 | x = 1__0;\n" +
+		"  |     ^~~~\n"
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestRenderUnderlineAfterUnicode(t *testing.T) {
+	d := Diagnostic{
+		Code:    MalformedNumber,
+		Message: "bad",
+		Span:    Span{Start: pos("u.chp", 1, 8, 9), End: pos("u.chp", 1, 12, 13)},
+	}
+
+	var out bytes.Buffer
+	r := NewRenderer(&out, false)
+	r.AddSource("u.chp", "café = 1__0;")
+	r.Render(d)
+
+	expected := "u.chp:1:8: error[L0005]: bad\n" +
+		"  |\n" +
+		"1 | café = 1__0;\n" +
+		"  |        ^~~~\n"
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestRenderWithoutSource(t *testing.T) {
+	var out bytes.Buffer
+	r := NewRenderer(&out, true)
+	r.Render(Diagnostic{Code: UnterminatedString, Message: "m", Span: At(pos("missing.chp", 1, 1, 0))})
+
+	expected := "\x1b[1mmissing.chp:1:1\x1b[0m: \x1b[1;31merror[L0002]\x1b[0m: \x1b[1mm\x1b[0m\n"
+	if out.String() != expected {
+		t.Errorf("wrong output.\nexpected: %q\ngot:      %q", expected, out.String())
+	}
+}
+
+func TestParseColorMode(t *testing.T) {
+	for _, s := range []string{"auto", "always", "never"} {
+		if _, err := ParseColorMode(s); err != nil {
+			t.Errorf("ParseColorMode(%q): unexpected error %s", s, err)
+		}
+	}
+	if _, err := ParseColorMode("sometimes"); err == nil {
+		t.Errorf("ParseColorMode(\"sometimes\"): expected an error")
+	}
+	if !ColorAlways.Enabled(nil) || ColorNever.Enabled(nil) {
+		t.Errorf("ColorAlways/ColorNever: wrong Enabled result")
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change.
+const diffContext = 3
+
+type edit struct {
+	op   byte // ' ', '-' or '+'
+	line string
+}
+
+// unifiedDiff returns a unified diff from a to b, the old and new contents
+// of the file name, or "" if they have the same lines.
+func unifiedDiff(name string, a, b []byte) string {
+	edits := diffLines(splitLines(string(a)), splitLines(string(b)))
+
+	var out strings.Builder
+	for i := 0; i < len(edits); {
+		for i < len(edits) && edits[i].op == ' ' {
+			i++
+		}
+		if i == len(edits) {
+			break
+		}
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s.orig\n+++ %s\n", name, name)
+		}
+
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := hunkEnd(edits, i)
+		writeHunk(&out, edits, start, end)
+		i = end
+	}
+
+	return out.String()
+}
+
+// hunkEnd returns the end of the hunk whose first change is at i. Changes
+// closer together than twice the context share a hunk.
+func hunkEnd(edits []edit, i int) int {
+	end := i
+	for {
+		for end < len(edits) && edits[end].op != ' ' {
+			end++
+		}
+		same := end
+		for same < len(edits) && edits[same].op == ' ' {
+			same++
+		}
+		if same < len(edits) && same-end <= 2*diffContext {
+			end = same
+			continue
+		}
+		if same-end > diffContext {
+			return end + diffContext
+		}
+		return same
+	}
+}
+
+func writeHunk(out *strings.Builder, edits []edit, start, end int) {
+	aLine, bLine := 1, 1
+	for _, e := range edits[:start] {
+		if e.op != '+' {
+			aLine++
+		}
+		if e.op != '-' {
+			bLine++
+		}
+	}
+
+	aCount, bCount := 0, 0
+	for _, e := range edits[start:end] {
+		if e.op != '+' {
+			aCount++
+		}
+		if e.op != '-' {
+			bCount++
+		}
+	}
+	if aCount == 0 {
+		aLine--
+	}
+	if bCount == 0 {
+		bLine--
+	}
+
+	fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", aLine, aCount, bLine, bCount)
+	for _, e := range edits[start:end] {
+		fmt.Fprintf(out, "%c%s\n", e.op, e.line)
+	}
+}
+
+// diffLines returns the edits that turn a into b. It uses the linear-space
+// form of Myers' O(ND) algorithm, so the cost grows with the size of the
+// files times the number of changed lines rather than with their product.
+func diffLines(a, b []string) []edit {
+	d := &differ{a: a, b: b}
+	d.diff(0, len(a), 0, len(b))
+
+	// The search may interleave the lines a change adds and removes; list
+	// the removed ones first, as diff(1) does.
+	edits := d.edits
+	for i := 0; i < len(edits); {
+		j := i
+		for j < len(edits) && edits[j].op != ' ' {
+			j++
+		}
+		run := edits[i:j]
+		sort.SliceStable(run, func(x, y int) bool { return run[x].op == '-' && run[y].op == '+' })
+		i = j + 1
+	}
+	return edits
+}
+
+// differ collects the edits between a and b in order as diff finds them.
+type differ struct {
+	a, b  []string
+	edits []edit
+}
+
+// diff appends the edits that turn a[a0:a1] into b[b0:b1]. Lines the two
+// ranges start or end with are kept as they are, and what is left between
+// them is split in two at a point on a shortest edit path.
+func (d *differ) diff(a0, a1, b0, b1 int) {
+	for a0 < a1 && b0 < b1 && d.a[a0] == d.b[b0] {
+		d.edits = append(d.edits, edit{' ', d.a[a0]})
+		a0++
+		b0++
+	}
+	end := a1
+	for a0 < a1 && b0 < b1 && d.a[a1-1] == d.b[b1-1] {
+		a1--
+		b1--
+	}
+
+	if a0 == a1 || b0 == b1 {
+		for _, line := range d.a[a0:a1] {
+			d.edits = append(d.edits, edit{'-', line})
+		}
+		for _, line := range d.b[b0:b1] {
+			d.edits = append(d.edits, edit{'+', line})
+		}
+	} else {
+		x, y := d.split(a0, a1, b0, b1)
+		d.diff(a0, x, b0, y)
+		d.diff(x, a1, y, b1)
+	}
+
+	for _, line := range d.a[a1:end] {
+		d.edits = append(d.edits, edit{' ', line})
+	}
+}
+
+// split returns a point (x, y) on a shortest edit path from (a0, b0) to
+// (a1, b1), found by searching forward from the start and backward from
+// the end, one more edit at a time, until the searches overlap. Both
+// ranges must be non-empty and differ in their first and last lines, so
+// that the point is strictly between the two ends.
+func (d *differ) split(a0, a1, b0, b1 int) (int, int) {
+	n, m := a1-a0, b1-b0
+	delta := n - m
+	odd := delta%2 != 0
+	max := (n + m + 1) / 2
+
+	// fwd[max+k] is the furthest x reached so far on the diagonal
+	// x - y = k, counting from (a0, b0); bwd is the same counting back
+	// from (a1, b1). -1 marks a diagonal not reached yet.
+	fwd := make([]int, 2*max+2)
+	bwd := make([]int, 2*max+2)
+	for i := range fwd {
+		fwd[i], bwd[i] = -1, -1
+	}
+	fwd[max+1], bwd[max+1] = 0, 0
+
+	// Diagonals that have run off the edge of the grid are dropped from
+	// the search by narrowing its range of k.
+	fwdLo, fwdHi, bwdLo, bwdHi := 0, 0, 0, 0
+	for e := 0; e < max; e++ {
+		for k := -e + fwdLo; k <= e-fwdHi; k += 2 {
+			x := furthest(fwd, max+k, k == -e, k == e)
+			y := x - k
+			for x < n && y < m && d.a[a0+x] == d.b[b0+y] {
+				x++
+				y++
+			}
+			fwd[max+k] = x
+			switch {
+			case x > n:
+				fwdHi += 2
+			case y > m:
+				fwdLo += 2
+			case odd:
+				if i := max + delta - k; i >= 0 && i < len(bwd) && bwd[i] != -1 && x >= n-bwd[i] {
+					return a0 + x, b0 + y
+				}
+			}
+		}
+
+		for k := -e + bwdLo; k <= e-bwdHi; k += 2 {
+			x := furthest(bwd, max+k, k == -e, k == e)
+			y := x - k
+			for x < n && y < m && d.a[a1-1-x] == d.b[b1-1-y] {
+				x++
+				y++
+			}
+			bwd[max+k] = x
+			switch {
+			case x > n:
+				bwdHi += 2
+			case y > m:
+				bwdLo += 2
+			case !odd:
+				if i := max + delta - k; i >= 0 && i < len(fwd) && fwd[i] != -1 && fwd[i] >= n-x {
+					fx := fwd[i]
+					return a0 + fx, b0 + fx - (i - max)
+				}
+			}
+		}
+	}
+
+	// The searches always meet before this. Deleting all of a and then
+	// inserting all of b would still be a correct diff.
+	return a1, b0
+}
+
+// furthest returns where a path with one more edit starts on the diagonal
+// at index i of v: one step down from the diagonal above it, or one step
+// right from the one below, whichever of the two got further.
+func furthest(v []int, i int, lowest, highest bool) int {
+	if lowest || (!highest && v[i-1] < v[i+1]) {
+		return v[i+1]
+	}
+	return v[i-1] + 1
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"chimp/ast"
+	"chimp/diag"
+	"chimp/lexer"
+	"chimp/parser"
+	"chimp/token"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// tokens prints the tokens of a file, up to and including EOF, as text or
+// JSON. Lexical errors are rendered to stderr after the tokens.
+func tokens(input string, filename string, color bool, format string) int {
+	l := lexer.New(input, filename)
+
+	toks := []token.Token{}
+	for {
+		tok := l.NextToken()
+		toks = append(toks, tok)
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+
+	if format == "json" {
+		out, err := json.MarshalIndent(struct {
+			File   string        `json:"file"`
+			Tokens []token.Token `json:"tokens"`
+		}{filename, toks}, "", "  ")
+		if err != nil {
+			fmt.Printf("CLI: %s\n", err)
+			return exitSoftware
+		}
+		fmt.Println(string(out))
+	} else {
+		for _, tok := range toks {
+			fmt.Printf("%d:%d\t%s\t%q\n", tok.Pos.Line, tok.Pos.Column, tok.Type, tok.Literal)
+		}
+	}
+
+	if len(l.Errors) > 0 {
+		renderDiags(input, filename, color, l.Errors)
+		if l.Errors.HasErrors() {
+			return exitDataErr
+		}
+	}
+	return 0
+}
+
+// dumpAST parses a file and prints its syntax tree as an indented tree,
+// JSON or an S-expression.
+func dumpAST(input string, filename string, color bool, format string) int {
+	l := lexer.New(input, filename)
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	if diags := append(l.Errors, p.Errors()...); len(diags) > 0 {
+		renderDiags(input, filename, color, diags)
+		if diags.HasErrors() {
+			return exitDataErr
+		}
+	}
+
+	switch format {
+	case "json":
+		data, err := ast.MarshalJSON(program)
+		if err != nil {
+			fmt.Printf("CLI: %s\n", err)
+			return exitSoftware
+		}
+		var out bytes.Buffer
+		json.Indent(&out, data, "", "  ")
+		fmt.Println(out.String())
+	case "sexpr":
+		fmt.Println(ast.Sexpr(program))
+	default:
+		ast.Fprint(os.Stdout, program)
+	}
+	return 0
+}
+
+// renderDiags renders diags to stderr, quoting input unless it is empty.
+func renderDiags(input string, filename string, color bool, diags diag.List) {
+	r := diag.NewRenderer(os.Stderr, color)
+	if input != "" {
+		r.AddSource(filename, input)
+	}
+	r.RenderAll(diags)
+}
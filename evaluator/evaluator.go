@@ -0,0 +1,337 @@
+package evaluator
+
+import (
+	"chimp/ast"
+	"chimp/object"
+	"fmt"
+	"strings"
+)
+
+// MaxCallDepth is how many function calls may be in progress at once. It
+// matches vm.MaxFrames, so that both engines overflow at the same depth.
+const MaxCallDepth = 1024
+
+var (
+	NULL  = &object.Null{}
+	TRUE  = &object.Boolean{Value: true}
+	FALSE = &object.Boolean{Value: false}
+)
+
+// Eval evaluates node in env. Statements that produce no value, such as
+// declarations, evaluate to nil.
+func Eval(node ast.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+
+	// Statements
+	case *ast.Program:
+		return evalProgram(node, env)
+
+	case *ast.ExpressionStatement:
+		return Eval(node.Expression, env)
+
+	case *ast.BlockStatement:
+		return evalBlockStatement(node, env)
+
+	case *ast.ReturnStatement:
+		if node.ReturnValue == nil {
+			return &object.ReturnValue{Value: NULL}
+		}
+		val := Eval(node.ReturnValue, env)
+		if isError(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val}
+
+	case *ast.LetStatement:
+		return evalDeclaration(node.Name, node.Value, env)
+
+	case *ast.VarDecl:
+		return evalDeclaration(node.Name, node.Value, env)
+
+	// Expressions
+	case *ast.IntegerLiteral:
+		return &object.Integer{Value: node.Value}
+
+	case *ast.FloatLiteral:
+		return &object.Float{Value: node.Value}
+
+	case *ast.StringLiteral:
+		return &object.String{Value: node.Value}
+
+	case *ast.InterpolatedString:
+		return evalInterpolatedString(node, env)
+
+	case *ast.Boolean:
+		return nativeBoolToBooleanObject(node.Value)
+
+	case *ast.Null:
+		return NULL
+
+	case *ast.Identifier:
+		return evalIdentifier(node, env)
+
+	case *ast.PrefixExpression:
+		right := Eval(node.Right, env)
+		if isError(right) {
+			return right
+		}
+		return evalPrefixExpression(node, right)
+
+	case *ast.InfixExpression:
+		return evalInfixExpression(node, env)
+
+	case *ast.PostfixExpression:
+		return evalPostfixExpression(node, env)
+
+	case *ast.IfExpression:
+		return evalIfExpression(node, env)
+
+	case *ast.AssignExpression:
+		return evalAssignExpression(node, env)
+
+	case *ast.FunctionLiteral:
+		return &object.Function{Parameters: node.Parameters, Body: node.Body, Env: env}
+
+	case *ast.CallExpression:
+		function := Eval(node.Function, env)
+		if isError(function) {
+			return function
+		}
+
+		args := evalExpressions(node.Arguments, env)
+		if len(args) == 1 && isError(args[0]) {
+			return args[0]
+		}
+
+		return applyFunction(node, function, args, env.Depth()+1)
+
+	case *ast.ArrayLiteral:
+		return evalArrayLiteral(node, env)
+
+	case *ast.HashLiteral:
+		return evalHashLiteral(node, env)
+
+	case *ast.IndexExpression:
+		return evalIndexExpression(node, env)
+
+	case *ast.SliceExpression:
+		return evalSliceExpression(node, env)
+	}
+
+	return newError(node, "cannot evaluate %T", node)
+}
+
+func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+	var result object.Object
+
+	for _, statement := range program.Statements {
+		result = Eval(statement, env)
+
+		switch result := result.(type) {
+		case *object.ReturnValue:
+			return result.Value
+		case *object.Error:
+			return result
+		}
+	}
+
+	return result
+}
+
+// evalBlockStatement evaluates to the value of the block's last
+// statement. Return values and errors are passed up unopened, so they
+// stop every enclosing block on their way out.
+func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+	var result object.Object
+
+	for _, statement := range block.Statements {
+		result = Eval(statement, env)
+
+		if result != nil {
+			rt := result.Type()
+			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+				return result
+			}
+		}
+	}
+
+	return result
+}
+
+func evalDeclaration(name *ast.Identifier, value ast.Expression, env *object.Environment) object.Object {
+	val := Eval(value, env)
+	if isError(val) {
+		return val
+	}
+
+	if _, ok := value.(*ast.FunctionLiteral); ok {
+		val.(*object.Function).Name = name.Value
+	}
+	env.Set(name.Value, val)
+
+	return nil
+}
+
+func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	if val, ok := env.Get(node.Value); ok {
+		return val
+	}
+
+	if builtin := object.GetBuiltinByName(node.Value); builtin != nil {
+		return builtin
+	}
+
+	return newError(node, "identifier not found: %s", node.Value)
+}
+
+func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	val := Eval(node.Value, env)
+	if isError(val) {
+		return val
+	}
+
+	name := node.Target.(*ast.Identifier)
+	if !env.Assign(name.Value, val) {
+		return newError(name, "identifier not found: %s", name.Value)
+	}
+
+	return val
+}
+
+func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := Eval(ie.Condition, env)
+	if isError(condition) {
+		return condition
+	}
+
+	var result object.Object
+	if isTruthy(condition) {
+		result = Eval(ie.Consequence, object.NewEnclosedEnvironment(env))
+	} else if ie.Alternative != nil {
+		result = Eval(ie.Alternative, object.NewEnclosedEnvironment(env))
+	}
+
+	if result == nil {
+		return NULL
+	}
+	return result
+}
+
+func evalInterpolatedString(node *ast.InterpolatedString, env *object.Environment) object.Object {
+	var out strings.Builder
+
+	for _, part := range node.Parts {
+		val := Eval(part, env)
+		if isError(val) {
+			return val
+		}
+		out.WriteString(val.Inspect())
+	}
+
+	return &object.String{Value: out.String()}
+}
+
+func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+	var result []object.Object
+
+	for _, e := range exps {
+		evaluated := Eval(e, env)
+		if isError(evaluated) {
+			return []object.Object{evaluated}
+		}
+		result = append(result, evaluated)
+	}
+
+	return result
+}
+
+// applyFunction calls fn with args, making a call depth calls deep.
+// Chimp has no implicit returns: a call that finishes without reaching a
+// return statement evaluates to null.
+func applyFunction(call *ast.CallExpression, fn object.Object, args []object.Object, depth int) object.Object {
+	if builtin, ok := fn.(*object.Builtin); ok {
+		return applyBuiltin(call, builtin, args)
+	}
+
+	function, ok := fn.(*object.Function)
+	if !ok {
+		return newError(call, "not a function: %s", fn.Type())
+	}
+
+	if len(args) != len(function.Parameters) {
+		return newError(call, "wrong number of arguments: want=%d, got=%d",
+			len(function.Parameters), len(args))
+	}
+
+	if depth >= MaxCallDepth {
+		return newError(call, "stack overflow at depth %d", depth)
+	}
+
+	env := object.NewCallEnvironment(function.Env, depth)
+	for i, param := range function.Parameters {
+		env.Set(param.Name.Value, args[i])
+	}
+
+	switch result := Eval(function.Body, env).(type) {
+	case *object.ReturnValue:
+		return result.Value
+	case *object.Error:
+		traceCall(result, function, call)
+		return result
+	}
+
+	return NULL
+}
+
+// traceCall adds the call of function at call to the stack trace of an
+// error leaving it. The frame for the caller is added too, as the main
+// program, to be renamed if the error turns out to leave a function.
+func traceCall(err *object.Error, function *object.Function, call *ast.CallExpression) {
+	if len(err.Trace) == 0 {
+		err.Trace = append(err.Trace, object.StackFrame{Function: function.Name, Pos: err.Pos})
+	} else {
+		err.Trace[len(err.Trace)-1].Function = function.Name
+	}
+	err.Trace = append(err.Trace, object.StackFrame{Function: "main", Pos: call.Pos()})
+}
+
+// applyBuiltin calls a builtin, turning the nil it returns for null into
+// NULL and placing its errors at the call.
+func applyBuiltin(call *ast.CallExpression, builtin *object.Builtin, args []object.Object) object.Object {
+	switch result := builtin.Fn(args...).(type) {
+	case nil:
+		return NULL
+	case *object.Error:
+		result.Pos = call.Pos()
+		return result
+	default:
+		return result
+	}
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return TRUE
+	}
+	return FALSE
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case NULL, FALSE:
+		return false
+	default:
+		return true
+	}
+}
+
+func newError(node ast.Node, format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Pos: node.Pos()}
+}
+
+func isError(obj object.Object) bool {
+	if obj != nil {
+		return obj.Type() == object.ERROR_OBJ
+	}
+	return false
+}
@@ -0,0 +1,606 @@
+package evaluator
+
+import (
+	"bytes"
+	"chimp/lexer"
+	"chimp/object"
+	"chimp/parser"
+	"os"
+
+	"testing"
+)
+
+func testEval(t *testing.T, input string) object.Object {
+	l := lexer.New(input, "evaltest")
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	env := object.NewEnvironment()
+
+	return Eval(program, env)
+}
+
+func TestEvalIntegerExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"5", 5},
+		{"-10", -10},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"50 / 2 * 2 + 10", 60},
+		{"3 * (3 * 3) + 10", 37},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"0xF0 | 0x0F", 255},
+		{"0b1100 & 0b1010", 8},
+		{"0b1100 ^ 0b1010", 6},
+		{"~0", -1},
+		{"1 << 10", 1024},
+		{"1024 >> 3", 128},
+		{"1_000 + 0o10", 1008},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"1.5", 1.5},
+		{"-2.5", -2.5},
+		{"1.5 + 2.25", 3.75},
+		{"1e3 / 4.0", 250},
+		{"2.0 ** 0.5 ** 2.0", 1.189207115002721},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		result, ok := evaluated.(*object.Float)
+		if !ok {
+			t.Errorf("object: expected=Float. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if result.Value != tt.expected {
+			t.Errorf("object.Value: expected=%g. got=%g", tt.expected, result.Value)
+		}
+	}
+}
+
+func TestEvalBooleanExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"true", true},
+		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 <= 1", true},
+		{"1 >= 2", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"true == true", true},
+		{"true != false", true},
+		{"(1 < 2) == true", true},
+		{"!true", false},
+		{"!!5", true},
+		{"true && false", false},
+		{"true || false", true},
+		{"true ^^ true", false},
+		{"false ^^ true", true},
+		{`"a" == "a"`, true},
+		{`"a" != "b"`, true},
+		{"null == null", true},
+		{"1 == null", false},
+		{"false && (1 / 0 == 0)", false},
+		{"true || (1 / 0 == 0)", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestStringExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"Hello" + " " + "World!"`, "Hello World!"},
+		{`let name = "chimp"; "hi ${name}, ${1 + 2}!"`, "hi chimp, 3!"},
+		{"`raw ${x}`", "raw ${x}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		str, ok := evaluated.(*object.String)
+		if !ok {
+			t.Errorf("object: expected=String. got=%T (%+v)", evaluated, evaluated)
+			continue
+		}
+		if str.Value != tt.expected {
+			t.Errorf("str.Value: expected=%q. got=%q", tt.expected, str.Value)
+		}
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	testIntegerObject(t, testEval(t, "null ?? 5"), 5)
+	testIntegerObject(t, testEval(t, "3 ?? 5"), 3)
+	testIntegerObject(t, testEval(t, "null ?? null ?? 7"), 7)
+	testIntegerObject(t, testEval(t, "1 ?? 1 / 0"), 1)
+}
+
+func TestIfElseExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if true { 10 }", 10},
+		{"if false { 10 }", nil},
+		{"if 1 < 2 { 10 } else { 20 }", 10},
+		{"if 1 > 2 { 10 } else { 20 }", 20},
+		{"if 1 > 2 { 10 } else if 2 > 1 { 30 } else { 20 }", 30},
+		{"let isChimpGood = false; let s = if isChimpGood == true { 0 } else { 1 }; s", 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestReturnStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{`
+if 10 > 1 {
+    if 10 > 1 {
+        return 10;
+    }
+
+    return 1;
+}
+`, 10},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"5 + true;", "type mismatch: INTEGER + BOOLEAN"},
+		{"5 + true; 5;", "type mismatch: INTEGER + BOOLEAN"},
+		{"-true", "unknown operator: -BOOLEAN"},
+		{"true + false;", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"5; true + false; 5", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"if 10 > 1 { true + false; }", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"foobar", "identifier not found: foobar"},
+		{`"Hello" - "World"`, "unknown operator: STRING - STRING"},
+		{"1 / 0", "division by zero"},
+		{"2 ** -1", "negative exponent: 2 ** -1"},
+		{"1 << -1", "negative shift count: -1"},
+		{"let f = fn(int x) { return x; }; f(1, 2)", "wrong number of arguments: want=1, got=2"},
+		{"let x = 5; x(1)", "not a function: INTEGER"},
+		{"[1, 2, 3][3]", "index out of range: 3 (length 3)"},
+		{"[1, 2, 3][-1]", "index out of range: -1 (length 3)"},
+		{`"ab"[2]`, "index out of range: 2 (length 2)"},
+		{"[1, 2, 3][1:4]", "slice bounds out of range: [1:4] (length 3)"},
+		{"[1, 2, 3][2:1]", "slice bounds out of range: [2:1] (length 3)"},
+		{`[1]["a"]`, "index must be an integer, got STRING"},
+		{`{"name": "Chimp"}[fn(x) { x }];`, "unusable as hash key: FUNCTION"},
+		{`{[1]: 2}`, "unusable as hash key: ARRAY"},
+		{"5[0]", "index operator not supported: INTEGER"},
+		{`{"a": 1}[0:1]`, "slice operator not supported: HASH"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Message != tt.expected {
+			t.Errorf("wrong error message. expected=%q, got=%q", tt.expected, errObj.Message)
+		}
+	}
+}
+
+func TestErrorPosition(t *testing.T) {
+	evaluated := testEval(t, "let x = 1;\nlet y = x + true;")
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	expected := "evaltest:2:9: runtime error: type mismatch: INTEGER + BOOLEAN"
+	if errObj.Inspect() != expected {
+		t.Errorf("errObj.Inspect(): expected=%q. got=%q", expected, errObj.Inspect())
+	}
+}
+
+func TestDeclarations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a;", 5},
+		{"int a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; int c = a + b + 5; c;", 15},
+		{"bool t = true; if t { 1 } else { 2 }", 1},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+}
+
+func TestFunctionObject(t *testing.T) {
+	input := "fn(int x) { return x + 2; };"
+
+	evaluated := testEval(t, input)
+	fn, ok := evaluated.(*object.Function)
+	if !ok {
+		t.Fatalf("object: expected=Function. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(fn.Parameters) != 1 {
+		t.Fatalf("fn.Parameters: expected=1. got=%d", len(fn.Parameters))
+	}
+	if fn.Parameters[0].String() != "int x" {
+		t.Fatalf("fn.Parameters[0]: expected='int x'. got=%q", fn.Parameters[0])
+	}
+}
+
+func TestFunctionApplication(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let addTwo = fn(int x) { return x + 2; }; addTwo(3);", 5},
+		{"let double = fn(x) { return x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { return x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { return x; }(5)", 5},
+		{"let noReturn = fn(x) { x; }; noReturn(5)", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if expected, ok := tt.expected.(int); ok {
+			testIntegerObject(t, evaluated, int64(expected))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestClosures(t *testing.T) {
+	input := `
+let newAdder = fn(x) {
+    return fn(y) { return x + y; };
+};
+
+let addTwo = newAdder(2);
+addTwo(2);`
+
+	testIntegerObject(t, testEval(t, input), 4)
+}
+
+func TestRecursiveFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let fact = fn(n) { if n < 2 { return 1; } return n * fact(n - 1); }; fact(5)", 120},
+		{`let isEven = fn(n) { if n == 0 { return true; } return isOdd(n - 1); };
+let isOdd = fn(n) { if n == 0 { return false; } return isEven(n - 1); };
+isEven(10)`, true},
+		{`let outer = fn() {
+    let ping = fn(n) { if n == 0 { return 0; } return pong(n - 1) + 1; };
+    let pong = fn(n) { if n == 0 { return 0; } return ping(n - 1) + 1; };
+    return ping(7);
+};
+outer()`, 7},
+		{"let twice = fn(f, x) { return f(f(x)); }; twice(fn(x) { return x * 3; }, 2)", 18},
+		{"let compose = fn(f, g) { return fn(x) { return f(g(x)); }; }; compose(len, rest)([1, 2, 3])", 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestStackOverflow(t *testing.T) {
+	input := `let f = fn(x) {
+    return f(x + 1);
+};
+f(0)`
+
+	errObj, ok := testEval(t, input).(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned")
+	}
+	expected := "evaltest:2:12: runtime error: stack overflow at depth 1024"
+	if errObj.Inspect() != expected {
+		t.Errorf("errObj.Inspect(): expected=%q. got=%q", expected, errObj.Inspect())
+	}
+	if len(errObj.Trace) != MaxCallDepth {
+		t.Errorf("len(errObj.Trace): expected=%d. got=%d", MaxCallDepth, len(errObj.Trace))
+	}
+}
+
+func TestStackTrace(t *testing.T) {
+	input := `let inner = fn(x) {
+    return x + true;
+};
+let outer = fn() { return inner(1); };
+outer()`
+
+	errObj, ok := testEval(t, input).(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned")
+	}
+	expected := `    at inner (evaltest:2:12)
+    at outer (evaltest:4:27)
+    at main (evaltest:5:1)
+`
+	if got := object.FormatStackTrace(errObj.Trace); got != expected {
+		t.Errorf("stack trace:\nexpected=%q\ngot=     %q", expected, got)
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	evaluated := testEval(t, "[1, 2 * 2, 3 + 3]")
+
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got=%d", len(result.Elements))
+	}
+
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][1 + 1]", 3},
+		{"let i = 0; [1][i]", 1},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{"{5: 5}[5]", 5},
+		{"{true: 5}[true]", 5},
+		{"{false: 5}[false]", 5},
+		{`{"a": 1, "a": 2}["a"]`, 2},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestInspectCollections(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"[1, [true, null], \"s\"]", "[1, [true, null], s]"},
+		{"[]", "[]"},
+		{`{"one": 1, 2: [2], true: {}}`, "{one: 1, 2: [2], true: {}}"},
+		{"[1, 2, 3, 4][1:3]", "[2, 3]"},
+		{"[1, 2, 3][:]", "[1, 2, 3]"},
+		{"[1, 2, 3][2:]", "[3]"},
+		{"[1, 2, 3][:0]", "[]"},
+		{"[1, 2, 3][3:]", "[]"},
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[1:4]`, "éll"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%q: expected=%q. got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("héllo")`, 5},
+		{"len([1, 2, 3])", 3},
+		{`len({"a": 1})`, 1},
+		{"len(1)", "argument to len not supported, got INTEGER"},
+		{`len("one", "two")`, "wrong number of arguments to len: want=1, got=2"},
+		{"first([1, 2, 3])", 1},
+		{"first([])", nil},
+		{"first(1)", "argument to first must be ARRAY, got INTEGER"},
+		{"last([1, 2, 3])", 3},
+		{"last([])", nil},
+		{"len(rest([1, 2, 3]))", 2},
+		{"len(rest([]))", 0},
+		{"push([], 1)[0]", 1},
+		{"let a = [1]; let b = push(a, 2); len(a) + len(b)", 3},
+		{"push(1, 1)", "argument to push must be ARRAY, got INTEGER"},
+		{`type_of(1) + type_of([]) + type_of({}) + type_of(null) + type_of(len) + type_of(fn() {})`, "intarraymapnullfunctionfunction"},
+		{`to_string([1, "a"])`, "[1, a]"},
+		{`parse_int("42")`, 42},
+		{`parse_int("-7")`, -7},
+		{`parse_int("4x")`, nil},
+		{"assert(1 < 2)", nil},
+		{"assert(1 > 2)", "assertion failed"},
+		{`assert(false, "bad ${1}")`, "assertion failed: bad 1"},
+		{"puts()", nil},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			switch obj := evaluated.(type) {
+			case *object.Error:
+				if obj.Message != expected {
+					t.Errorf("%q: wrong error message. expected=%q, got=%q", tt.input, expected, obj.Message)
+				}
+			case *object.String:
+				if obj.Value != expected {
+					t.Errorf("%q: expected=%q. got=%q", tt.input, expected, obj.Value)
+				}
+			default:
+				t.Errorf("%q: expected %q. got=%T (%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestPuts(t *testing.T) {
+	var out bytes.Buffer
+	object.Stdout = &out
+	defer func() { object.Stdout = os.Stdout }()
+
+	testEval(t, `puts("a", 1); print("b", [2]); print(null)`)
+
+	if out.String() != "a\n1\nb[2]null" {
+		t.Errorf("output: expected=%q. got=%q", "a\n1\nb[2]null", out.String())
+	}
+}
+
+func TestBuiltinErrorPosition(t *testing.T) {
+	evaluated := testEval(t, "let xs = [];\nassert(len(xs) > 0, \"empty\")")
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	expected := "evaltest:2:1: runtime error: assertion failed: empty"
+	if errObj.Inspect() != expected {
+		t.Errorf("errObj.Inspect(): expected=%q. got=%q", expected, errObj.Inspect())
+	}
+}
+
+func TestAssignment(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let x = 1; x = 2; x;", 2},
+		{"let x = 1; let y = 2; x = y = 3; x + y;", 6},
+		{"let x = 1; if true { x = 5; }; x;", 5},
+		{"let x = 1; if true { let x = 2; x = 3; }; x;", 1},
+		{"let count = 0; let inc = fn() { count = count + 1; }; inc(); inc(); count;", 2},
+		{"let x = 1; x++; x++; x;", 3},
+		{"let x = 1; x--;", 1},
+		{"let x = 5; let y = x-- + x; y;", 9},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(t, tt.input), tt.expected)
+	}
+
+	err, ok := testEval(t, "y = 1;").(*object.Error)
+	if !ok || err.Message != "identifier not found: y" {
+		t.Errorf("expected identifier not found error. got=%v", err)
+	}
+}
+
+func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
+	result, ok := obj.(*object.Integer)
+	if !ok {
+		t.Errorf("object: expected=Integer. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object.Value: expected=%d. got=%d", expected, result.Value)
+		return false
+	}
+
+	return true
+}
+
+func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
+	result, ok := obj.(*object.Boolean)
+	if !ok {
+		t.Errorf("object: expected=Boolean. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object.Value: expected=%t. got=%t", expected, result.Value)
+		return false
+	}
+
+	return true
+}
+
+func testNullObject(t *testing.T, obj object.Object) bool {
+	if obj != NULL {
+		t.Errorf("object: expected=NULL. got=%T (%+v)", obj, obj)
+		return false
+	}
+
+	return true
+}
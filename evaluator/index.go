@@ -0,0 +1,84 @@
+package evaluator
+
+import (
+	"chimp/ast"
+	"chimp/object"
+)
+
+func evalArrayLiteral(node *ast.ArrayLiteral, env *object.Environment) object.Object {
+	elements := evalExpressions(node.Elements, env)
+	if len(elements) == 1 && isError(elements[0]) {
+		return elements[0]
+	}
+	if elements == nil {
+		elements = []object.Object{}
+	}
+	return &object.Array{Elements: elements}
+}
+
+func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+	hash := object.NewHash()
+
+	for _, pair := range node.Pairs {
+		key := Eval(pair.Key, env)
+		if isError(key) {
+			return key
+		}
+		value := Eval(pair.Value, env)
+		if isError(value) {
+			return value
+		}
+
+		hashKey, err := object.HashableKey(key)
+		if err != nil {
+			return newError(node, "%s", err)
+		}
+		hash.Set(hashKey, value)
+	}
+
+	return hash
+}
+
+func evalIndexExpression(node *ast.IndexExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+	index := Eval(node.Index, env)
+	if isError(index) {
+		return index
+	}
+
+	result, err := object.Index(left, index)
+	if err != nil {
+		return newError(node, "%s", err)
+	}
+	if result == nil {
+		return NULL
+	}
+	return result
+}
+
+// evalSliceExpression evaluates a[lo:hi].
+func evalSliceExpression(node *ast.SliceExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	bounds := []object.Object{nil, nil}
+	for i, bound := range []ast.Expression{node.Low, node.High} {
+		if bound == nil {
+			continue
+		}
+		if bounds[i] = Eval(bound, env); isError(bounds[i]) {
+			return bounds[i]
+		}
+	}
+
+	result, err := object.Slice(left, bounds[0], bounds[1])
+	if err != nil {
+		return newError(node, "%s", err)
+	}
+	return result
+}
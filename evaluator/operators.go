@@ -0,0 +1,242 @@
+package evaluator
+
+import (
+	"chimp/ast"
+	"chimp/object"
+	"math"
+)
+
+func evalPrefixExpression(node *ast.PrefixExpression, right object.Object) object.Object {
+	switch node.Operator {
+	case "!":
+		return nativeBoolToBooleanObject(!isTruthy(right))
+	case "-":
+		switch right := right.(type) {
+		case *object.Integer:
+			return &object.Integer{Value: -right.Value}
+		case *object.Float:
+			return &object.Float{Value: -right.Value}
+		}
+	case "~":
+		if right, ok := right.(*object.Integer); ok {
+			return &object.Integer{Value: ^right.Value}
+		}
+	}
+
+	return newError(node, "unknown operator: %s%s", node.Operator, right.Type())
+}
+
+// evalPostfixExpression evaluates x++ and x--, which update the variable
+// x and give the value it had before.
+func evalPostfixExpression(node *ast.PostfixExpression, env *object.Environment) object.Object {
+	name := node.Left.(*ast.Identifier)
+	old := Eval(name, env)
+	if isError(old) {
+		return old
+	}
+
+	delta := int64(1)
+	if node.Operator == "--" {
+		delta = -1
+	}
+
+	var updated object.Object
+	switch old := old.(type) {
+	case *object.Integer:
+		updated = &object.Integer{Value: old.Value + delta}
+	case *object.Float:
+		updated = &object.Float{Value: old.Value + float64(delta)}
+	default:
+		return newError(node, "unknown operator: %s%s", old.Type(), node.Operator)
+	}
+
+	env.Assign(name.Value, updated)
+	return old
+}
+
+func evalInfixExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
+	}
+
+	// The logical operators and ?? only evaluate their right operand when
+	// the left one does not settle the result.
+	switch node.Operator {
+	case "&&":
+		if !isTruthy(left) {
+			return FALSE
+		}
+		return evalTruthiness(node.Right, env)
+	case "||":
+		if isTruthy(left) {
+			return TRUE
+		}
+		return evalTruthiness(node.Right, env)
+	case "??":
+		if left != NULL {
+			return left
+		}
+		return Eval(node.Right, env)
+	}
+
+	right := Eval(node.Right, env)
+	if isError(right) {
+		return right
+	}
+
+	return evalBinaryOperator(node, node.Operator, left, right)
+}
+
+func evalTruthiness(node ast.Expression, env *object.Environment) object.Object {
+	val := Eval(node, env)
+	if isError(val) {
+		return val
+	}
+	return nativeBoolToBooleanObject(isTruthy(val))
+}
+
+// evalBinaryOperator applies an operator whose operands have both been
+// evaluated. Both operands must have the same type, except that anything
+// may be compared against null.
+func evalBinaryOperator(node ast.Node, operator string, left, right object.Object) object.Object {
+	switch {
+	case operator == "^^":
+		return nativeBoolToBooleanObject(isTruthy(left) != isTruthy(right))
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return evalIntegerInfixExpression(node, operator, left.(*object.Integer).Value, right.(*object.Integer).Value)
+	case left.Type() == object.FLOAT_OBJ && right.Type() == object.FLOAT_OBJ:
+		return evalFloatInfixExpression(node, operator, left.(*object.Float).Value, right.(*object.Float).Value)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return evalStringInfixExpression(node, operator, left.(*object.String).Value, right.(*object.String).Value)
+	case left.Type() == object.BOOLEAN_OBJ && right.Type() == object.BOOLEAN_OBJ:
+		return evalBooleanInfixExpression(node, operator, left.(*object.Boolean).Value, right.(*object.Boolean).Value)
+	case left == NULL || right == NULL:
+		switch operator {
+		case "==":
+			return nativeBoolToBooleanObject(left == right)
+		case "!=":
+			return nativeBoolToBooleanObject(left != right)
+		}
+	case left.Type() != right.Type():
+		return newError(node, "type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	}
+
+	return newError(node, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
+
+func evalIntegerInfixExpression(node ast.Node, operator string, left, right int64) object.Object {
+	switch operator {
+	case "+":
+		return &object.Integer{Value: left + right}
+	case "-":
+		return &object.Integer{Value: left - right}
+	case "*":
+		return &object.Integer{Value: left * right}
+	case "/":
+		if right == 0 {
+			return newError(node, "division by zero")
+		}
+		return &object.Integer{Value: left / right}
+	case "**":
+		if right < 0 {
+			return newError(node, "negative exponent: %d ** %d", left, right)
+		}
+		return &object.Integer{Value: intPow(left, right)}
+	case "&":
+		return &object.Integer{Value: left & right}
+	case "|":
+		return &object.Integer{Value: left | right}
+	case "^":
+		return &object.Integer{Value: left ^ right}
+	case "<<", ">>":
+		if right < 0 {
+			return newError(node, "negative shift count: %d", right)
+		}
+		if operator == "<<" {
+			return &object.Integer{Value: left << uint64(right)}
+		}
+		return &object.Integer{Value: left >> uint64(right)}
+	case "<":
+		return nativeBoolToBooleanObject(left < right)
+	case "<=":
+		return nativeBoolToBooleanObject(left <= right)
+	case ">":
+		return nativeBoolToBooleanObject(left > right)
+	case ">=":
+		return nativeBoolToBooleanObject(left >= right)
+	case "==":
+		return nativeBoolToBooleanObject(left == right)
+	case "!=":
+		return nativeBoolToBooleanObject(left != right)
+	}
+
+	return newError(node, "unknown operator: %s %s %s", object.INTEGER_OBJ, operator, object.INTEGER_OBJ)
+}
+
+// intPow computes base**exp by repeated squaring. Like the other integer
+// operators it wraps around on overflow.
+func intPow(base, exp int64) int64 {
+	result := int64(1)
+	for exp > 0 {
+		if exp&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exp >>= 1
+	}
+	return result
+}
+
+func evalFloatInfixExpression(node ast.Node, operator string, left, right float64) object.Object {
+	switch operator {
+	case "+":
+		return &object.Float{Value: left + right}
+	case "-":
+		return &object.Float{Value: left - right}
+	case "*":
+		return &object.Float{Value: left * right}
+	case "/":
+		return &object.Float{Value: left / right}
+	case "**":
+		return &object.Float{Value: math.Pow(left, right)}
+	case "<":
+		return nativeBoolToBooleanObject(left < right)
+	case "<=":
+		return nativeBoolToBooleanObject(left <= right)
+	case ">":
+		return nativeBoolToBooleanObject(left > right)
+	case ">=":
+		return nativeBoolToBooleanObject(left >= right)
+	case "==":
+		return nativeBoolToBooleanObject(left == right)
+	case "!=":
+		return nativeBoolToBooleanObject(left != right)
+	}
+
+	return newError(node, "unknown operator: %s %s %s", object.FLOAT_OBJ, operator, object.FLOAT_OBJ)
+}
+
+func evalStringInfixExpression(node ast.Node, operator string, left, right string) object.Object {
+	switch operator {
+	case "+":
+		return &object.String{Value: left + right}
+	case "==":
+		return nativeBoolToBooleanObject(left == right)
+	case "!=":
+		return nativeBoolToBooleanObject(left != right)
+	}
+
+	return newError(node, "unknown operator: %s %s %s", object.STRING_OBJ, operator, object.STRING_OBJ)
+}
+
+func evalBooleanInfixExpression(node ast.Node, operator string, left, right bool) object.Object {
+	switch operator {
+	case "==":
+		return nativeBoolToBooleanObject(left == right)
+	case "!=":
+		return nativeBoolToBooleanObject(left != right)
+	}
+
+	return newError(node, "unknown operator: %s %s %s", object.BOOLEAN_OBJ, operator, object.BOOLEAN_OBJ)
+}
@@ -0,0 +1,321 @@
+// Package format prints Chimp programs in canonical form.
+//
+// The canonical form indents blocks by four spaces, puts one statement on
+// each line, keeps at most one blank line where the source had any, puts
+// single spaces around binary operators and parenthesizes an expression
+// only where precedence requires it. Comments stay where they were,
+// relative to the statements around them.
+package format
+
+import (
+	"bytes"
+	"chimp/ast"
+	"chimp/diag"
+	"chimp/lexer"
+	"chimp/parser"
+	"chimp/token"
+	"io"
+	"strings"
+)
+
+const indent = "    "
+
+// Source formats src, the contents of the file filename. If src has
+// syntax errors, it returns them instead.
+func Source(src []byte, filename string) ([]byte, diag.List) {
+	l := lexer.New(string(src), filename)
+	l.Mode = lexer.ScanComments
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	if diags := append(l.Errors, p.Errors()...); diags.HasErrors() {
+		return nil, diags
+	}
+
+	var buf bytes.Buffer
+	Fprint(&buf, program)
+	return buf.Bytes(), nil
+}
+
+// Fprint writes program to w in canonical form, placing the comments in
+// program.Comments by their positions.
+func Fprint(w io.Writer, program *ast.Program) error {
+	p := &printer{comments: program.Comments}
+	p.stmts(program.Statements, false)
+	p.commentsBefore(token.Position{Offset: int(^uint(0) >> 1)})
+	if p.out.Len() > 0 {
+		p.out.WriteString("\n")
+	}
+
+	_, err := w.Write(p.out.Bytes())
+	return err
+}
+
+type printer struct {
+	out      bytes.Buffer
+	depth    int
+	comments []*ast.Comment // not yet printed
+
+	// lastLine is the source line of the last thing printed, and atStart
+	// is set until the first line of a block is printed. Together they
+	// decide where blank lines go and which comments trail code.
+	lastLine int
+	atStart  bool
+}
+
+// newline starts an output line for something from source line line,
+// after a blank line if the source had one there.
+func (p *printer) newline(line int) {
+	if p.out.Len() > 0 {
+		p.out.WriteString("\n")
+		if !p.atStart && line > p.lastLine+1 {
+			p.out.WriteString("\n")
+		}
+	}
+	p.out.WriteString(strings.Repeat(indent, p.depth))
+	p.atStart = false
+}
+
+// commentsBefore prints the comments that come before pos. A comment that
+// shares a line with the code before it stays at the end of that line.
+func (p *printer) commentsBefore(pos token.Position) {
+	for len(p.comments) > 0 && p.comments[0].Pos().Offset < pos.Offset {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+
+		if c.Pos().Line == p.lastLine && p.out.Len() > 0 {
+			p.out.WriteString(" ")
+		} else {
+			p.newline(c.Pos().Line)
+		}
+		p.out.WriteString(strings.TrimRight(c.Text, " \t\r"))
+		p.lastLine = c.End().Line
+	}
+}
+
+func (p *printer) hasCommentBefore(pos token.Position) bool {
+	return len(p.comments) > 0 && p.comments[0].Pos().Offset < pos.Offset
+}
+
+// stmts prints a statement list. In a block, the last statement is the
+// block's value and needs no semicolon.
+func (p *printer) stmts(list []ast.Statement, inBlock bool) {
+	for i, s := range list {
+		p.commentsBefore(s.Pos())
+		p.newline(s.Pos().Line)
+		p.stmt(s)
+		if es, ok := s.(*ast.ExpressionStatement); ok && p.needsSemicolon(es, list[i+1:], inBlock) {
+			p.out.WriteString(";")
+		}
+		p.lastLine = s.End().Line
+	}
+}
+
+// needsSemicolon reports whether an expression statement followed by rest
+// ends with a semicolon. An if needs none unless the next statement would
+// otherwise continue it, as in "if a { b }; -c".
+func (p *printer) needsSemicolon(es *ast.ExpressionStatement, rest []ast.Statement, inBlock bool) bool {
+	if len(rest) == 0 {
+		_, isIf := es.Expression.(*ast.IfExpression)
+		return !inBlock && !isIf
+	}
+	if _, ok := es.Expression.(*ast.IfExpression); !ok {
+		return true
+	}
+	next, ok := rest[0].(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+	prec, _ := parser.Precedence(next.Token.Type)
+	return prec > parser.LOWEST
+}
+
+func (p *printer) stmt(s ast.Statement) {
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		p.out.WriteString("let " + s.Name.Value + " = ")
+		p.expr(s.Value, parser.LOWEST)
+		p.out.WriteString(";")
+	case *ast.VarDecl:
+		p.out.WriteString(s.Type.String() + " " + s.Name.Value + " = ")
+		p.expr(s.Value, parser.LOWEST)
+		p.out.WriteString(";")
+	case *ast.ReturnStatement:
+		p.out.WriteString("return")
+		if s.ReturnValue != nil {
+			p.out.WriteString(" ")
+			p.expr(s.ReturnValue, parser.LOWEST)
+		}
+		p.out.WriteString(";")
+	case *ast.ExpressionStatement:
+		p.expr(s.Expression, parser.LOWEST)
+	case *ast.BlockStatement:
+		p.block(s)
+	}
+}
+
+func (p *printer) block(b *ast.BlockStatement) {
+	p.out.WriteString("{")
+	p.lastLine = b.Token.Pos.Line
+	if len(b.Statements) == 0 && !p.hasCommentBefore(b.Rbrace.Pos) {
+		p.out.WriteString("}")
+		p.lastLine = b.Rbrace.Pos.Line
+		return
+	}
+
+	p.depth++
+	p.atStart = true
+	p.stmts(b.Statements, true)
+	p.commentsBefore(b.Rbrace.Pos)
+	p.depth--
+
+	p.atStart = true
+	p.newline(b.Rbrace.Pos.Line)
+	p.out.WriteString("}")
+	p.lastLine = b.Rbrace.Pos.Line
+}
+
+// atom is the precedence of expressions that never need parentheses.
+const atom = parser.INDEX + 1
+
+func precedence(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		prec, _ := parser.Precedence(token.TokenType(e.Operator))
+		return prec
+	case *ast.AssignExpression:
+		return parser.ASSIGN
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.PostfixExpression:
+		return parser.POSTFIX
+	case *ast.CallExpression:
+		return parser.CALL
+	case *ast.IndexExpression, *ast.SliceExpression:
+		return parser.INDEX
+	}
+	return atom
+}
+
+// expr prints e where the grammar expects an expression binding at least
+// as tightly as prec, parenthesizing it if it does not.
+func (p *printer) expr(e ast.Expression, prec int) {
+	if precedence(e) < prec {
+		p.out.WriteString("(")
+		p.expr(e, parser.LOWEST)
+		p.out.WriteString(")")
+		return
+	}
+
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		prec, right := parser.Precedence(token.TokenType(e.Operator))
+		left := prec
+		if right {
+			left++
+		} else {
+			prec++
+		}
+		p.expr(e.Left, left)
+		p.out.WriteString(" " + e.Operator + " ")
+		p.expr(e.Right, prec)
+	case *ast.AssignExpression:
+		p.expr(e.Target, parser.ASSIGN+1)
+		p.out.WriteString(" = ")
+		p.expr(e.Value, parser.ASSIGN)
+	case *ast.PrefixExpression:
+		p.out.WriteString(e.Operator)
+		// Keep "- -x" from printing as "--x".
+		if inner, ok := e.Right.(*ast.PrefixExpression); ok && inner.Operator == e.Operator {
+			p.expr(e.Right, atom)
+		} else {
+			p.expr(e.Right, parser.PREFIX)
+		}
+	case *ast.PostfixExpression:
+		p.expr(e.Left, parser.POSTFIX)
+		p.out.WriteString(e.Operator)
+	case *ast.CallExpression:
+		p.expr(e.Function, parser.CALL)
+		p.out.WriteString("(")
+		p.exprList(e.Arguments)
+		p.out.WriteString(")")
+	case *ast.IndexExpression:
+		p.expr(e.Left, parser.CALL)
+		p.out.WriteString("[")
+		p.expr(e.Index, parser.LOWEST)
+		p.out.WriteString("]")
+	case *ast.SliceExpression:
+		p.expr(e.Left, parser.CALL)
+		p.out.WriteString("[")
+		if e.Low != nil {
+			p.expr(e.Low, parser.LOWEST)
+		}
+		p.out.WriteString(":")
+		if e.High != nil {
+			p.expr(e.High, parser.LOWEST)
+		}
+		p.out.WriteString("]")
+	case *ast.ArrayLiteral:
+		p.out.WriteString("[")
+		p.exprList(e.Elements)
+		p.out.WriteString("]")
+	case *ast.HashLiteral:
+		p.out.WriteString("{")
+		for i, pair := range e.Pairs {
+			if i > 0 {
+				p.out.WriteString(", ")
+			}
+			p.expr(pair.Key, parser.LOWEST)
+			p.out.WriteString(": ")
+			p.expr(pair.Value, parser.LOWEST)
+		}
+		p.out.WriteString("}")
+	case *ast.InterpolatedString:
+		p.out.WriteString(`"`)
+		for _, part := range e.Parts {
+			if text, ok := part.(*ast.StringLiteral); ok {
+				s := (&ast.StringLiteral{Value: text.Value}).String()
+				p.out.WriteString(s[1 : len(s)-1])
+				continue
+			}
+			p.out.WriteString("${")
+			p.expr(part, parser.LOWEST)
+			p.out.WriteString("}")
+		}
+		p.out.WriteString(`"`)
+	case *ast.IfExpression:
+		p.out.WriteString("if ")
+		p.expr(e.Condition, parser.LOWEST)
+		p.out.WriteString(" ")
+		p.block(e.Consequence)
+		if e.Alternative != nil {
+			p.out.WriteString(" else ")
+			if elseIf := e.ElseIf(); elseIf != nil {
+				p.expr(elseIf, parser.LOWEST)
+			} else {
+				p.block(e.Alternative)
+			}
+		}
+	case *ast.FunctionLiteral:
+		params := []string{}
+		for _, param := range e.Parameters {
+			params = append(params, param.String())
+		}
+		p.out.WriteString("fn(" + strings.Join(params, ", ") + ") ")
+		if e.ReturnType != nil {
+			p.out.WriteString(e.ReturnType.String() + " ")
+		}
+		p.block(e.Body)
+	default:
+		p.out.WriteString(e.String())
+	}
+}
+
+func (p *printer) exprList(list []ast.Expression) {
+	for i, e := range list {
+		if i > 0 {
+			p.out.WriteString(", ")
+		}
+		p.expr(e, parser.LOWEST)
+	}
+}
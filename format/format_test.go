@@ -0,0 +1,131 @@
+package format
+
+import (
+	"chimp/lexer"
+	"chimp/parser"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"", ""},
+		{"let x=1+2*3", "let x = 1 + 2 * 3;\n"},
+		{"let x = (1 + 2) * 3; let y = 1 + (2 * 3);", "let x = (1 + 2) * 3;\nlet y = 1 + 2 * 3;\n"},
+		{"a - (b - c); (a - b) - c", "a - (b - c);\na - b - c;\n"},
+		{"a ** (b ** c); (a ** b) ** c", "a ** b ** c;\n(a ** b) ** c;\n"},
+		{"-(2 ** 2); (-2) ** 2; -(-x); !(!x)", "-2 ** 2;\n(-2) ** 2;\n-(-x);\n!(!x);\n"},
+		{"x = (y = 1); (x ?? y) ?? z", "x = y = 1;\n(x ?? y) ?? z;\n"},
+		{"int   x   =   0x1F;?List<int> xs=null", "int x = 0x1F;\n?List<int> xs = null;\n"},
+		{`let s = "a ${ x+1 } \t b";`, "let s = \"a ${x + 1} \\t b\";\n"},
+		{"f ( a , b ) ( c )", "f(a, b)(c);\n"},
+		{"let xs = [ 1,2 , [3] ] ; xs [ 0 ] [1 : ]", "let xs = [1, 2, [3]];\nxs[0][1:];\n"},
+		{`let m = {"a" :1, 2:(x + 1)}; m["a"]`, "let m = {\"a\": 1, 2: x + 1};\nm[\"a\"];\n"},
+		{"(-a)[0]; -(a[0]); f(x)[0](y); (a[:])[ : n-1]", "(-a)[0];\n-a[0];\nf(x)[0](y);\na[:][:n - 1];\n"},
+		{"x ++; -(y--)", "x++;\n-y--;\n"},
+		{"if a { b }; [1][0]", "if a {\n    b\n};\n[1][0];\n"},
+		{"Array<int> xs = []; Map<string, int> m = {}", "Array<int> xs = [];\nMap<string, int> m = {};\n"},
+		{"let f = fn(int a, b) int { return a + b }", "let f = fn(int a, b) int {\n    return a + b;\n};\n"},
+		{"fn(){}", "fn() {};\n"},
+		{"if x { y } else if z { w } else { v }",
+			"if x {\n    y\n} else if z {\n    w\n} else {\n    v\n}\n"},
+		{"if a { b; c; }\nd", "if a {\n    b;\n    c\n}\nd;\n"},
+		{"if a { b }; -c", "if a {\n    b\n};\n-c;\n"},
+		{"let f = fn() { if a { return 1; } return 2 }",
+			"let f = fn() {\n    if a {\n        return 1;\n    }\n    return 2;\n};\n"},
+		{"\n\nlet a = 1;\n\n\n\nlet b = 2;\nlet c = 3;\n\n", "let a = 1;\n\nlet b = 2;\nlet c = 3;\n"},
+	}
+
+	for _, tt := range tests {
+		out, diags := Source([]byte(tt.input), "fmttest")
+		if len(diags) > 0 {
+			t.Errorf("%q: unexpected errors: %v", tt.input, diags)
+			continue
+		}
+		if string(out) != tt.expected {
+			t.Errorf("%q:\nexpected=%q\ngot=     %q", tt.input, tt.expected, out)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"// header\n\nlet x = 1; // trailing\n// before y\nlet y = 2;",
+			"// header\n\nlet x = 1; // trailing\n// before y\nlet y = 2;\n"},
+		{"let f = fn() { // opens\n  // inside\n  a\n  // end\n}",
+			"let f = fn() { // opens\n    // inside\n    a\n    // end\n};\n"},
+		{"if a {\n// only a comment\n}", "if a {\n    // only a comment\n}\n"},
+		{"let x = /* inline */ 1;\n/* block\n   comment */\nx", "let x = 1; /* inline */\n/* block\n   comment */\nx;\n"},
+		{"a // last   ", "a; // last\n"},
+		{"a\n\n// detached\n\nb", "a;\n\n// detached\n\nb;\n"},
+	}
+
+	for _, tt := range tests {
+		out, diags := Source([]byte(tt.input), "fmttest")
+		if len(diags) > 0 {
+			t.Errorf("%q: unexpected errors: %v", tt.input, diags)
+			continue
+		}
+		if string(out) != tt.expected {
+			t.Errorf("%q:\nexpected=%q\ngot=     %q", tt.input, tt.expected, out)
+		}
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	out, diags := Source([]byte("let x = ;"), "fmttest")
+	if out != nil || !diags.HasErrors() {
+		t.Errorf("expected syntax errors and no output. got=%q, %v", out, diags)
+	}
+}
+
+// TestIdempotent formats every input again and checks that the output
+// does not change and still parses to the same program.
+func TestIdempotent(t *testing.T) {
+	inputs := []string{
+		"let x=1+2*3",
+		"if a { b }; -c; if d { e } f",
+		"let f = fn(fn(int) bool p, ?T x) fn() T { /* a */ return p; // b\n}; f(fn(x) { x })(1)",
+		"// c1\nlet a = 1 /* c2 */ + /* c3 */ 2; // c4\n\n\n/* c5 */ let b = if a > 1 { // c6\n\n a } else { /* c7 */ };",
+		"x = y = z ?? w ?? 1; a && b || c ^^ !d; 1 << 2 >> 3 & 4 | 5 ^ ~6",
+	}
+
+	files, _ := filepath.Glob("../examples/*.chp")
+	for _, f := range files {
+		src, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, string(src))
+	}
+
+	for _, input := range inputs {
+		once, diags := Source([]byte(input), "fmttest")
+		if len(diags) > 0 {
+			t.Errorf("%q: unexpected errors: %v", input, diags)
+			continue
+		}
+		twice, diags := Source(once, "fmttest")
+		if len(diags) > 0 {
+			t.Errorf("%q: formatted output does not parse: %v\n%s", input, diags, once)
+			continue
+		}
+		if string(once) != string(twice) {
+			t.Errorf("%q: formatting is not idempotent.\nfirst:\n%s\nsecond:\n%s", input, once, twice)
+		}
+		if parse(input) != parse(string(once)) {
+			t.Errorf("%q: formatting changed the program.\nbefore=%s\nafter= %s", input, parse(input), parse(string(once)))
+		}
+	}
+}
+
+func parse(input string) string {
+	return parser.New(lexer.New(input, "fmttest")).ParseProgram().String()
+}
@@ -2,10 +2,12 @@ module chimp
 
 go 1.19
 
-require github.com/chzyer/readline v1.5.1
+require (
+	github.com/chzyer/readline v1.5.1
+	golang.org/x/text v0.14.0
+)
 
 require (
 	github.com/mattn/go-runewidth v0.0.3 // indirect
 	golang.org/x/sys v0.5.0 // indirect
-	golang.org/x/text v0.14.0 // indirect
 )
@@ -0,0 +1,64 @@
+package lexer
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Identifiers follow Unicode Standard Annex #31: they start with a
+// character in XID_Start or '_', and go on with characters in
+// XID_Continue. The unicode package has the tables these properties are
+// derived from, but not the properties themselves.
+
+// idStart and idContinue are the characters added to the letters and
+// digits by Other_ID_Start and Other_ID_Continue.
+var (
+	idStart    = []*unicode.RangeTable{unicode.L, unicode.Nl, unicode.Other_ID_Start}
+	idContinue = []*unicode.RangeTable{
+		unicode.L, unicode.Nl, unicode.Other_ID_Start,
+		unicode.Mn, unicode.Mc, unicode.Nd, unicode.Pc, unicode.Other_ID_Continue,
+	}
+	notID = []*unicode.RangeTable{unicode.Pattern_Syntax, unicode.Pattern_White_Space}
+)
+
+// notXID holds the characters in ID_Start or ID_Continue that the XID
+// properties leave out, because their NFKC forms are not identifiers.
+var notXID = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x037a, 0x037a, 1},
+		{0x0e33, 0x0e33, 1},
+		{0x0eb3, 0x0eb3, 1},
+		{0x309b, 0x309c, 1},
+		{0xfc5e, 0xfc63, 1},
+		{0xfdfa, 0xfdfb, 1},
+		{0xfe70, 0xfe7e, 2},
+		{0xff9e, 0xff9f, 1},
+	},
+}
+
+// notXIDContinue holds the characters of notXID that are still in
+// XID_Continue.
+var notXIDContinue = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x0e33, 0x0e33, 1},
+		{0x0eb3, 0x0eb3, 1},
+		{0xff9e, 0xff9f, 1},
+	},
+}
+
+func isIdentStart(char rune) bool {
+	if char < utf8.RuneSelf {
+		return char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z' || char == '_'
+	}
+	return unicode.In(char, idStart...) && !unicode.In(char, notID...) && !unicode.Is(notXID, char)
+}
+
+func isIdentContinue(char rune) bool {
+	if char < utf8.RuneSelf {
+		return isIdentStart(char) || isDigit(char)
+	}
+	if unicode.Is(notXID, char) {
+		return unicode.Is(notXIDContinue, char)
+	}
+	return unicode.In(char, idContinue...) && !unicode.In(char, notID...)
+}
@@ -1,137 +1,169 @@
 package lexer
 
 import (
+	"bufio"
+	"chimp/diag"
 	"chimp/token"
-	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Mode controls optional lexer behavior.
+type Mode uint
+
+const (
+	// ScanComments returns comments as COMMENT tokens instead of skipping
+	// them.
+	ScanComments Mode = 1 << iota
 )
 
 type Lexer struct {
-	input    []rune
+	Mode Mode
+
+	src      io.RuneReader
+	err      error
 	Filename string
-	pos      int
+	offset   int
 	Line     int
+	column   int
 	char     rune
-	Errors   []string
+	width    int // bytes of char in the input; 0 at the end of the input
+	Errors   diag.List
+
+	// ahead holds characters decoded by nextNthChar that readChar has not
+	// reached yet.
+	ahead []decoded
+
+	// lit, when set, collects the characters readChar moves past.
+	lit *strings.Builder
+
+	// interps holds, for each string interpolation we are inside of, the
+	// number of unclosed '{' seen since its "${".
+	interps []int
+}
+
+type decoded struct {
+	char  rune
+	width int
 }
 
 func New(input string, filename string) *Lexer {
-	l := &Lexer{input: []rune(input), Filename: filename, Line: 1}
-	l.char = l.input[0]
+	return NewReader(strings.NewReader(input), filename)
+}
+
+// NewReader returns a lexer that reads its input from r as it goes, so
+// the input never has to be in memory all at once. Invalid UTF-8 is
+// reported in Errors and read as utf8.RuneError.
+func NewReader(r io.Reader, filename string) *Lexer {
+	src, ok := r.(io.RuneReader)
+	if !ok {
+		src = bufio.NewReader(r)
+	}
+
+	l := &Lexer{src: src, Filename: filename, Line: 1, column: 1}
+	l.advance()
 
 	return l
 }
 
+// Err returns the first error reading the input, other than io.EOF. The
+// lexer stops at such an error as if the input ended there.
+func (l *Lexer) Err() error {
+	return l.err
+}
+
+// NextToken returns the next token in the input, with Pos and End set to
+// the span of source it was read from.
 func (l *Lexer) NextToken() token.Token {
-	l.skipSpace()
+	l.skipTrivia()
+
+	start := l.position()
+	tok := l.readToken()
+	tok.Pos = start
+	tok.End = l.position()
+
+	return tok
+}
+
+func (l *Lexer) position() token.Position {
+	return token.Position{
+		Filename: l.Filename,
+		Offset:   l.offset,
+		Line:     l.Line,
+		Column:   l.column,
+	}
+}
+
+// skipTrivia skips whitespace and comments up to the start of the next
+// token. In ScanComments mode comments are tokens, and only whitespace is
+// skipped.
+func (l *Lexer) skipTrivia() {
+	for {
+		l.skipSpace()
+		if l.Mode&ScanComments != 0 {
+			return
+		}
+		switch {
+		case l.char == '/' && l.nextChar() == '/':
+			l.skipLineComment()
+		case l.char == '/' && l.nextChar() == '*':
+			l.skipBlockComment()
+		default:
+			return
+		}
+	}
+}
+
+func (l *Lexer) readToken() token.Token {
+	if l.atEOF() {
+		return newToken(token.EOF, "<eof>")
+	}
 
 	if isDigit(l.char) {
-		return newToken(token.INT, l.readNum())
+		return l.readNumber()
 	}
 
-	if isAlpha(l.char) {
+	if isIdentStart(l.char) {
 		return l.readIdent()
 	}
 
-	//two character statements
-	twoCharStr := string(l.char) + string(l.nextChar())
-	switch twoCharStr {
-	case "<=":
-		l.readChar()
-		l.readChar()
-		return newToken(token.LTEQ, twoCharStr)
-	case "==":
-		l.readChar()
-		l.readChar()
-		return newToken(token.EQ, twoCharStr)
-	case ">=":
-		l.readChar()
-		l.readChar()
-		return newToken(token.GTEQ, twoCharStr)
-	case "!=":
-		l.readChar()
-		l.readChar()
-		return newToken(token.NOTEQ, twoCharStr)
-	case "&&":
-		l.readChar()
-		l.readChar()
-		return newToken(token.BOOLAND, twoCharStr)
-	case "||":
-		l.readChar()
-		l.readChar()
-		return newToken(token.BOOLOR, twoCharStr)
-	case "^^":
-		l.readChar()
-		l.readChar()
-		return newToken(token.BOOLXOR, twoCharStr)
-	case ">>":
-		l.readChar()
-		l.readChar()
-		return newToken(token.RBITSHIFT, twoCharStr)
-	case "<<":
-		l.readChar()
-		l.readChar()
-		return newToken(token.LBITSHIFT, twoCharStr)
-	case "??":
-		l.readChar()
-		l.readChar()
-		return newToken(token.COALESCE, twoCharStr)
-	case "//":
-		l.skipLineComment()
-		return l.NextToken()
-	case "/*":
-		l.skipBlockComment()
-		return l.NextToken()
+	if l.char == '/' && (l.nextChar() == '/' || l.nextChar() == '*') {
+		return l.readComment()
+	}
 
+	switch l.char {
+	case '"':
+		return l.readString(token.STRING, token.STRING_HEAD)
+	case '`':
+		return l.readRawString()
+	case '}':
+		if n := len(l.interps); n > 0 && l.interps[n-1] == 0 {
+			l.interps = l.interps[:n-1]
+			return l.readString(token.STRING_TAIL, token.STRING_MID)
+		}
 	}
 
 	var tok token.Token
 
-	charStr := string(l.char)
 	switch l.char {
-	case '=':
-		tok = newToken(token.ASSIGN, charStr)
-	case '+':
-		tok = newToken(token.PLUS, charStr)
-	case '-':
-		tok = newToken(token.MINUS, charStr)
-	case '*':
-		tok = newToken(token.STAR, charStr)
-	case '/':
-		tok = newToken(token.SLASH, charStr)
-	case ',':
-		tok = newToken(token.COMMA, charStr)
-	case '(':
-		tok = newToken(token.LPAREN, charStr)
-	case ')':
-		tok = newToken(token.RPAREN, charStr)
 	case '{':
-		tok = newToken(token.LBRACE, charStr)
+		if n := len(l.interps); n > 0 {
+			l.interps[n-1]++
+		}
+		tok = newToken(token.LBRACE, "{")
 	case '}':
-		tok = newToken(token.RBRACE, charStr)
-	case ';':
-		tok = newToken(token.SEMICOLON, charStr)
-	case '<':
-		tok = newToken(token.LT, charStr)
-	case '>':
-		tok = newToken(token.GT, charStr)
-	case '&':
-		tok = newToken(token.BITAND, charStr)
-	case '|':
-		tok = newToken(token.BITOR, charStr)
-	case '^':
-		tok = newToken(token.BITXOR, charStr)
-	case '~':
-		tok = newToken(token.BITNOT, charStr)
-	case '!':
-		tok = newToken(token.BANG, charStr)
-	case '#':
-		tok = newToken(token.HASH, charStr)
-	case '?':
-		tok = newToken(token.QUESTION, charStr)
-	case 0:
-		tok = newToken(token.EOF, "<eof>")
+		if n := len(l.interps); n > 0 {
+			l.interps[n-1]--
+		}
+		tok = newToken(token.RBRACE, "}")
 	default:
-		tok = newToken(token.ILLEGAL, charStr)
+		if tok, ok := l.readOperator(); ok {
+			return tok
+		}
+		tok = newToken(token.ILLEGAL, string(l.char))
 	}
 
 	l.readChar()
@@ -139,11 +171,56 @@ func (l *Lexer) NextToken() token.Token {
 	return tok
 }
 
-func (l *Lexer) skipSpace() {
-	for isSpace(l.char) {
-		if l.char == '\n' {
-			l.Line++
+// operators maps each operator and delimiter, other than braces, to its
+// token type.
+var operators = map[string]token.TokenType{
+	"=": token.ASSIGN, "+": token.PLUS, "-": token.MINUS, "*": token.STAR, "/": token.SLASH,
+	"**": token.DOUBLESTAR,
+
+	"<": token.LT, "<=": token.LTEQ, "==": token.EQ, ">=": token.GTEQ, ">": token.GT,
+	"!=": token.NOTEQ, "&&": token.BOOLAND, "||": token.BOOLOR, "^^": token.BOOLXOR,
+	"!": token.BANG,
+
+	"&": token.BITAND, "|": token.BITOR, "^": token.BITXOR, "~": token.BITNOT,
+	"<<": token.LBITSHIFT, ">>": token.RBITSHIFT,
+
+	"#": token.HASH, "?": token.QUESTION, "??": token.COALESCE,
+	"->": token.ARROW, "=>": token.FAT_ARROW, "++": token.INCREMENT, "--": token.DECREMENT,
+
+	"+=": token.PLUS_ASSIGN, "-=": token.MINUS_ASSIGN, "*=": token.STAR_ASSIGN,
+	"/=": token.SLASH_ASSIGN, "**=": token.DOUBLESTAR_ASSIGN, "&=": token.BITAND_ASSIGN,
+	"|=": token.BITOR_ASSIGN, "^=": token.BITXOR_ASSIGN, "<<=": token.LBITSHIFT_ASSIGN,
+	">>=": token.RBITSHIFT_ASSIGN,
+
+	",": token.COMMA, ";": token.SEMICOLON, ":": token.COLON, ".": token.DOT,
+	"(": token.LPAREN, ")": token.RPAREN, "[": token.LBRACKET, "]": token.RBRACKET,
+}
+
+// maxOperatorLen is the length of the longest operator.
+const maxOperatorLen = 3
+
+// readOperator reads the longest operator the input starts with, and
+// reports whether there was one.
+func (l *Lexer) readOperator() (token.Token, bool) {
+	chars := []rune{l.char}
+	for n := 1; n < maxOperatorLen; n++ {
+		chars = append(chars, l.nextNthChar(n))
+	}
+
+	for n := len(chars); n > 0; n-- {
+		op := string(chars[:n])
+		if tokType, ok := operators[op]; ok {
+			for i := 0; i < n; i++ {
+				l.readChar()
+			}
+			return newToken(tokType, op), true
 		}
+	}
+	return token.Token{}, false
+}
+
+func (l *Lexer) skipSpace() {
+	for isSpace(l.char) || l.invalid() {
 		l.readChar()
 	}
 }
@@ -153,8 +230,67 @@ func isSpace(char rune) bool {
 }
 
 func (l *Lexer) readChar() {
-	l.char = l.nextChar()
-	l.pos++
+	if !l.atEOF() {
+		if l.lit != nil {
+			l.lit.WriteRune(l.char)
+		}
+		if l.char == '\n' {
+			l.Line++
+			l.column = 1
+		} else {
+			l.column++
+		}
+		l.offset += l.width
+	}
+	l.advance()
+}
+
+// advance makes the next character of the input the current one.
+func (l *Lexer) advance() {
+	var next decoded
+	if len(l.ahead) > 0 {
+		next = l.ahead[0]
+		l.ahead = l.ahead[1:]
+	} else {
+		next = l.decode()
+	}
+	l.char, l.width = next.char, next.width
+
+	if l.invalid() {
+		start := l.position()
+		end := start
+		end.Offset++
+		end.Column++
+		l.Errors.Add(diag.InvalidUTF8, diag.Span{Start: start, End: end}, "invalid UTF-8 encoding")
+	}
+}
+
+// decode reads the next character from the input, or returns a zero
+// width character at its end.
+func (l *Lexer) decode() decoded {
+	if l.src == nil {
+		return decoded{}
+	}
+
+	char, width, err := l.src.ReadRune()
+	if err != nil {
+		if err != io.EOF {
+			l.err = err
+		}
+		l.src = nil
+		return decoded{}
+	}
+	return decoded{char, width}
+}
+
+// invalid reports whether the current character is a byte that is not
+// valid UTF-8.
+func (l *Lexer) invalid() bool {
+	return l.char == utf8.RuneError && l.width == 1
+}
+
+func (l *Lexer) atEOF() bool {
+	return l.width == 0
 }
 
 func (l *Lexer) nextChar() rune {
@@ -162,53 +298,49 @@ func (l *Lexer) nextChar() rune {
 }
 
 func (l *Lexer) nextNthChar(n int) rune {
-	if l.pos+n >= len(l.input) {
-		return 0
+	for len(l.ahead) < n {
+		l.ahead = append(l.ahead, l.decode())
 	}
 
-	return l.input[l.pos+n]
+	return l.ahead[n-1].char
 }
 
 func isDigit(char rune) bool {
 	return char >= '0' && char <= '9'
 }
 
-func (l *Lexer) readNum() string {
-	numStr := ""
-	for isDigit(l.char) {
-		numStr += string(l.char)
+// readIdent reads an identifier or keyword. Identifiers are put in
+// Unicode normal form C, so names that look the same are the same.
+func (l *Lexer) readIdent() token.Token {
+	var ident strings.Builder
+	for isIdentContinue(l.char) {
+		ident.WriteRune(l.char)
 		l.readChar()
 	}
-	return numStr
+	name := norm.NFC.String(ident.String())
+	return newToken(token.MatchIdent(name), name)
 }
 
-func isAlpha(char rune) bool {
-	return char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z'
-}
+func (l *Lexer) readComment() token.Token {
+	l.lit = &strings.Builder{}
+	defer func() { l.lit = nil }()
 
-func (l *Lexer) readIdent() token.Token {
-	ident := string(l.char)
-	l.readChar()
-	for isAlnum(l.char) || l.char == '_' {
-		ident += string(l.char)
-		l.readChar()
+	if l.nextChar() == '/' {
+		l.skipLineComment()
+	} else {
+		l.skipBlockComment()
 	}
-	tokType := token.MatchIdent(ident)
-	return newToken(tokType, ident)
-}
-
-func isAlnum(char rune) bool {
-	return isDigit(char) || isAlpha(char)
+	return newToken(token.COMMENT, l.lit.String())
 }
 
 func (l *Lexer) skipLineComment() {
-	for l.char != '\n' && l.char != 0 {
+	for l.char != '\n' && !l.atEOF() {
 		l.readChar()
 	}
 }
 
 func (l *Lexer) skipBlockComment() {
-	start_Line := l.Line
+	start := l.position()
 	l.readChar()
 	l.readChar()
 	for {
@@ -219,15 +351,25 @@ func (l *Lexer) skipBlockComment() {
 		}
 		if l.char == '/' && l.nextChar() == '*' {
 			l.skipBlockComment()
+			continue
 		}
-		if l.char == 0 {
-			l.Errors = append(l.Errors, fmt.Sprintf("Syntax Error:%d: Unterminated block bomment before end of file.\n", start_Line))
+		if l.atEOF() {
+			l.errorf(diag.UnterminatedComment, start, "unterminated block comment").Fix = &diag.Fix{
+				Span:        diag.At(l.position()),
+				Replacement: "*/",
+				Message:     "close the comment",
+			}
 			break
 		}
 		l.readChar()
 	}
 }
 
+// errorf records a diagnostic spanning from start to the current position.
+func (l *Lexer) errorf(code diag.Code, start token.Position, format string, args ...interface{}) *diag.Diagnostic {
+	return l.Errors.Add(code, diag.Span{Start: start, End: l.position()}, format, args...)
+}
+
 func newToken(Type token.TokenType, Literal string) token.Token {
 	return token.Token{Type: Type, Literal: Literal}
 }
@@ -2,6 +2,7 @@ package lexer
 
 import (
 	"chimp/token"
+	"io"
 
 	"testing"
 )
@@ -79,3 +80,465 @@ let result = add(five, ten);
 		}
 	}
 }
+
+func TestOperators(t *testing.T) {
+	input := `[a]: b.c -> d => e += -= *= /= **= &= |= ^= <<= >>= ++ -- ***= <<<= >>== a--b !!= @`
+
+	expTokens := []struct {
+		expType    token.TokenType
+		expLiteral string
+	}{
+		{token.LBRACKET, "["},
+		{token.IDENT, "a"},
+		{token.RBRACKET, "]"},
+		{token.COLON, ":"},
+		{token.IDENT, "b"},
+		{token.DOT, "."},
+		{token.IDENT, "c"},
+		{token.ARROW, "->"},
+		{token.IDENT, "d"},
+		{token.FAT_ARROW, "=>"},
+		{token.IDENT, "e"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.MINUS_ASSIGN, "-="},
+		{token.STAR_ASSIGN, "*="},
+		{token.SLASH_ASSIGN, "/="},
+		{token.DOUBLESTAR_ASSIGN, "**="},
+		{token.BITAND_ASSIGN, "&="},
+		{token.BITOR_ASSIGN, "|="},
+		{token.BITXOR_ASSIGN, "^="},
+		{token.LBITSHIFT_ASSIGN, "<<="},
+		{token.RBITSHIFT_ASSIGN, ">>="},
+		{token.INCREMENT, "++"},
+		{token.DECREMENT, "--"},
+		{token.DOUBLESTAR, "**"},
+		{token.STAR_ASSIGN, "*="},
+		{token.LBITSHIFT, "<<"},
+		{token.LTEQ, "<="},
+		{token.RBITSHIFT_ASSIGN, ">>="},
+		{token.ASSIGN, "="},
+		{token.IDENT, "a"},
+		{token.DECREMENT, "--"},
+		{token.IDENT, "b"},
+		{token.BANG, "!"},
+		{token.NOTEQ, "!="},
+		{token.ILLEGAL, "@"},
+		{token.EOF, "<eof>"},
+	}
+
+	l := New(input, "ops.chp")
+
+	for index, testTok := range expTokens {
+		tok := l.NextToken()
+
+		if tok.Type != testTok.expType || tok.Literal != testTok.expLiteral {
+			t.Fatalf("tests[%d] - expected=%q %q. got=%q %q",
+				index, testTok.expType, testTok.expLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestTokenPositions(t *testing.T) {
+	input := `let x = 10;
+/* a
+   comment */ x <= 2;
+`
+	expTokens := []struct {
+		expType   token.TokenType
+		expPos    token.Position
+		expEndCol int
+	}{
+		{token.LET, token.Position{Filename: "pos.chp", Offset: 0, Line: 1, Column: 1}, 4},
+		{token.IDENT, token.Position{Filename: "pos.chp", Offset: 4, Line: 1, Column: 5}, 6},
+		{token.ASSIGN, token.Position{Filename: "pos.chp", Offset: 6, Line: 1, Column: 7}, 8},
+		{token.INT, token.Position{Filename: "pos.chp", Offset: 8, Line: 1, Column: 9}, 11},
+		{token.SEMICOLON, token.Position{Filename: "pos.chp", Offset: 10, Line: 1, Column: 11}, 12},
+		{token.IDENT, token.Position{Filename: "pos.chp", Offset: 31, Line: 3, Column: 15}, 16},
+		{token.LTEQ, token.Position{Filename: "pos.chp", Offset: 33, Line: 3, Column: 17}, 19},
+		{token.INT, token.Position{Filename: "pos.chp", Offset: 36, Line: 3, Column: 20}, 21},
+		{token.SEMICOLON, token.Position{Filename: "pos.chp", Offset: 37, Line: 3, Column: 21}, 22},
+		{token.EOF, token.Position{Filename: "pos.chp", Offset: 39, Line: 4, Column: 1}, 1},
+	}
+
+	l := New(input, "pos.chp")
+
+	for index, testTok := range expTokens {
+		tok := l.NextToken()
+
+		if tok.Type != testTok.expType {
+			t.Fatalf("tests[%d] - wrong tokentype. expected=%q, got=%q",
+				index, testTok.expType, tok.Type)
+		}
+
+		if tok.Pos != testTok.expPos {
+			t.Fatalf("tests[%d] - wrong position. expected=%#v, got=%#v",
+				index, testTok.expPos, tok.Pos)
+		}
+
+		if tok.End.Column != testTok.expEndCol {
+			t.Fatalf("tests[%d] - wrong end column. expected=%d, got=%d",
+				index, testTok.expEndCol, tok.End.Column)
+		}
+	}
+}
+
+func TestScanComments(t *testing.T) {
+	input := `// leading
+let x = 1; /* a /* nested */ comment */ x
+/* unterminated`
+
+	tests := []struct {
+		expType    token.TokenType
+		expLiteral string
+		expLine    int
+	}{
+		{token.COMMENT, "// leading", 1},
+		{token.LET, "let", 2},
+		{token.IDENT, "x", 2},
+		{token.ASSIGN, "=", 2},
+		{token.INT, "1", 2},
+		{token.SEMICOLON, ";", 2},
+		{token.COMMENT, "/* a /* nested */ comment */", 2},
+		{token.IDENT, "x", 2},
+		{token.COMMENT, "/* unterminated", 3},
+		{token.EOF, "<eof>", 3},
+	}
+
+	l := New(input, "comments.chp")
+	l.Mode = ScanComments
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expType || tok.Literal != tt.expLiteral {
+			t.Fatalf("tests[%d]: expected=%s %q. got=%s %q", i, tt.expType, tt.expLiteral, tok.Type, tok.Literal)
+		}
+		if tok.Pos.Line != tt.expLine {
+			t.Fatalf("tests[%d]: line: expected=%d. got=%d", i, tt.expLine, tok.Pos.Line)
+		}
+	}
+	if len(l.Errors) != 1 || l.Errors[0].Message != "unterminated block comment" {
+		t.Errorf("errors: expected the unterminated comment. got=%v", l.Errors)
+	}
+}
+
+func TestSkipsComments(t *testing.T) {
+	l := New("a /* b /* c */ d */ e // f\ng", "comments.chp")
+
+	for _, exp := range []string{"a", "e", "g"} {
+		if tok := l.NextToken(); tok.Literal != exp {
+			t.Fatalf("expected=%q. got=%q", exp, tok.Literal)
+		}
+	}
+}
+
+func TestStrings(t *testing.T) {
+	input := "\"hello world\" \"tab\\there\\n\" \"q\\\"\\\\\" \"\\u{1F600}\\$x\" `raw\n\\n ${x}`" +
+		" \"a ${x + 1} b ${ {} } c\" \"${\"in${y}\"}\""
+
+	expTokens := []struct {
+		expType    token.TokenType
+		expLiteral string
+	}{
+		{token.STRING, "hello world"},
+		{token.STRING, "tab\there\n"},
+		{token.STRING, "q\"\\"},
+		{token.STRING, "\U0001F600$x"},
+		{token.RAW_STRING, "raw\n\\n ${x}"},
+		{token.STRING_HEAD, "a "},
+		{token.IDENT, "x"},
+		{token.PLUS, "+"},
+		{token.INT, "1"},
+		{token.STRING_MID, " b "},
+		{token.LBRACE, "{"},
+		{token.RBRACE, "}"},
+		{token.STRING_TAIL, " c"},
+		{token.STRING_HEAD, ""},
+		{token.STRING_HEAD, "in"},
+		{token.IDENT, "y"},
+		{token.STRING_TAIL, ""},
+		{token.STRING_TAIL, ""},
+		{token.EOF, "<eof>"},
+	}
+
+	l := New(input, "strings.chp")
+
+	for index, testTok := range expTokens {
+		tok := l.NextToken()
+
+		if tok.Type != testTok.expType {
+			t.Fatalf("tests[%d] - wrong tokentype. expected=%q, got=%q",
+				index, testTok.expType, tok.Type)
+		}
+
+		if tok.Literal != testTok.expLiteral {
+			t.Fatalf("tests[%d] - wrong tokenliteral. expected=%q, got=%q",
+				index, testTok.expLiteral, tok.Literal)
+		}
+	}
+
+	if len(l.Errors) != 0 {
+		t.Fatalf("l.Errors: expected none. got=%q", l.Errors)
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expError string
+	}{
+		{"let s = \"abc\nlet", "err.chp:1:9: error[L0002]: unterminated string literal"},
+		{"\n  `abc", "err.chp:2:3: error[L0002]: unterminated raw string literal"},
+		{`"\q"`, "err.chp:1:2: error[L0003]: unknown escape sequence '\\q'"},
+		{`"\u{110000}"`, "err.chp:1:2: error[L0004]: invalid unicode escape '\\u{110000}'"},
+		{"/* /* */", "err.chp:1:1: error[L0001]: unterminated block comment"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input, "err.chp")
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		}
+
+		if len(l.Errors) != 1 {
+			t.Fatalf("l.Errors: expected 1 error. got=%v", l.Errors)
+		}
+		if l.Errors[0].Error() != tt.expError {
+			t.Errorf("l.Errors[0]: expected=%q. got=%q", tt.expError, l.Errors[0].Error())
+		}
+	}
+}
+
+func TestNumbers(t *testing.T) {
+	input := "0 42 1_000_000 0xFF 0x_dead_BEEF 0o755 0b1010 1.5 1.5e-3 2E10 3e+2 7.x"
+
+	expTokens := []struct {
+		expType    token.TokenType
+		expLiteral string
+	}{
+		{token.INT, "0"},
+		{token.INT, "42"},
+		{token.INT, "1_000_000"},
+		{token.HEX_INT, "0xFF"},
+		{token.HEX_INT, "0x_dead_BEEF"},
+		{token.OCT_INT, "0o755"},
+		{token.BIN_INT, "0b1010"},
+		{token.FLOAT, "1.5"},
+		{token.FLOAT, "1.5e-3"},
+		{token.FLOAT, "2E10"},
+		{token.FLOAT, "3e+2"},
+		{token.INT, "7"},
+		{token.DOT, "."},
+		{token.IDENT, "x"},
+		{token.EOF, "<eof>"},
+	}
+
+	l := New(input, "numbers.chp")
+
+	for index, testTok := range expTokens {
+		tok := l.NextToken()
+
+		if tok.Type != testTok.expType {
+			t.Fatalf("tests[%d] - wrong tokentype. expected=%q, got=%q",
+				index, testTok.expType, tok.Type)
+		}
+
+		if tok.Literal != testTok.expLiteral {
+			t.Fatalf("tests[%d] - wrong tokenliteral. expected=%q, got=%q",
+				index, testTok.expLiteral, tok.Literal)
+		}
+	}
+
+	if len(l.Errors) != 0 {
+		t.Fatalf("l.Errors: expected none. got=%q", l.Errors)
+	}
+}
+
+func TestMalformedNumbers(t *testing.T) {
+	tests := []struct {
+		input      string
+		expLiteral string
+		expError   string
+	}{
+		{"0x;", "0x", "hexadecimal literal has no digits"},
+		{"1__0;", "1__0", "'_' must separate successive digits"},
+		{"10_;", "10_", "'_' must separate successive digits"},
+		{"1_.5;", "1_.5", "'_' must separate successive digits"},
+		{"0b102;", "0b102", "invalid character '2' in binary literal"},
+		{"0o8;", "0o8", "octal literal has no digits"},
+		{"12abc;", "12abc", "invalid character 'a' in decimal literal"},
+		{"1e;", "1e", "exponent has no digits"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input, "bad.chp")
+
+		tok := l.NextToken()
+		if tok.Literal != tt.expLiteral {
+			t.Errorf("%q: wrong tokenliteral. expected=%q, got=%q", tt.input, tt.expLiteral, tok.Literal)
+		}
+		if next := l.NextToken(); next.Type != token.SEMICOLON {
+			t.Errorf("%q: literal was split. next token=%q", tt.input, next.Literal)
+		}
+
+		if len(l.Errors) == 0 {
+			t.Errorf("%q: expected an error. got none", tt.input)
+			continue
+		}
+		expError := "bad.chp:1:1: error[L0005]: malformed number: " + tt.expError
+		if l.Errors[0].Error() != expError {
+			t.Errorf("%q: l.Errors[0]: expected=%q. got=%q", tt.input, expError, l.Errors[0].Error())
+		}
+		if end := l.Errors[0].Span.End.Column; end != len(tt.expLiteral)+1 {
+			t.Errorf("%q: l.Errors[0].Span.End.Column: expected=%d. got=%d", tt.input, len(tt.expLiteral)+1, end)
+		}
+	}
+}
+
+func TestEmptyInput(t *testing.T) {
+	for _, input := range []string{"", "   \n", "// only a comment"} {
+		l := New(input, "empty.chp")
+		for i := 0; i < 2; i++ {
+			if tok := l.NextToken(); tok.Type != token.EOF {
+				t.Errorf("%q: expected EOF. got=%q", input, tok.Type)
+			}
+		}
+		if len(l.Errors) != 0 {
+			t.Errorf("%q: l.Errors: expected none. got=%q", input, l.Errors)
+		}
+	}
+}
+
+// oneByteReader returns a byte at a time, so characters are split across
+// reads.
+type oneByteReader struct {
+	data []byte
+}
+
+func (r *oneByteReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	p[0] = r.data[0]
+	r.data = r.data[1:]
+	return 1, nil
+}
+
+func TestNewReader(t *testing.T) {
+	input := "let café = \"naïve\"; // ünïcode\n🐒 + 1"
+	want := New(input, "reader.chp")
+	l := NewReader(&oneByteReader{[]byte(input)}, "reader.chp")
+	l.Mode = ScanComments
+	want.Mode = ScanComments
+
+	for {
+		exp, tok := want.NextToken(), l.NextToken()
+		if tok != exp {
+			t.Fatalf("expected=%+v. got=%+v", exp, tok)
+		}
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+	if l.Err() != nil {
+		t.Errorf("l.Err(): expected nil. got=%s", l.Err())
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	l := New("let x\xff = \"a\xc3b\";", "utf8.chp")
+
+	expTokens := []struct {
+		expType    token.TokenType
+		expLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.STRING, "a�b"},
+		{token.SEMICOLON, ";"},
+		{token.EOF, "<eof>"},
+	}
+	for i, tt := range expTokens {
+		tok := l.NextToken()
+		if tok.Type != tt.expType || tok.Literal != tt.expLiteral {
+			t.Fatalf("tests[%d] - expected=%q %q. got=%q %q", i, tt.expType, tt.expLiteral, tok.Type, tok.Literal)
+		}
+	}
+
+	expErrors := []string{
+		"utf8.chp:1:6: error[L0006]: invalid UTF-8 encoding",
+		"utf8.chp:1:12: error[L0006]: invalid UTF-8 encoding",
+	}
+	if len(l.Errors) != len(expErrors) {
+		t.Fatalf("l.Errors: expected %d. got=%q", len(expErrors), l.Errors)
+	}
+	for i, exp := range expErrors {
+		if got := l.Errors[i].Error(); got != exp {
+			t.Errorf("l.Errors[%d]: expected=%q. got=%q", i, exp, got)
+		}
+	}
+}
+
+func TestNulIsIllegal(t *testing.T) {
+	l := New("1\x002", "nul.chp")
+
+	for _, exp := range []token.TokenType{token.INT, token.ILLEGAL, token.INT, token.EOF} {
+		if tok := l.NextToken(); tok.Type != exp {
+			t.Fatalf("expected=%q. got=%q", exp, tok.Type)
+		}
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	tests := []struct {
+		input      string
+		expType    token.TokenType
+		expLiteral string
+	}{
+		{"_private", token.IDENT, "_private"},
+		{"__", token.IDENT, "__"},
+		{"caf\u00e9", token.IDENT, "caf\u00e9"},
+		{"cafe\u0301", token.IDENT, "caf\u00e9"},
+		{"λx", token.IDENT, "λx"},
+		{"日本語", token.IDENT, "日本語"},
+		{"переменная_1", token.IDENT, "переменная_1"},
+		{"\uff8d\uff9f", token.IDENT, "\uff8d\uff9f"},
+		{"\u0301a", token.ILLEGAL, "\u0301"},
+		{"x₁", token.IDENT, "x"},
+		{"\uff9f", token.ILLEGAL, "\uff9f"},
+		{"·", token.ILLEGAL, "·"},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input, "ident.chp")
+		tok := l.NextToken()
+		if tok.Type != tt.expType || tok.Literal != tt.expLiteral {
+			t.Errorf("%q: expected=%q %q. got=%q %q", tt.input, tt.expType, tt.expLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestColumnsCountCharacters(t *testing.T) {
+	l := New("let café = \"日本\"; 🐒", "col.chp")
+
+	expected := []struct {
+		col, endCol, offset int
+	}{
+		{1, 4, 0},
+		{5, 9, 4},
+		{10, 11, 10},
+		{12, 16, 12},
+		{16, 17, 20},
+		{18, 19, 22},
+		{19, 19, 26},
+	}
+	for i, exp := range expected {
+		tok := l.NextToken()
+		if tok.Pos.Column != exp.col || tok.End.Column != exp.endCol || tok.Pos.Offset != exp.offset {
+			t.Errorf("tokens[%d] %q: expected columns %d-%d at offset %d. got=%d-%d at offset %d",
+				i, tok.Literal, exp.col, exp.endCol, exp.offset, tok.Pos.Column, tok.End.Column, tok.Pos.Offset)
+		}
+	}
+}
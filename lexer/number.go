@@ -0,0 +1,145 @@
+package lexer
+
+import (
+	"chimp/diag"
+	"chimp/token"
+	"strings"
+)
+
+type numBase struct {
+	tokType token.TokenType
+	name    string
+	isDigit func(rune) bool
+}
+
+var numBases = map[rune]numBase{
+	'x': {token.HEX_INT, "hexadecimal", isHexDigit},
+	'o': {token.OCT_INT, "octal", isOctDigit},
+	'b': {token.BIN_INT, "binary", isBinDigit},
+}
+
+// readNumber reads an integer or floating-point literal. The literal is
+// returned as written, underscores included. A malformed literal is still
+// read as a single token, and the problem is recorded in l.Errors.
+func (l *Lexer) readNumber() token.Token {
+	start := l.position()
+	var lit strings.Builder
+
+	// Problems are found part way through the literal; once it has been
+	// read, widen their spans to cover all of it.
+	nerrs := len(l.Errors)
+	defer func() {
+		for i := nerrs; i < len(l.Errors); i++ {
+			l.Errors[i].Span.End = l.position()
+		}
+	}()
+
+	if l.char == '0' {
+		if base, ok := numBases[l.nextChar()]; ok {
+			lit.WriteRune(l.char)
+			l.readChar()
+			lit.WriteRune(l.char)
+			l.readChar()
+
+			if !l.readDigits(&lit, base.isDigit) {
+				l.numError(start, "%s literal has no digits", base.name)
+			}
+			l.readJunk(&lit, start, base.name)
+			l.checkUnderscores(lit.String()[2:], start, base.isDigit)
+
+			return newToken(base.tokType, lit.String())
+		}
+	}
+
+	tokType := token.TokenType(token.INT)
+	l.readDigits(&lit, isDigit)
+
+	if l.char == '.' && isDigit(l.nextChar()) {
+		tokType = token.FLOAT
+		lit.WriteRune(l.char)
+		l.readChar()
+		l.readDigits(&lit, isDigit)
+	}
+
+	if l.char == 'e' || l.char == 'E' {
+		tokType = token.FLOAT
+		lit.WriteRune(l.char)
+		l.readChar()
+		if l.char == '+' || l.char == '-' {
+			lit.WriteRune(l.char)
+			l.readChar()
+		}
+		if !l.readDigits(&lit, isDigit) {
+			l.numError(start, "exponent has no digits")
+		}
+	}
+
+	if tokType == token.FLOAT {
+		l.readJunk(&lit, start, "floating-point")
+	} else {
+		l.readJunk(&lit, start, "decimal")
+	}
+	l.checkUnderscores(lit.String(), start, isDigit)
+
+	return newToken(tokType, lit.String())
+}
+
+// readDigits reads a run of digits and '_' separators and reports whether
+// it contained at least one digit.
+func (l *Lexer) readDigits(lit *strings.Builder, isValid func(rune) bool) bool {
+	found := false
+	for isValid(l.char) || l.char == '_' {
+		found = found || l.char != '_'
+		lit.WriteRune(l.char)
+		l.readChar()
+	}
+	return found
+}
+
+// readJunk consumes letters and digits stuck to the end of a literal, such
+// as the '2' in 0b102, so they are reported instead of becoming the next
+// token.
+func (l *Lexer) readJunk(lit *strings.Builder, start token.Position, name string) {
+	if !isIdentContinue(l.char) {
+		return
+	}
+
+	l.numError(start, "invalid character '%c' in %s literal", l.char, name)
+	for isIdentContinue(l.char) {
+		lit.WriteRune(l.char)
+		l.readChar()
+	}
+}
+
+// checkUnderscores reports separators that do not sit between two digits,
+// as in 1__0, 10_ or 1_.5. A separator may directly follow a base prefix,
+// as in 0x_FF, so body excludes the prefix.
+func (l *Lexer) checkUnderscores(body string, start token.Position, isValid func(rune) bool) {
+	if !strings.Contains(body, "_") {
+		return
+	}
+
+	body = strings.TrimPrefix(body, "_")
+
+	for i := 0; i < len(body); i++ {
+		if body[i] != '_' {
+			continue
+		}
+		if i == 0 || i == len(body)-1 || !isValid(rune(body[i-1])) || !isValid(rune(body[i+1])) {
+			l.numError(start, "'_' must separate successive digits")
+			return
+		}
+	}
+}
+
+func (l *Lexer) numError(start token.Position, format string, args ...interface{}) {
+	l.errorf(diag.MalformedNumber, start, "malformed number: "+format, args...)
+}
+
+func isOctDigit(char rune) bool {
+	return char >= '0' && char <= '7'
+}
+
+func isBinDigit(char rune) bool {
+	return char == '0' || char == '1'
+}
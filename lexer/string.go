@@ -0,0 +1,131 @@
+package lexer
+
+import (
+	"chimp/diag"
+	"chimp/token"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// readString reads the body of a double-quoted string, starting on the
+// character that opens it: either the opening '"' or the '}' that closes
+// an interpolation. The token is of type end when the body runs up to the
+// closing '"', and of type interp when it stops at a "${".
+func (l *Lexer) readString(end, interp token.TokenType) token.Token {
+	start := l.position()
+	l.readChar()
+
+	var out strings.Builder
+	for {
+		switch {
+		case l.char == '"':
+			l.readChar()
+			return newToken(end, out.String())
+		case l.char == '$' && l.nextChar() == '{':
+			l.readChar()
+			l.readChar()
+			l.interps = append(l.interps, 0)
+			return newToken(interp, out.String())
+		case l.char == '\n' || l.atEOF():
+			l.errorf(diag.UnterminatedString, start, "unterminated string literal").Fix = &diag.Fix{
+				Span:        diag.At(l.position()),
+				Replacement: `"`,
+				Message:     "close the string",
+			}
+			return newToken(end, out.String())
+		case l.char == '\\':
+			l.readEscape(&out)
+		default:
+			out.WriteRune(l.char)
+			l.readChar()
+		}
+	}
+}
+
+// readRawString reads a backtick-delimited string. Raw strings may span
+// lines and have no escapes or interpolation.
+func (l *Lexer) readRawString() token.Token {
+	start := l.position()
+	l.readChar()
+
+	var out strings.Builder
+	for l.char != '`' {
+		if l.atEOF() {
+			l.errorf(diag.UnterminatedString, start, "unterminated raw string literal").Fix = &diag.Fix{
+				Span:        diag.At(l.position()),
+				Replacement: "`",
+				Message:     "close the string",
+			}
+			return newToken(token.RAW_STRING, out.String())
+		}
+		if l.char != '\r' {
+			out.WriteRune(l.char)
+		}
+		l.readChar()
+	}
+	l.readChar()
+
+	return newToken(token.RAW_STRING, out.String())
+}
+
+var escapes = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'0':  0,
+	'"':  '"',
+	'\'': '\'',
+	'\\': '\\',
+	'$':  '$',
+}
+
+// readEscape decodes the escape sequence starting at the current '\'.
+func (l *Lexer) readEscape(out *strings.Builder) {
+	start := l.position()
+	l.readChar()
+
+	if char, ok := escapes[l.char]; ok {
+		out.WriteRune(char)
+		l.readChar()
+		return
+	}
+
+	if l.char != 'u' {
+		char := l.char
+		if l.char != '\n' && !l.atEOF() {
+			l.readChar()
+		}
+		l.errorf(diag.UnknownEscape, start, "unknown escape sequence '\\%c'", char)
+		return
+	}
+
+	l.readChar()
+	if l.char != '{' {
+		l.errorf(diag.InvalidUnicodeEscape, start, "expected '{' after '\\u'")
+		return
+	}
+	l.readChar()
+
+	digits := ""
+	for isHexDigit(l.char) {
+		digits += string(l.char)
+		l.readChar()
+	}
+	if l.char != '}' {
+		l.errorf(diag.InvalidUnicodeEscape, start, "unterminated unicode escape")
+		return
+	}
+	l.readChar()
+
+	code, err := strconv.ParseUint(digits, 16, 32)
+	if err != nil || len(digits) > 6 || !utf8.ValidRune(rune(code)) {
+		l.errorf(diag.InvalidUnicodeEscape, start, "invalid unicode escape '\\u{%s}'", digits)
+		return
+	}
+	out.WriteRune(rune(code))
+}
+
+func isHexDigit(char rune) bool {
+	return isDigit(char) || char >= 'a' && char <= 'f' || char >= 'A' && char <= 'F'
+}
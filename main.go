@@ -1,68 +1,346 @@
 package main
 
 import (
+	"bufio"
+	"chimp/compiler"
+	"chimp/diag"
 	"chimp/lexer"
 	"chimp/repl"
-	"chimp/token"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// Exit codes follow sysexits.h.
+const (
+	exitUsage    = 64
+	exitDataErr  = 65
+	exitSoftware = 70
+	exitIOErr    = 74
 )
 
 func main() {
-	user, err := user.Current()
+	argv := os.Args
+	if len(argv) < 2 {
+		fmt.Println("CLI: no arguments supplied")
+		os.Exit(exitUsage)
+	}
+
+	switch argv[1] {
+	case "run":
+		os.Exit(runCmd(argv[2:]))
+	case "build":
+		os.Exit(buildCmd(argv[2:]))
+	case "disasm":
+		os.Exit(disasmCmd(argv[2:]))
+	case "tokens":
+		os.Exit(tokensCmd(argv[2:]))
+	case "ast":
+		os.Exit(astCmd(argv[2:]))
+	case "fmt":
+		os.Exit(fmtCmd(argv[2:]))
+	case "play":
+		os.Exit(playCmd(argv[2:]))
+	default:
+		fmt.Printf("CLI: unrecognized argument '%s'\n", argv[1])
+		os.Exit(exitUsage)
+	}
+}
+
+// newFlagSet returns a flag set for a subcommand with the flags every
+// subcommand shares.
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	color := fs.String("color", "auto", "colorize diagnostics: auto, always or never")
+	return fs, color
+}
+
+// colorEnabled resolves a --color flag value against stderr.
+func colorEnabled(mode string) (bool, bool) {
+	m, err := diag.ParseColorMode(mode)
 	if err != nil {
-		panic(err)
+		fmt.Printf("CLI: %s\n", err)
+		return false, false
 	}
-	argv := os.Args
-	argc := len(argv)
-	switch argc {
+	return m.Enabled(os.Stderr), true
+}
+
+func runCmd(args []string) int {
+	fs, colorMode := newFlagSet("run")
+	engine := fs.String("engine", "eval", "execution engine: eval or vm")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	color, ok := colorEnabled(*colorMode)
+	if !ok {
+		return exitUsage
+	}
+	if *engine != "eval" && *engine != "vm" {
+		fmt.Printf("CLI: invalid engine '%s': expected eval or vm\n", *engine)
+		return exitUsage
+	}
+
+	switch fs.NArg() {
+	case 0:
+		fmt.Println("CLI: no filename provided")
+		return exitUsage
 	case 1:
-		fmt.Println("CLI: no arguments supplied")
-		os.Exit(64)
-	case 2:
-		switch argv[1] {
-		case "run":
-			fmt.Println("CLI: no filename provided")
-			os.Exit(64)
-		case "play":
-			fmt.Printf("Hello %s! This is the Chimp programming language!\nFeel free to type in commands\n", user.Name)
-			repl.Start()
-		default:
-			fmt.Printf("CLI: unrecognized argument '%s'", argv[1])
-			os.Exit(64)
+	default:
+		fmt.Println("CLI: too many arguments")
+		return exitUsage
+	}
+
+	// Diagnostics show the source they are in if it can be read again,
+	// which standard input cannot.
+	filename := fs.Arg(0)
+	in := os.Stdin
+	source := func() string { return "" }
+	if filename == "-" {
+		filename = "stdin"
+	} else {
+		source = func() string {
+			src, _ := os.ReadFile(filename)
+			return string(src)
 		}
-	case 3:
-		switch argv[1] {
-		case "run":
-			var Filename string = argv[2]
-			contents, err := os.ReadFile(Filename)
-			if err != nil {
-				os.Exit(74)
-			}
-			input := string(contents)
-			l := lexer.New(input, Filename)
-			var tok token.Token
-			for {
-				tok = l.NextToken()
-				fmt.Printf("Token%+v\n", tok)
-				if tok.Type == token.EOF {
-					break
-				}
-			}
-			for _, err := range l.Errors {
-				fmt.Println(err)
-			}
-		case "play":
-			fmt.Println("CLI: play takes no additional arguments. Moving along.")
-			fmt.Printf("Hello %s! This is the Chimp programming language!\nFeel free to type in commands\n", user.Name)
-			repl.Start()
-		default:
-			fmt.Println("CLI: why are we here? just to suffer?")
-			os.Exit(64)
+		f, err := os.Open(filename)
+		if err != nil {
+			fmt.Printf("CLI: %s\n", err)
+			return exitIOErr
+		}
+		defer f.Close()
+		in = f
+	}
+
+	// Source files are lexed as they are read; only object files are read
+	// in whole.
+	r := bufio.NewReader(in)
+	if magic, _ := r.Peek(compiler.MagicLen); compiler.IsObjectFile(magic) {
+		if *engine != "vm" && flagPassed(fs, "engine") {
+			fmt.Println("CLI: compiled files only run on the vm engine")
+			return exitUsage
 		}
+		contents, err := io.ReadAll(r)
+		if err != nil {
+			fmt.Printf("CLI: %s\n", err)
+			return exitIOErr
+		}
+		return runObjectFile(contents, filename)
+	}
+
+	return run(lexer.NewReader(r, filename), source, color, *engine)
+}
+
+func buildCmd(args []string) int {
+	fs, colorMode := newFlagSet("build")
+	out := fs.String("o", "", "output file (default: the source file with a .chpc extension)")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	color, ok := colorEnabled(*colorMode)
+	if !ok {
+		return exitUsage
+	}
+
+	switch len(files) {
+	case 0:
+		fmt.Println("CLI: no filename provided")
+		return exitUsage
+	case 1:
+	default:
+		fmt.Println("CLI: too many arguments")
+		return exitUsage
+	}
+
+	filename := files[0]
+	if *out == "" {
+		*out = strings.TrimSuffix(filename, filepath.Ext(filename)) + ".chpc"
+	}
+
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Printf("CLI: %s\n", err)
+		return exitIOErr
+	}
+
+	return build(contents, filename, *out, color)
+}
+
+func disasmCmd(args []string) int {
+	fs, colorMode := newFlagSet("disasm")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	color, ok := colorEnabled(*colorMode)
+	if !ok {
+		return exitUsage
+	}
+
+	switch fs.NArg() {
+	case 0:
+		fmt.Println("CLI: no filename provided")
+		return exitUsage
+	case 1:
 	default:
 		fmt.Println("CLI: too many arguments")
-		os.Exit(64)
+		return exitUsage
+	}
+
+	filename := fs.Arg(0)
+	contents, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Printf("CLI: %s\n", err)
+		return exitIOErr
 	}
+
+	return disasm(contents, filename, color)
+}
+
+func tokensCmd(args []string) int {
+	fs, colorMode := newFlagSet("tokens")
+	format := fs.String("format", "text", "output format: text or json")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	color, ok := colorEnabled(*colorMode)
+	if !ok {
+		return exitUsage
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Printf("CLI: invalid format '%s': expected text or json\n", *format)
+		return exitUsage
+	}
+
+	contents, status := readSource(files)
+	if contents == nil {
+		return status
+	}
+
+	return tokens(string(contents), files[0], color, *format)
+}
+
+func astCmd(args []string) int {
+	fs, colorMode := newFlagSet("ast")
+	format := fs.String("format", "tree", "output format: tree, json or sexpr")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	color, ok := colorEnabled(*colorMode)
+	if !ok {
+		return exitUsage
+	}
+	if *format != "tree" && *format != "json" && *format != "sexpr" {
+		fmt.Printf("CLI: invalid format '%s': expected tree, json or sexpr\n", *format)
+		return exitUsage
+	}
+
+	contents, status := readSource(files)
+	if contents == nil {
+		return status
+	}
+
+	return dumpAST(string(contents), files[0], color, *format)
+}
+
+func fmtCmd(args []string) int {
+	fs, colorMode := newFlagSet("fmt")
+	write := fs.Bool("w", false, "write the result to each file instead of printing it")
+	diff := fs.Bool("d", false, "print a diff of the changes instead of the result")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return exitUsage
+	}
+	color, ok := colorEnabled(*colorMode)
+	if !ok {
+		return exitUsage
+	}
+	if len(files) == 0 {
+		fmt.Println("CLI: no filename provided")
+		return exitUsage
+	}
+
+	status := 0
+	for _, filename := range files {
+		if s := formatFile(filename, *write, *diff, color); s != 0 {
+			status = s
+		}
+	}
+	return status
+}
+
+// readSource reads the single file named by a command's arguments. On
+// failure it returns nil and the exit status.
+func readSource(files []string) ([]byte, int) {
+	switch len(files) {
+	case 0:
+		fmt.Println("CLI: no filename provided")
+		return nil, exitUsage
+	case 1:
+	default:
+		fmt.Println("CLI: too many arguments")
+		return nil, exitUsage
+	}
+
+	contents, err := os.ReadFile(files[0])
+	if err != nil {
+		fmt.Printf("CLI: %s\n", err)
+		return nil, exitIOErr
+	}
+	return contents, 0
+}
+
+// parseInterspersed parses flags that may come before or after the
+// positional arguments, and returns the positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// flagPassed reports whether the flag name was set on the command line.
+func flagPassed(fs *flag.FlagSet, name string) bool {
+	passed := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return passed
+}
+
+func playCmd(args []string) int {
+	fs, colorMode := newFlagSet("play")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	color, ok := colorEnabled(*colorMode)
+	if !ok {
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		fmt.Println("CLI: play takes no additional arguments. Moving along.")
+	}
+
+	user, err := user.Current()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Hello %s! This is the Chimp programming language!\nFeel free to type in commands\n", user.Name)
+	repl.Start(color)
+
+	return 0
 }
@@ -0,0 +1,229 @@
+package object
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Stdout is where puts and print write.
+var Stdout io.Writer = os.Stdout
+
+// BuiltinFunction implements a builtin. It returns nil for null, so that
+// each engine can substitute its own null value.
+type BuiltinFunction func(args ...Object) Object
+
+type Builtin struct {
+	Name string
+	Fn   BuiltinFunction
+}
+
+func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
+func (b *Builtin) Inspect() string  { return "builtin " + b.Name }
+
+// Builtins lists the builtin functions. Their index in the list is what
+// compiled code refers to them by, so new ones go at the end. The type
+// checker declares their signatures in types.Builtins.
+var Builtins = []*Builtin{
+	{"len", builtinLen},
+	{"puts", builtinPuts},
+	{"print", builtinPrint},
+	{"first", builtinFirst},
+	{"last", builtinLast},
+	{"rest", builtinRest},
+	{"push", builtinPush},
+	{"type_of", builtinTypeOf},
+	{"to_string", builtinToString},
+	{"parse_int", builtinParseInt},
+	{"assert", builtinAssert},
+}
+
+// GetBuiltinByName returns the builtin called name, or nil if there is
+// none.
+func GetBuiltinByName(name string) *Builtin {
+	for _, b := range Builtins {
+		if b.Name == name {
+			return b
+		}
+	}
+	return nil
+}
+
+func newError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...)}
+}
+
+func wrongArgCount(name string, want string, args []Object) *Error {
+	return newError("wrong number of arguments to %s: want=%s, got=%d", name, want, len(args))
+}
+
+// arrayArg returns the single array argument of the builtin name.
+func arrayArg(name string, args []Object) (*Array, *Error) {
+	if len(args) != 1 {
+		return nil, wrongArgCount(name, "1", args)
+	}
+	arr, ok := args[0].(*Array)
+	if !ok {
+		return nil, newError("argument to %s must be ARRAY, got %s", name, args[0].Type())
+	}
+	return arr, nil
+}
+
+func builtinLen(args ...Object) Object {
+	if len(args) != 1 {
+		return wrongArgCount("len", "1", args)
+	}
+
+	switch arg := args[0].(type) {
+	case *String:
+		return &Integer{Value: int64(utf8.RuneCountInString(arg.Value))}
+	case *Array:
+		return &Integer{Value: int64(len(arg.Elements))}
+	case *Hash:
+		return &Integer{Value: int64(len(arg.Pairs))}
+	}
+	return newError("argument to len not supported, got %s", args[0].Type())
+}
+
+// builtinPuts writes each argument on a line of its own.
+func builtinPuts(args ...Object) Object {
+	for _, arg := range args {
+		fmt.Fprintln(Stdout, arg.Inspect())
+	}
+	return nil
+}
+
+// builtinPrint writes its arguments one after another, with nothing
+// between them and no newline at the end.
+func builtinPrint(args ...Object) Object {
+	for _, arg := range args {
+		fmt.Fprint(Stdout, arg.Inspect())
+	}
+	return nil
+}
+
+func builtinFirst(args ...Object) Object {
+	arr, err := arrayArg("first", args)
+	if err != nil {
+		return err
+	}
+	if len(arr.Elements) == 0 {
+		return nil
+	}
+	return arr.Elements[0]
+}
+
+func builtinLast(args ...Object) Object {
+	arr, err := arrayArg("last", args)
+	if err != nil {
+		return err
+	}
+	if len(arr.Elements) == 0 {
+		return nil
+	}
+	return arr.Elements[len(arr.Elements)-1]
+}
+
+// builtinRest returns a new array holding all but the first element. The
+// rest of an empty array is empty.
+func builtinRest(args ...Object) Object {
+	arr, err := arrayArg("rest", args)
+	if err != nil {
+		return err
+	}
+	if len(arr.Elements) == 0 {
+		return &Array{Elements: []Object{}}
+	}
+	elements := make([]Object, len(arr.Elements)-1)
+	copy(elements, arr.Elements[1:])
+	return &Array{Elements: elements}
+}
+
+// builtinPush returns a new array with the element added at the end,
+// leaving the original as it was.
+func builtinPush(args ...Object) Object {
+	if len(args) != 2 {
+		return wrongArgCount("push", "2", args)
+	}
+	arr, ok := args[0].(*Array)
+	if !ok {
+		return newError("argument to push must be ARRAY, got %s", args[0].Type())
+	}
+
+	elements := make([]Object, len(arr.Elements), len(arr.Elements)+1)
+	copy(elements, arr.Elements)
+	return &Array{Elements: append(elements, args[1])}
+}
+
+// typeNames maps object types to the names type_of reports, which are
+// the ones programs spell them with.
+var typeNames = map[ObjectType]string{
+	INTEGER_OBJ:  "int",
+	FLOAT_OBJ:    "float",
+	BOOLEAN_OBJ:  "bool",
+	STRING_OBJ:   "string",
+	NULL_OBJ:     "null",
+	ARRAY_OBJ:    "array",
+	HASH_OBJ:     "map",
+	FUNCTION_OBJ: "function",
+	BUILTIN_OBJ:  "function",
+}
+
+func builtinTypeOf(args ...Object) Object {
+	if len(args) != 1 {
+		return wrongArgCount("type_of", "1", args)
+	}
+	name, ok := typeNames[args[0].Type()]
+	if !ok {
+		name = strings.ToLower(string(args[0].Type()))
+	}
+	return &String{Value: name}
+}
+
+func builtinToString(args ...Object) Object {
+	if len(args) != 1 {
+		return wrongArgCount("to_string", "1", args)
+	}
+	return &String{Value: args[0].Inspect()}
+}
+
+// builtinParseInt parses a decimal integer, returning null if the string
+// does not hold one.
+func builtinParseInt(args ...Object) Object {
+	if len(args) != 1 {
+		return wrongArgCount("parse_int", "1", args)
+	}
+	s, ok := args[0].(*String)
+	if !ok {
+		return newError("argument to parse_int must be STRING, got %s", args[0].Type())
+	}
+
+	n, err := strconv.ParseInt(s.Value, 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &Integer{Value: n}
+}
+
+// builtinAssert fails with a runtime error, carrying the optional
+// message, if its condition is false.
+func builtinAssert(args ...Object) Object {
+	if len(args) != 1 && len(args) != 2 {
+		return wrongArgCount("assert", "1 or 2", args)
+	}
+	cond, ok := args[0].(*Boolean)
+	if !ok {
+		return newError("argument to assert must be BOOLEAN, got %s", args[0].Type())
+	}
+	if cond.Value {
+		return nil
+	}
+
+	if len(args) == 2 {
+		return newError("assertion failed: %s", args[1].Inspect())
+	}
+	return newError("assertion failed")
+}
@@ -0,0 +1,62 @@
+package object
+
+// Environment maps names to values for one scope. Each function call gets
+// an environment enclosed by the one the function was defined in, which is
+// what lets closures see the variables around them.
+type Environment struct {
+	store map[string]Object
+	outer *Environment
+	depth int // the number of function calls in progress
+}
+
+func NewEnvironment() *Environment {
+	return &Environment{store: make(map[string]Object)}
+}
+
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := NewEnvironment()
+	env.outer = outer
+	env.depth = outer.depth
+	return env
+}
+
+// NewCallEnvironment returns the environment for a call, made depth calls
+// deep, of a function defined in outer.
+func NewCallEnvironment(outer *Environment, depth int) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	env.depth = depth
+	return env
+}
+
+// Depth is the number of function calls in progress in e.
+func (e *Environment) Depth() int {
+	return e.depth
+}
+
+// Get looks name up in this scope and then in each enclosing one.
+func (e *Environment) Get(name string) (Object, bool) {
+	obj, ok := e.store[name]
+	if !ok && e.outer != nil {
+		obj, ok = e.outer.Get(name)
+	}
+	return obj, ok
+}
+
+// Set binds name in this scope, shadowing any outer binding.
+func (e *Environment) Set(name string, val Object) Object {
+	e.store[name] = val
+	return val
+}
+
+// Assign rebinds name in the nearest scope that defines it, and reports
+// whether there was one.
+func (e *Environment) Assign(name string, val Object) bool {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = val
+		return true
+	}
+	if e.outer != nil {
+		return e.outer.Assign(name, val)
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package object
+
+import "testing"
+
+func TestEnclosedEnvironment(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("x", &Integer{Value: 1})
+	outer.Set("y", &Integer{Value: 2})
+
+	inner := NewEnclosedEnvironment(outer)
+	inner.Set("x", &Integer{Value: 10})
+
+	tests := []struct {
+		env      *Environment
+		name     string
+		expected int64
+	}{
+		{inner, "x", 10},
+		{inner, "y", 2},
+		{outer, "x", 1},
+	}
+
+	for _, tt := range tests {
+		obj, ok := tt.env.Get(tt.name)
+		if !ok {
+			t.Fatalf("Get(%q): not found", tt.name)
+		}
+		if obj.(*Integer).Value != tt.expected {
+			t.Errorf("Get(%q): expected=%d. got=%s", tt.name, tt.expected, obj.Inspect())
+		}
+	}
+
+	if _, ok := outer.Get("z"); ok {
+		t.Errorf("Get(\"z\"): expected not found")
+	}
+}
@@ -0,0 +1,103 @@
+package object
+
+import "fmt"
+
+// Index returns left[index]. It returns nil for a key missing from a hash,
+// so that each engine can substitute its own null value.
+func Index(left, index Object) (Object, error) {
+	switch left := left.(type) {
+	case *Array:
+		i, err := checkIndex(index, len(left.Elements))
+		if err != nil {
+			return nil, err
+		}
+		return left.Elements[i], nil
+	case *String:
+		runes := []rune(left.Value)
+		i, err := checkIndex(index, len(runes))
+		if err != nil {
+			return nil, err
+		}
+		return &String{Value: string(runes[i])}, nil
+	case *Hash:
+		key, err := HashableKey(index)
+		if err != nil {
+			return nil, err
+		}
+		if val, ok := left.Get(key); ok {
+			return val, nil
+		}
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("index operator not supported: %s", left.Type())
+}
+
+// HashableKey returns key as a hash key, if it can be one.
+func HashableKey(key Object) (Hashable, error) {
+	hashKey, ok := key.(Hashable)
+	if !ok {
+		return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+	}
+	return hashKey, nil
+}
+
+// checkIndex returns index as an int if it is an integer in [0, length).
+func checkIndex(index Object, length int) (int, error) {
+	i, ok := index.(*Integer)
+	if !ok {
+		return 0, fmt.Errorf("index must be an integer, got %s", index.Type())
+	}
+	if i.Value < 0 || i.Value >= int64(length) {
+		return 0, fmt.Errorf("index out of range: %d (length %d)", i.Value, length)
+	}
+	return int(i.Value), nil
+}
+
+// Slice returns left[low:high]. A missing bound is nil or null; the low
+// bound then defaults to 0 and the high bound to the length of left.
+func Slice(left, low, high Object) (Object, error) {
+	switch left := left.(type) {
+	case *Array:
+		lo, hi, err := checkSlice(low, high, len(left.Elements))
+		if err != nil {
+			return nil, err
+		}
+		elements := make([]Object, hi-lo)
+		copy(elements, left.Elements[lo:hi])
+		return &Array{Elements: elements}, nil
+	case *String:
+		runes := []rune(left.Value)
+		lo, hi, err := checkSlice(low, high, len(runes))
+		if err != nil {
+			return nil, err
+		}
+		return &String{Value: string(runes[lo:hi])}, nil
+	}
+
+	return nil, fmt.Errorf("slice operator not supported: %s", left.Type())
+}
+
+// checkSlice returns the bounds of a slice of something length long. They
+// must satisfy 0 <= lo <= hi <= length.
+func checkSlice(low, high Object, length int) (int, int, error) {
+	lo, hi := int64(0), int64(length)
+	for _, b := range []struct {
+		obj Object
+		val *int64
+	}{{low, &lo}, {high, &hi}} {
+		if b.obj == nil || b.obj.Type() == NULL_OBJ {
+			continue
+		}
+		i, ok := b.obj.(*Integer)
+		if !ok {
+			return 0, 0, fmt.Errorf("slice bounds must be integers, got %s", b.obj.Type())
+		}
+		*b.val = i.Value
+	}
+
+	if lo < 0 || lo > hi || hi > int64(length) {
+		return 0, 0, fmt.Errorf("slice bounds out of range: [%d:%d] (length %d)", lo, hi, length)
+	}
+	return int(lo), int(hi), nil
+}
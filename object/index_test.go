@@ -0,0 +1,42 @@
+package object
+
+import "testing"
+
+func TestIndexAndSlice(t *testing.T) {
+	arr := &Array{Elements: []Object{&Integer{Value: 1}, &Integer{Value: 2}, &Integer{Value: 3}}}
+	str := &String{Value: "héllo"}
+	hash := NewHash()
+	hash.Set(&String{Value: "a"}, &Integer{Value: 1})
+
+	tests := []struct {
+		name     string
+		get      func() (Object, error)
+		expected string
+	}{
+		{"arr[1]", func() (Object, error) { return Index(arr, &Integer{Value: 1}) }, "2"},
+		{"arr[3]", func() (Object, error) { return Index(arr, &Integer{Value: 3}) }, "index out of range: 3 (length 3)"},
+		{"str[1]", func() (Object, error) { return Index(str, &Integer{Value: 1}) }, "é"},
+		{`hash["a"]`, func() (Object, error) { return Index(hash, &String{Value: "a"}) }, "1"},
+		{`hash["b"]`, func() (Object, error) { return Index(hash, &String{Value: "b"}) }, "<nil>"},
+		{"hash[arr]", func() (Object, error) { return Index(hash, arr) }, "unusable as hash key: ARRAY"},
+		{"arr[1:]", func() (Object, error) { return Slice(arr, &Integer{Value: 1}, nil) }, "[2, 3]"},
+		{"str[:2]", func() (Object, error) { return Slice(str, &Null{}, &Integer{Value: 2}) }, "hé"},
+		{"arr[2:1]", func() (Object, error) { return Slice(arr, &Integer{Value: 2}, &Integer{Value: 1}) },
+			"slice bounds out of range: [2:1] (length 3)"},
+		{"hash[:]", func() (Object, error) { return Slice(hash, nil, nil) }, "slice operator not supported: HASH"},
+	}
+
+	for _, tt := range tests {
+		result, err := tt.get()
+		got := "<nil>"
+		switch {
+		case err != nil:
+			got = err.Error()
+		case result != nil:
+			got = result.Inspect()
+		}
+		if got != tt.expected {
+			t.Errorf("%s: expected=%q. got=%q", tt.name, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package object
+
+import (
+	"bytes"
+	"chimp/ast"
+	"chimp/code"
+	"chimp/token"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type ObjectType string
+
+const (
+	INTEGER_OBJ      = "INTEGER"
+	FLOAT_OBJ        = "FLOAT"
+	BOOLEAN_OBJ      = "BOOLEAN"
+	STRING_OBJ       = "STRING"
+	NULL_OBJ         = "NULL"
+	FUNCTION_OBJ     = "FUNCTION"
+	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	ERROR_OBJ        = "ERROR"
+	ARRAY_OBJ        = "ARRAY"
+	HASH_OBJ         = "HASH"
+	BUILTIN_OBJ      = "BUILTIN"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+	UPVALUE_OBJ           = "UPVALUE"
+)
+
+type Object interface {
+	Type() ObjectType
+	Inspect() string
+}
+
+type Integer struct {
+	Value int64
+}
+
+func (i *Integer) Type() ObjectType { return INTEGER_OBJ }
+func (i *Integer) Inspect() string  { return strconv.FormatInt(i.Value, 10) }
+
+type Float struct {
+	Value float64
+}
+
+func (f *Float) Type() ObjectType { return FLOAT_OBJ }
+func (f *Float) Inspect() string  { return strconv.FormatFloat(f.Value, 'g', -1, 64) }
+
+type Boolean struct {
+	Value bool
+}
+
+func (b *Boolean) Type() ObjectType { return BOOLEAN_OBJ }
+func (b *Boolean) Inspect() string  { return strconv.FormatBool(b.Value) }
+
+type String struct {
+	Value string
+}
+
+func (s *String) Type() ObjectType { return STRING_OBJ }
+func (s *String) Inspect() string  { return s.Value }
+
+// HashKey identifies the value of a hash key: keys of the same type and
+// value have the same HashKey, and keys that differ have different ones.
+// Strings are kept whole in Text rather than hashed, so that two strings
+// never share a key.
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+	Text  string
+}
+
+// Hashable is implemented by the objects that can be hash keys.
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (s *String) HashKey() HashKey {
+	return HashKey{Type: s.Type(), Text: s.Value}
+}
+
+type Null struct{}
+
+func (n *Null) Type() ObjectType { return NULL_OBJ }
+func (n *Null) Inspect() string  { return "null" }
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Type() ObjectType { return ARRAY_OBJ }
+func (a *Array) Inspect() string {
+	var out bytes.Buffer
+
+	elements := []string{}
+	for _, e := range a.Elements {
+		elements = append(elements, e.Inspect())
+	}
+
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+
+	return out.String()
+}
+
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash maps keys to values, remembering the order keys were first set in
+// so that it always prints the same way.
+type Hash struct {
+	Pairs map[HashKey]HashPair
+	Keys  []HashKey
+}
+
+func NewHash() *Hash {
+	return &Hash{Pairs: map[HashKey]HashPair{}}
+}
+
+func (h *Hash) Type() ObjectType { return HASH_OBJ }
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+
+	pairs := []string{}
+	for _, k := range h.Keys {
+		pair := h.Pairs[k]
+		pairs = append(pairs, pair.Key.Inspect()+": "+pair.Value.Inspect())
+	}
+
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+
+	return out.String()
+}
+
+// Set maps key to value, replacing any value it had.
+func (h *Hash) Set(key Hashable, value Object) {
+	hk := key.HashKey()
+	if _, ok := h.Pairs[hk]; !ok {
+		h.Keys = append(h.Keys, hk)
+	}
+	h.Pairs[hk] = HashPair{Key: key, Value: value}
+}
+
+// Get returns the value key maps to, and whether there is one.
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	pair, ok := h.Pairs[key.HashKey()]
+	return pair.Value, ok
+}
+
+// ReturnValue wraps the value of a return statement while it unwinds to
+// the enclosing function call.
+type ReturnValue struct {
+	Value Object
+}
+
+func (rv *ReturnValue) Type() ObjectType { return RETURN_VALUE_OBJ }
+func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
+
+// Error is a runtime error. It unwinds the evaluation like a return value,
+// all the way to the top of the program, collecting the calls it leaves
+// in Trace.
+type Error struct {
+	Message string
+	Pos     token.Position
+	Trace   []StackFrame
+}
+
+func (e *Error) Type() ObjectType { return ERROR_OBJ }
+func (e *Error) Inspect() string {
+	if e.Pos.IsValid() {
+		return fmt.Sprintf("%s: runtime error: %s", e.Pos, e.Message)
+	}
+	return "runtime error: " + e.Message
+}
+
+type Function struct {
+	Parameters []*ast.Parameter
+	Body       *ast.BlockStatement
+	Env        *Environment
+	Name       string // the name the function was declared with, if any
+}
+
+func (f *Function) Type() ObjectType { return FUNCTION_OBJ }
+func (f *Function) Inspect() string  { return inspectFunction(f.Name) }
+
+// inspectFunction renders a function by its name, the same way on both
+// engines.
+func inspectFunction(name string) string {
+	if name == "" {
+		name = "<anonymous>"
+	}
+	return "fn " + name
+}
+
+// CompiledFunction is the bytecode of a function literal, as it sits in the
+// constant pool.
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	Positions     code.PosTable
+	NumLocals     int
+	NumParameters int
+	Name          string
+}
+
+func (cf *CompiledFunction) Type() ObjectType { return COMPILED_FUNCTION_OBJ }
+func (cf *CompiledFunction) Inspect() string  { return inspectFunction(cf.Name) }
+
+// Closure is a compiled function together with the variables it captured.
+// It reports the same type as Function, so that programs behave alike on
+// both engines.
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []*Upvalue
+}
+
+func (c *Closure) Type() ObjectType { return FUNCTION_OBJ }
+func (c *Closure) Inspect() string  { return inspectFunction(c.Fn.Name) }
+
+// Upvalue is a variable captured by a closure. While the function that
+// declared it is running, it refers to the variable's slot on the stack;
+// once that function returns, Close moves the value into the upvalue.
+type Upvalue struct {
+	Location *Object
+	closed   Object
+}
+
+func (u *Upvalue) Type() ObjectType { return UPVALUE_OBJ }
+func (u *Upvalue) Inspect() string  { return fmt.Sprintf("Upvalue[%s]", (*u.Location).Inspect()) }
+
+func (u *Upvalue) Get() Object    { return *u.Location }
+func (u *Upvalue) Set(val Object) { *u.Location = val }
+func (u *Upvalue) Close() {
+	u.closed = *u.Location
+	u.Location = &u.closed
+}
@@ -0,0 +1,70 @@
+package object
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestStringHashKey(t *testing.T) {
+	hello1 := &String{Value: "Hello World"}
+	hello2 := &String{Value: "Hello World"}
+	diff1 := &String{Value: "My name is johnny"}
+	diff2 := &String{Value: "My name is johnny"}
+
+	if hello1.HashKey() != hello2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+	if diff1.HashKey() != diff2.HashKey() {
+		t.Errorf("strings with same content have different hash keys")
+	}
+	if hello1.HashKey() == diff1.HashKey() {
+		t.Errorf("strings with different content have same hash keys")
+	}
+}
+
+func TestHashKeysDifferByType(t *testing.T) {
+	one := &Integer{Value: 1}
+	yes := &Boolean{Value: true}
+
+	if one.HashKey() == yes.HashKey() {
+		t.Errorf("1 and true have the same hash key")
+	}
+}
+
+func TestHashKeepsInsertionOrder(t *testing.T) {
+	h := NewHash()
+	h.Set(&String{Value: "b"}, &Integer{Value: 1})
+	h.Set(&Integer{Value: 2}, &Boolean{Value: true})
+	h.Set(&String{Value: "b"}, &Integer{Value: 3})
+
+	if got := h.Inspect(); got != "{b: 3, 2: true}" {
+		t.Errorf("Inspect: expected=%q. got=%q", "{b: 3, 2: true}", got)
+	}
+	if v, ok := h.Get(&Integer{Value: 2}); !ok || v.Inspect() != "true" {
+		t.Errorf("Get(2): expected=true. got=%v", v)
+	}
+	if _, ok := h.Get(&Boolean{Value: false}); ok {
+		t.Errorf("Get(false): expected no value")
+	}
+}
+
+func TestHashStringKeysAreExact(t *testing.T) {
+	h := NewHash()
+	for i := 0; i < 10000; i++ {
+		h.Set(&String{Value: fmt.Sprintf("key%d", i)}, &Integer{Value: int64(i)})
+	}
+
+	if len(h.Keys) != 10000 {
+		t.Fatalf("expected=10000 keys. got=%d", len(h.Keys))
+	}
+	for i := 0; i < 10000; i++ {
+		key := &String{Value: fmt.Sprintf("key%d", i)}
+		v, ok := h.Get(key)
+		if !ok || v.(*Integer).Value != int64(i) {
+			t.Fatalf("Get(%q): expected=%d. got=%v", key.Value, i, v)
+		}
+	}
+	if _, ok := h.Get(&String{Value: "key10000"}); ok {
+		t.Errorf("Get(%q): expected no value", "key10000")
+	}
+}
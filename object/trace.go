@@ -0,0 +1,45 @@
+package object
+
+import (
+	"chimp/token"
+	"fmt"
+	"strings"
+)
+
+// StackFrame is a function call that was in progress when a runtime error
+// happened: the function, and the position it had reached.
+type StackFrame struct {
+	Function string
+	Pos      token.Position
+}
+
+func (f StackFrame) String() string {
+	name := f.Function
+	if name == "" {
+		name = "<anonymous>"
+	}
+	return fmt.Sprintf("at %s (%s)", name, f.Pos)
+}
+
+// traceEnds is how many frames FormatStackTrace shows from each end of a
+// trace too long to read.
+const traceEnds = 10
+
+// FormatStackTrace formats trace, innermost call first, one indented
+// frame per line. The middle of a long trace, as left by a runaway
+// recursion, is elided.
+func FormatStackTrace(trace []StackFrame) string {
+	var out strings.Builder
+
+	for i, f := range trace {
+		if len(trace) > 2*traceEnds+1 && i == traceEnds {
+			fmt.Fprintf(&out, "    ... %d more frames\n", len(trace)-2*traceEnds)
+		}
+		if len(trace) > 2*traceEnds+1 && i >= traceEnds && i < len(trace)-traceEnds {
+			continue
+		}
+		fmt.Fprintf(&out, "    %s\n", f)
+	}
+
+	return out.String()
+}
@@ -0,0 +1,468 @@
+package parser
+
+import (
+	"chimp/ast"
+	"chimp/diag"
+	"chimp/token"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// parseExpression is a Pratt (precedence-climbing) parser. It parses a
+// prefix expression and then keeps folding postfix and infix operators
+// into it for as long as they bind tighter than precedence.
+func (p *Parser) parseExpression(precedence int) ast.Expression {
+	prefix := p.prefixParseFns[p.curToken.Type]
+	if prefix == nil {
+		p.noPrefixParseFnError(p.curToken.Type)
+		return nil
+	}
+	leftExp := prefix()
+
+	for !p.peekTokenIs(token.SEMICOLON) && !p.peekTokenIs(token.EOF) {
+		if postfix := p.postfixParseFns[p.peekToken.Type]; postfix != nil && precedence < POSTFIX {
+			p.nextToken()
+			if leftExp = postfix(leftExp); leftExp == nil {
+				return nil
+			}
+			continue
+		}
+
+		if precedence >= p.peekPrecedence() {
+			break
+		}
+
+		infix := p.infixParseFns[p.peekToken.Type]
+		if infix == nil {
+			return leftExp
+		}
+
+		p.nextToken()
+		leftExp = infix(leftExp)
+	}
+
+	return leftExp
+}
+
+func (p *Parser) parseIdentifier() ast.Expression {
+	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+}
+
+var intBases = map[token.TokenType]int{
+	token.INT:     10,
+	token.HEX_INT: 16,
+	token.OCT_INT: 8,
+	token.BIN_INT: 2,
+}
+
+func (p *Parser) parseIntegerLiteral() ast.Expression {
+	lit := &ast.IntegerLiteral{Token: p.curToken}
+
+	digits := strings.ReplaceAll(p.curToken.Literal, "_", "")
+	base := intBases[p.curToken.Type]
+	if base != 10 {
+		digits = digits[2:]
+	}
+
+	value, err := strconv.ParseInt(digits, base, 64)
+	if err != nil {
+		p.numberError(err, "integer")
+		return nil
+	}
+
+	lit.Value = value
+
+	return lit
+}
+
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+
+	value, err := strconv.ParseFloat(strings.ReplaceAll(p.curToken.Literal, "_", ""), 64)
+	if err != nil {
+		p.numberError(err, "float")
+		return nil
+	}
+
+	lit.Value = value
+
+	return lit
+}
+
+// numberError reports a literal that does not fit its type. Malformed
+// literals are not reported again here, as the lexer already has.
+func (p *Parser) numberError(err error, kind string) {
+	if errors.Is(err, strconv.ErrRange) {
+		p.errorf(diag.InvalidNumber, diag.TokenSpan(p.curToken),
+			"%s literal %s is out of range", kind, p.curToken.Literal)
+	}
+}
+
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
+// parseInterpolatedString parses the token sequence the lexer emits for
+// "text${expr}text${expr}text" into a single concatenation node.
+func (p *Parser) parseInterpolatedString() ast.Expression {
+	str := &ast.InterpolatedString{Token: p.curToken}
+	str.Parts = append(str.Parts, &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal})
+
+	for {
+		p.nextToken()
+		str.Parts = append(str.Parts, p.parseExpression(LOWEST))
+
+		if p.peekTokenIs(token.STRING_MID) {
+			p.nextToken()
+			str.Parts = append(str.Parts, &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal})
+			continue
+		}
+
+		if !p.expPeek(token.STRING_TAIL) {
+			return nil
+		}
+		str.Parts = append(str.Parts, &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal})
+
+		return str
+	}
+}
+
+func (p *Parser) parseBoolean() ast.Expression {
+	return &ast.Boolean{Token: p.curToken, Value: p.curTokenIs(token.TRUE)}
+}
+
+func (p *Parser) parseNull() ast.Expression {
+	return &ast.Null{Token: p.curToken}
+}
+
+func (p *Parser) parseGroupedExpression() ast.Expression {
+	lparen := p.curToken
+	p.nextToken()
+
+	exp := p.parseExpression(LOWEST)
+
+	if !p.peekTokenIs(token.RPAREN) {
+		p.peekError(token.RPAREN).Notes = []diag.Note{
+			{Span: diag.TokenSpan(lparen), Message: "unclosed '(' opened here"},
+		}
+		return nil
+	}
+	p.nextToken()
+
+	return exp
+}
+
+func (p *Parser) parsePrefixExpression() ast.Expression {
+	expression := &ast.PrefixExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+	}
+
+	p.nextToken()
+
+	expression.Right = p.parseExpression(PREFIX)
+
+	return expression
+}
+
+func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
+	expression := &ast.InfixExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Left:     left,
+	}
+
+	precedence := p.curPrecedence()
+	if rightAssoc[p.curToken.Type] {
+		precedence--
+	}
+
+	p.nextToken()
+
+	expression.Right = p.parseExpression(precedence)
+
+	return expression
+}
+
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	exp := &ast.AssignExpression{Token: p.curToken, Target: target}
+
+	if _, ok := target.(*ast.Identifier); !ok {
+		p.errorf(diag.InvalidAssignment, diag.SpanOf(target), "cannot assign to %s", target)
+		return nil
+	}
+
+	p.nextToken()
+	exp.Value = p.parseExpression(ASSIGN - 1)
+
+	return exp
+}
+
+// parsePostfixExpression parses x++ or x--, which update the variable x.
+func (p *Parser) parsePostfixExpression(left ast.Expression) ast.Expression {
+	if _, ok := left.(*ast.Identifier); !ok {
+		p.errorf(diag.InvalidAssignment, diag.SpanOf(left), "cannot assign to %s", left)
+		return nil
+	}
+
+	return &ast.PostfixExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Left:     left,
+	}
+}
+
+func (p *Parser) parseIfExpression() ast.Expression {
+	expression := &ast.IfExpression{Token: p.curToken}
+
+	p.nextToken()
+	expression.Condition = p.parseExpression(LOWEST)
+	if expression.Condition == nil {
+		return nil
+	}
+
+	if !p.expPeek(token.LBRACE) {
+		return nil
+	}
+	expression.Consequence = p.parseBlockStatement()
+	if expression.Consequence == nil {
+		return nil
+	}
+
+	if !p.peekTokenIs(token.ELSE) {
+		return expression
+	}
+	p.nextToken()
+
+	// else if ... is sugar for else { if ... }
+	if p.peekTokenIs(token.IF) {
+		elseTok := p.curToken
+		p.nextToken()
+		elseIf := p.parseIfExpression()
+		if elseIf == nil {
+			return nil
+		}
+		expression.Alternative = &ast.BlockStatement{
+			Token: elseTok,
+			Statements: []ast.Statement{
+				&ast.ExpressionStatement{Token: elseIf.(*ast.IfExpression).Token, Expression: elseIf},
+			},
+		}
+		return expression
+	}
+
+	if !p.expPeek(token.LBRACE) {
+		return nil
+	}
+	expression.Alternative = p.parseBlockStatement()
+	if expression.Alternative == nil {
+		return nil
+	}
+
+	return expression
+}
+
+func (p *Parser) parseFunctionLiteral() ast.Expression {
+	lit := &ast.FunctionLiteral{Token: p.curToken}
+
+	if !p.expPeek(token.LPAREN) {
+		return nil
+	}
+
+	lit.Parameters = p.parseFunctionParameters()
+	if lit.Parameters == nil {
+		return nil
+	}
+
+	if !p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		if lit.ReturnType = p.parseType(false); lit.ReturnType == nil {
+			return nil
+		}
+	}
+
+	if !p.expPeek(token.LBRACE) {
+		return nil
+	}
+
+	lit.Body = p.parseBlockStatement()
+	if lit.Body == nil {
+		return nil
+	}
+
+	return lit
+}
+
+// parseFunctionParameters parses a parameter list of the form
+// (int x, ?bool y) or (x, y). It returns nil on error.
+func (p *Parser) parseFunctionParameters() []*ast.Parameter {
+	params := []*ast.Parameter{}
+
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return params
+	}
+
+	for {
+		p.nextToken()
+
+		param := &ast.Parameter{}
+		if !p.curTokenIs(token.IDENT) || !p.peekTokenIs(token.COMMA) && !p.peekTokenIs(token.RPAREN) {
+			if param.Type = p.parseType(true); param.Type == nil {
+				return nil
+			}
+			p.nextToken()
+		}
+		if !p.curTokenIs(token.IDENT) {
+			p.errorf(diag.UnexpectedToken, diag.TokenSpan(p.curToken),
+				"expected parameter name, got '%s' instead", p.curToken.Type)
+			return nil
+		}
+		param.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		params = append(params, param)
+
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	if !p.expPeek(token.RPAREN) {
+		return nil
+	}
+
+	return params
+}
+
+func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	exp := &ast.CallExpression{Token: p.curToken, Function: function}
+
+	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	if exp.Arguments == nil {
+		return nil
+	}
+	exp.Rparen = p.curToken
+
+	return exp
+}
+
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+
+	array.Elements = p.parseExpressionList(token.RBRACKET)
+	if array.Elements == nil {
+		return nil
+	}
+	array.Rbracket = p.curToken
+
+	return array
+}
+
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.curToken, Pairs: []*ast.HashPair{}}
+
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		pair := p.parseHashPair()
+		if pair == nil {
+			p.skipBraces()
+			return nil
+		}
+		hash.Pairs = append(hash.Pairs, pair)
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expPeek(token.COMMA) {
+			p.skipBraces()
+			return nil
+		}
+	}
+	p.nextToken()
+	hash.Rbrace = p.curToken
+
+	return hash
+}
+
+func (p *Parser) parseHashPair() *ast.HashPair {
+	pair := &ast.HashPair{Key: p.parseExpression(LOWEST)}
+	if pair.Key == nil || !p.expPeek(token.COLON) {
+		return nil
+	}
+	pair.Colon = p.curToken
+
+	p.nextToken()
+	if pair.Value = p.parseExpression(LOWEST); pair.Value == nil {
+		return nil
+	}
+
+	return pair
+}
+
+// parseIndexExpression parses a[i] or the slice a[i:j], where either
+// bound may be left out.
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	lbracket := p.curToken
+
+	var index ast.Expression
+	if !p.peekTokenIs(token.COLON) {
+		p.nextToken()
+		if index = p.parseExpression(LOWEST); index == nil {
+			return nil
+		}
+	}
+
+	if !p.peekTokenIs(token.COLON) {
+		if !p.expPeek(token.RBRACKET) {
+			return nil
+		}
+		return &ast.IndexExpression{Token: lbracket, Left: left, Index: index, Rbracket: p.curToken}
+	}
+	p.nextToken()
+
+	slice := &ast.SliceExpression{Token: lbracket, Left: left, Low: index}
+	if !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		if slice.High = p.parseExpression(LOWEST); slice.High == nil {
+			return nil
+		}
+	}
+	if !p.expPeek(token.RBRACKET) {
+		return nil
+	}
+	slice.Rbracket = p.curToken
+
+	return slice
+}
+
+// parseExpressionList parses comma-separated expressions up to the end
+// token, leaving curToken on it. It returns nil on error.
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	list := []ast.Expression{}
+
+	if p.peekTokenIs(end) {
+		p.nextToken()
+		return list
+	}
+
+	p.nextToken()
+	exp := p.parseExpression(LOWEST)
+	if exp == nil {
+		return nil
+	}
+	list = append(list, exp)
+
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		p.nextToken()
+		exp := p.parseExpression(LOWEST)
+		if exp == nil {
+			return nil
+		}
+		list = append(list, exp)
+	}
+
+	if !p.expPeek(end) {
+		return nil
+	}
+
+	return list
+}
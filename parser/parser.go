@@ -2,16 +2,45 @@ package parser
 
 import (
 	"chimp/ast"
+	"chimp/diag"
 	"chimp/lexer"
 	"chimp/token"
-	"fmt"
 )
 
 type Parser struct {
 	l         *lexer.Lexer
 	curToken  token.Token
 	peekToken token.Token
-	errors    []string
+	errors    diag.List
+
+	// comments holds every comment read, and docs the /// comments among
+	// them that start their line. codeLine is the line the last token
+	// other than a comment ended on.
+	comments []*ast.Comment
+	docs     []*ast.Comment
+	codeLine int
+
+	// panicking is set from the first error in a statement until the
+	// parser has resynchronized, and silences the errors in between.
+	panicking bool
+
+	// braces is the number of '{' before curToken less the number of '}'.
+	braces int
+
+	// ahead holds tokens read from the lexer past peekToken, and next is
+	// the index of the one after peekToken. While speculating every token
+	// read is kept there so that backtrack can replay it.
+	ahead       []token.Token
+	next        int
+	speculating int
+
+	// owedGT is set when the first '>' of a '>>' closed a generic type
+	// and the second is still to close the enclosing one.
+	owedGT bool
+
+	prefixParseFns  map[token.TokenType]prefixParseFn
+	infixParseFns   map[token.TokenType]infixParseFn
+	postfixParseFns map[token.TokenType]postfixParseFn
 }
 
 type (
@@ -23,24 +52,178 @@ type (
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{l: l}
 
+	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
+	p.registerPrefix(token.IDENT, p.parseIdentifier)
+	p.registerPrefix(token.INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.HEX_INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.OCT_INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.BIN_INT, p.parseIntegerLiteral)
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)
+	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.RAW_STRING, p.parseStringLiteral)
+	p.registerPrefix(token.STRING_HEAD, p.parseInterpolatedString)
+	p.registerPrefix(token.TRUE, p.parseBoolean)
+	p.registerPrefix(token.FALSE, p.parseBoolean)
+	p.registerPrefix(token.NULL, p.parseNull)
+	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
+	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
+	p.registerPrefix(token.BANG, p.parsePrefixExpression)
+	p.registerPrefix(token.BITNOT, p.parsePrefixExpression)
+	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+
+	p.infixParseFns = make(map[token.TokenType]infixParseFn)
+	for tokType := range precedences {
+		p.registerInfix(tokType, p.parseInfixExpression)
+	}
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+
+	p.postfixParseFns = make(map[token.TokenType]postfixParseFn)
+	p.registerPostfix(token.INCREMENT, p.parsePostfixExpression)
+	p.registerPostfix(token.DECREMENT, p.parsePostfixExpression)
+
 	p.nextToken()
 	p.nextToken()
 	return p
 }
 
-func (p *Parser) Errors() []string {
+func (p *Parser) Errors() diag.List {
 	return p.errors
 }
 
-func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("%s:%d: p.peekToken.Type: expected '%s', got '%s' instead",
-		p.l.Filename, p.l.Line, t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+// insertable lists the tokens that a fix may offer to insert when they
+// are missing.
+var insertable = map[token.TokenType]bool{
+	token.SEMICOLON: true,
+	token.RPAREN:    true,
+	token.RBRACE:    true,
+}
+
+func (p *Parser) peekError(t token.TokenType) *diag.Diagnostic {
+	d := p.errorf(diag.UnexpectedToken, diag.TokenSpan(p.peekToken),
+		"expected '%s', got '%s' instead", t, p.peekToken.Type)
+	if insertable[t] {
+		d.Fix = &diag.Fix{
+			Span:        diag.At(p.curToken.End),
+			Replacement: string(t),
+			Message:     "insert the missing token",
+		}
+	}
+	return d
+}
+
+func (p *Parser) noPrefixParseFnError(t token.TokenType) {
+	p.errorf(diag.ExpectedExpression, diag.TokenSpan(p.curToken),
+		"expected an expression, got '%s'", t)
+}
+
+func (p *Parser) registerPrefix(tokType token.TokenType, fn prefixParseFn) {
+	p.prefixParseFns[tokType] = fn
+}
+
+func (p *Parser) registerInfix(tokType token.TokenType, fn infixParseFn) {
+	p.infixParseFns[tokType] = fn
+}
+
+func (p *Parser) registerPostfix(tokType token.TokenType, fn postfixParseFn) {
+	p.postfixParseFns[tokType] = fn
 }
 
 func (p *Parser) nextToken() {
+	switch p.curToken.Type {
+	case token.LBRACE:
+		p.braces++
+	case token.RBRACE:
+		p.braces--
+	}
 	p.curToken = p.peekToken
-	p.peekToken = p.l.NextToken()
+	p.peekToken = p.readToken()
+}
+
+func (p *Parser) readToken() token.Token {
+	if p.next == len(p.ahead) {
+		if p.speculating == 0 {
+			p.ahead, p.next = p.ahead[:0], 0
+			return p.lex()
+		}
+		p.ahead = append(p.ahead, p.lex())
+	}
+	tok := p.ahead[p.next]
+	p.next++
+	return tok
+}
+
+// lex returns the next token from the lexer, setting comments aside for
+// Program.Comments.
+func (p *Parser) lex() token.Token {
+	for {
+		tok := p.l.NextToken()
+		if tok.Type != token.COMMENT {
+			p.codeLine = tok.End.Line
+			return tok
+		}
+
+		c := &ast.Comment{Token: tok, Text: tok.Literal}
+		p.comments = append(p.comments, c)
+		if c.IsDoc() && tok.Pos.Line > p.codeLine {
+			p.docs = append(p.docs, c)
+		}
+	}
+}
+
+// docFor returns the /// comments on the lines right above tok, which
+// starts a declaration, or nil if there are none.
+func (p *Parser) docFor(tok token.Token) *ast.CommentGroup {
+	end := len(p.docs)
+	for end > 0 && p.docs[end-1].Pos().Offset > tok.Pos.Offset {
+		end--
+	}
+
+	start, line := end, tok.Pos.Line
+	for start > 0 && p.docs[start-1].End().Line == line-1 {
+		start--
+		line = p.docs[start].Pos().Line
+	}
+	if start == end {
+		return nil
+	}
+
+	return &ast.CommentGroup{List: append([]*ast.Comment{}, p.docs[start:end]...)}
+}
+
+// lookahead returns the token n places after peekToken.
+func (p *Parser) lookahead(n int) token.Token {
+	for len(p.ahead) < p.next+n {
+		p.ahead = append(p.ahead, p.lex())
+	}
+	return p.ahead[p.next+n-1]
+}
+
+// state is a parser position that backtrack can return to.
+type state struct {
+	cur, peek token.Token
+	next      int
+	braces    int
+	owedGT    bool
+	panicking bool
+}
+
+// speculate starts a parse that may be abandoned with backtrack. Errors
+// found while speculating are not reported.
+func (p *Parser) speculate() state {
+	p.speculating++
+	return state{p.curToken, p.peekToken, p.next, p.braces, p.owedGT, p.panicking}
+}
+
+// backtrack ends a speculative parse, returning to s.
+func (p *Parser) backtrack(s state) {
+	p.speculating--
+	p.curToken, p.peekToken, p.next, p.braces = s.cur, s.peek, s.next, s.braces
+	p.owedGT, p.panicking = s.owedGT, s.panicking
 }
 
 func (p *Parser) ParseProgram() *ast.Program {
@@ -48,33 +231,48 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program.Statements = []ast.Statement{}
 
 	for p.curToken.Type != token.EOF {
-		stmt := p.parseStatement()
-		if stmt != nil {
+		if stmt := p.parseStatement(); stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
 		p.nextToken()
 	}
+	program.Comments = p.comments
 
 	return program
 }
 
+// parseStatement parses the statement starting at curToken, leaving
+// curToken on its last token. If the statement has an error it returns nil
+// and skips ahead to the end of the statement instead.
 func (p *Parser) parseStatement() ast.Statement {
+	var stmt ast.Statement
+	start := p.braces
+
 	switch p.curToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
-	case token.INT_KW:
-		return p.parseIntStatement()
-	case token.BOOL_KW:
-		return p.parseBoolStatement()
+		stmt = nilIfFailed(p.parseLetStatement())
+	case token.INT_KW, token.BOOL_KW, token.STRING_KW, token.QUESTION:
+		stmt = nilIfFailed(p.parseVarDecl())
 	case token.RETURN:
-		return p.parseReturnStatement()
+		stmt = nilIfFailed(p.parseReturnStatement())
 	default:
+		if p.atVarDecl() {
+			stmt = nilIfFailed(p.parseVarDecl())
+		} else {
+			stmt = nilIfFailed(p.parseExpressionStatement())
+		}
+	}
+
+	if p.panicking {
+		p.synchronize(start)
 		return nil
 	}
+
+	return stmt
 }
 
 func (p *Parser) parseLetStatement() *ast.LetStatement {
-	stmt := &ast.LetStatement{Token: p.curToken}
+	stmt := &ast.LetStatement{Doc: p.docFor(p.curToken), Token: p.curToken}
 
 	if !p.expPeek(token.IDENT) {
 		return nil
@@ -86,17 +284,32 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 		return nil
 	}
 
-	// TODO: We're skipping the expressions until we
-	// encounter a semicolon
-	for !p.curTokenIs(token.SEMICOLON) {
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
 	return stmt
 }
 
-func (p *Parser) parseIntStatement() *ast.IntStatement {
-	stmt := &ast.IntStatement{Token: p.curToken}
+// atVarDecl reports whether the statement at curToken, which may also
+// begin an expression, is a declaration: a type, a name and '='.
+func (p *Parser) atVarDecl() bool {
+	s := p.speculate()
+	defer p.backtrack(s)
+
+	return p.parseType(true) != nil && p.peekTokenIs(token.IDENT) &&
+		p.lookahead(1).Type == token.ASSIGN
+}
+
+func (p *Parser) parseVarDecl() *ast.VarDecl {
+	stmt := &ast.VarDecl{Doc: p.docFor(p.curToken)}
+	if stmt.Type = p.parseType(true); stmt.Type == nil {
+		return nil
+	}
 
 	if !p.expPeek(token.IDENT) {
 		return nil
@@ -108,45 +321,68 @@ func (p *Parser) parseIntStatement() *ast.IntStatement {
 		return nil
 	}
 
-	// TODO: We're skipping the expressions until we
-	// encounter a semicolon
-	for !p.curTokenIs(token.SEMICOLON) {
+	p.nextToken()
+
+	stmt.Value = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
 	return stmt
 }
 
-func (p *Parser) parseBoolStatement() *ast.BoolStatement {
-	stmt := &ast.BoolStatement{Token: p.curToken}
+func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
+	stmt := &ast.ReturnStatement{Token: p.curToken}
 
-	if !p.expPeek(token.IDENT) {
-		return nil
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+		return stmt
 	}
 
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	p.nextToken()
 
-	if !p.expPeek(token.ASSIGN) {
-		return nil
-	}
+	stmt.ReturnValue = p.parseExpression(LOWEST)
 
-	// TODO: We're skipping the expressions until we
-	// encounter a semicolon
-	for !p.curTokenIs(token.SEMICOLON) {
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
 	return stmt
 }
 
-func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
-	stmt := &ast.ReturnStatement{Token: p.curToken}
+// parseBlockStatement parses statements up to the '}' matching the '{' at
+// curToken, recovering from errors in each of them.
+func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	block := &ast.BlockStatement{Token: p.curToken}
+	block.Statements = []ast.Statement{}
 
 	p.nextToken()
 
-	// TODO: We're skipping the expressions until we
-	// encounter a semicolon
-	for !p.curTokenIs(token.SEMICOLON) {
+	for !p.curTokenIs(token.RBRACE) {
+		if p.curTokenIs(token.EOF) {
+			p.errorf(diag.UnexpectedToken, diag.TokenSpan(p.curToken),
+				"expected '}', got '%s' instead", p.curToken.Type).Notes = []diag.Note{
+				{Span: diag.TokenSpan(block.Token), Message: "unclosed '{' opened here"},
+			}
+			return nil
+		}
+		if stmt := p.parseStatement(); stmt != nil {
+			block.Statements = append(block.Statements, stmt)
+		}
+		p.nextToken()
+	}
+	block.Rbrace = p.curToken
+
+	return block
+}
+
+func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
+	stmt := &ast.ExpressionStatement{Token: p.curToken}
+
+	stmt.Expression = p.parseExpression(LOWEST)
+
+	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 
@@ -2,8 +2,12 @@ package parser
 
 import (
 	"chimp/ast"
+	"chimp/diag"
 	"chimp/lexer"
+	"fmt"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func checkParserErrors(t *testing.T, p *Parser) {
@@ -13,8 +17,8 @@ func checkParserErrors(t *testing.T, p *Parser) {
 	}
 
 	t.Errorf("parser has %d errors", len(errors))
-	for _, msg := range errors {
-		t.Errorf("parser error: %q", msg)
+	for _, err := range errors {
+		t.Errorf("parser error: %q", err.Error())
 	}
 	t.FailNow()
 }
@@ -83,14 +87,14 @@ func testLetStatement(t *testing.T, s ast.Statement, name string, p *Parser) boo
 	return true
 }
 
-func TestIntStatements(t *testing.T) {
+func TestVarDecls(t *testing.T) {
 	input := `
 int x = 5;
-int y = 10;
-int foobar = 838383;
+bool y = true;
+string foobar = "meow";
 `
 
-	l := lexer.New(input, "inttest")
+	l := lexer.New(input, "vartest")
 	p := New(l)
 
 	program := p.ParseProgram()
@@ -103,110 +107,231 @@ int foobar = 838383;
 	}
 
 	tests := []struct {
+		expType  string
 		expIdent string
 	}{
-		{"x"},
-		{"y"},
-		{"foobar"},
+		{"int", "x"},
+		{"bool", "y"},
+		{"string", "foobar"},
 	}
 
 	for index, testStruct := range tests {
 		stmt := program.Statements[index]
-		if !testIntStatement(t, stmt, testStruct.expIdent, p) {
+		if !testVarDecl(t, stmt, testStruct.expType, testStruct.expIdent, p) {
 			return
 		}
 	}
 }
 
-func testIntStatement(t *testing.T, s ast.Statement, name string, p *Parser) bool {
-	if s.TokenLiteral() != "int" {
-		t.Errorf("%s:%d: s.TokenLiteral not 'int', got=%q\n",
-			p.l.Filename, p.l.Line, s.TokenLiteral())
+func testVarDecl(t *testing.T, s ast.Statement, typ, name string, p *Parser) bool {
+	if s.TokenLiteral() != typ {
+		t.Errorf("%s:%d: s.TokenLiteral: expected=%q, got=%q\n",
+			p.l.Filename, p.l.Line, typ, s.TokenLiteral())
 		return false
 	}
 
-	intStmt, ok := s.(*ast.IntStatement)
+	decl, ok := s.(*ast.VarDecl)
 	if !ok {
-		t.Errorf("%s:%d: s not *ast.IntStatement, got=%T\n",
+		t.Errorf("%s:%d: s: expected=*ast.VarDecl, got=%T\n",
 			p.l.Filename, p.l.Line, s)
 		return false
 	}
 
-	if intStmt.Name.Value != name {
-		t.Errorf("%s:%d: intStmt.Name.Value not '%s'. got=%s\n",
-			p.l.Filename, p.l.Line, name, intStmt.Name.Value)
+	if decl.Type.String() != typ {
+		t.Errorf("%s:%d: decl.Type: expected=%s. got=%s\n",
+			p.l.Filename, p.l.Line, typ, decl.Type)
+		return false
+	}
+
+	if decl.Name.Value != name {
+		t.Errorf("%s:%d: decl.Name.Value: expected='%s'. got=%s\n",
+			p.l.Filename, p.l.Line, name, decl.Name.Value)
 		return false
 	}
 
-	if intStmt.Name.TokenLiteral() != name {
-		t.Errorf("%s:%d: intStmt.Name.TokenLiteral not '%s'. got=%s\n",
-			p.l.Filename, p.l.Line, name, intStmt.Name.TokenLiteral())
+	if decl.Name.TokenLiteral() != name {
+		t.Errorf("%s:%d: decl.Name.TokenLiteral: expected=%s. got=%s\n",
+			p.l.Filename, p.l.Line, name, decl.Name.TokenLiteral())
 		return false
 	}
 
 	return true
 }
 
-func TestBoolStatements(t *testing.T) {
-	input := `
-bool x = true;
-bool y = false;
-`
+func TestTypeExpressions(t *testing.T) {
+	tests := []struct {
+		input   string
+		expType string
+		typ     ast.TypeExpr
+	}{
+		{"float f = 1.5;", "float", &ast.NamedType{}},
+		{"Point p = origin;", "Point", &ast.NamedType{}},
+		{"List<int> xs = list();", "List<int>", &ast.GenericType{}},
+		{"Map<string, List<int>> m = map();", "Map<string, List<int>>", &ast.GenericType{}},
+		{"List<List<List<int>>> xs = nest();", "List<List<List<int>>>", &ast.GenericType{}},
+		{"?int n = null;", "?int", &ast.NullableType{}},
+		{"?List<?int> n = null;", "?List<?int>", &ast.NullableType{}},
+		{"IOError!string s = read();", "IOError!string", &ast.ErrorUnionType{}},
+		{"!int n = parse();", "!int", &ast.ErrorUnionType{}},
+		{"fn(int, bool) string f = g;", "fn(int, bool) string", &ast.FunctionType{}},
+		{"fn(int) f = g;", "fn(int)", &ast.FunctionType{}},
+		{"fn() fn(int) Point f = g;", "fn() fn(int) Point", &ast.FunctionType{}},
+		{"fn(?int) !Result<int> f = g;", "fn(?int) !Result<int>", &ast.FunctionType{}},
+	}
 
-	l := lexer.New(input, "booltest")
-	p := New(l)
+	for _, tt := range tests {
+		l := lexer.New(tt.input, "typetest")
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
 
-	program := p.ParseProgram()
-	checkParserErrors(t, p)
-	if program == nil {
-		t.Fatalf("ParseProgram() returned nil\n")
-	}
-	if len(program.Statements) != 2 {
-		t.Fatalf("Expected 2 statements. got=%d\n", len(program.Statements))
+		if len(program.Statements) != 1 {
+			t.Fatalf("%q: expected 1 statement. got=%d", tt.input, len(program.Statements))
+		}
+		decl, ok := program.Statements[0].(*ast.VarDecl)
+		if !ok {
+			t.Errorf("%q: expected=*ast.VarDecl. got=%T", tt.input, program.Statements[0])
+			continue
+		}
+		if reflect.TypeOf(decl.Type) != reflect.TypeOf(tt.typ) {
+			t.Errorf("%q: decl.Type: expected=%T. got=%T", tt.input, tt.typ, decl.Type)
+		}
+		if decl.Type.String() != tt.expType {
+			t.Errorf("%q: decl.Type: expected=%s. got=%s", tt.input, tt.expType, decl.Type)
+		}
+		if decl.Type.End().Offset != decl.Name.Pos().Offset-1 {
+			t.Errorf("%q: decl.Type ends at offset %d. expected=%d", tt.input, decl.Type.End().Offset, decl.Name.Pos().Offset-1)
+		}
 	}
+}
 
+func TestNotVarDecls(t *testing.T) {
 	tests := []struct {
-		expIdent string
+		input    string
+		expected string
 	}{
-		{"x"},
-		{"y"},
+		{"a < b;", "(a < b)"},
+		{"a < b > c;", "((a < b) > c)"},
+		{"a >> b;", "(a >> b)"},
+		{"!x;", "(!x)"},
+		{"x = 5;", "x = 5"},
+		{"f(x);", "f(x)"},
+		{"fn(x) { x }(1);", "fn(x) { x }(1)"},
+		{"fn(x) int { x };", "fn(x) int { x }"},
 	}
 
-	for index, testStruct := range tests {
-		stmt := program.Statements[index]
-		if !testBoolStatement(t, stmt, testStruct.expIdent, p) {
-			return
+	for _, tt := range tests {
+		l := lexer.New(tt.input, "exprtest")
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Errorf("%q: expected=*ast.ExpressionStatement. got=%T", tt.input, program.Statements[0])
+			continue
+		}
+		if stmt.Expression.String() != tt.expected {
+			t.Errorf("%q: expected=%q. got=%q", tt.input, tt.expected, stmt.Expression.String())
 		}
 	}
 }
 
-func testBoolStatement(t *testing.T, s ast.Statement, name string, p *Parser) bool {
-	if s.TokenLiteral() != "bool" {
-		t.Errorf("%s:%d: s.TokenLiteral: expected='bool', got=%q\n",
-			p.l.Filename, p.l.Line, s.TokenLiteral())
-		return false
+func TestTypeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"?List<int x = 1;", "expected '>', got 'IDENT' instead"},
+		{"?List<int>> x = 1;", "unexpected '>' after type ?List<int>"},
+		{"?5 x = 1;", "expected a type, got 'INT' instead"},
+		{"int 5 = 1;", "expected 'IDENT', got 'INT' instead"},
 	}
 
-	boolStmt, ok := s.(*ast.BoolStatement)
-	if !ok {
-		t.Errorf("%s:%d: s: expected=*ast.BoolStatement, got=%T\n",
-			p.l.Filename, p.l.Line, s)
-		return false
+	for _, tt := range tests {
+		l := lexer.New(tt.input, "typeerr")
+		p := New(l)
+		p.ParseProgram()
+
+		errs := p.Errors()
+		if len(errs) != 1 {
+			t.Errorf("%q: expected 1 error. got=%d: %v", tt.input, len(errs), errs)
+			continue
+		}
+		if errs[0].Message != tt.expected {
+			t.Errorf("%q: expected=%q. got=%q", tt.input, tt.expected, errs[0].Message)
+		}
 	}
+}
 
-	if boolStmt.Name.Value != name {
-		t.Errorf("%s:%d: boolStmt.Name.Value: expected='%s'. got=%s\n",
-			p.l.Filename, p.l.Line, name, boolStmt.Name.Value)
-		return false
+func TestComments(t *testing.T) {
+	input := `// one
+let x = /* two */ 1;
+List<int> /* three */ xs = f(); // four
+`
+
+	l := lexer.New(input, "commenttest")
+	l.Mode = lexer.ScanComments
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("Expected 2 statements. got=%d", len(program.Statements))
+	}
+	expected := []string{"// one", "/* two */", "/* three */", "// four"}
+	if len(program.Comments) != len(expected) {
+		t.Fatalf("program.Comments: expected=%d. got=%d", len(expected), len(program.Comments))
+	}
+	for i, exp := range expected {
+		if program.Comments[i].Text != exp {
+			t.Errorf("program.Comments[%d]: expected=%q. got=%q", i, exp, program.Comments[i].Text)
+		}
 	}
+}
 
-	if boolStmt.Name.TokenLiteral() != name {
-		t.Errorf("%s:%d: boolStmt.Name.TokenLiteral: expected=%s. got=%s\n",
-			p.l.Filename, p.l.Line, name, boolStmt.Name.TokenLiteral())
-		return false
+func TestDocComments(t *testing.T) {
+	input := `/// Adds one.
+/// Really.
+let a = 1;
+
+/// Set apart.
+
+let b = 2;
+let c = 3; /// trailing
+//// not a doc
+// plain
+/// A count.
+int d = 4;
+`
+
+	l := lexer.New(input, "doctest")
+	l.Mode = lexer.ScanComments
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	tests := []string{"Adds one.\nReally.", "", "", "A count."}
+	if len(program.Statements) != len(tests) {
+		t.Fatalf("Expected %d statements. got=%d", len(tests), len(program.Statements))
+	}
+	for i, expected := range tests {
+		var doc *ast.CommentGroup
+		switch stmt := program.Statements[i].(type) {
+		case *ast.LetStatement:
+			doc = stmt.Doc
+		case *ast.VarDecl:
+			doc = stmt.Doc
+		}
+		if got := doc.Text(); got != expected {
+			t.Errorf("statement %d: expected doc=%q. got=%q", i, expected, got)
+		}
 	}
 
-	return true
+	program = New(lexer.New(input, "doctest")).ParseProgram()
+	if doc := program.Statements[0].(*ast.LetStatement).Doc; doc != nil {
+		t.Errorf("expected no doc without ScanComments. got=%q", doc.Text())
+	}
 }
 
 func TestReturnStatements(t *testing.T) {
@@ -241,68 +366,943 @@ return true;
 	}
 }
 
-/*
-func TestStringStatements(t *testing.T) {
-	input := `
-string x = "meow";
-string y = "10";
-string foobar = ":";
-`
+func TestIdentifierExpression(t *testing.T) {
+	input := "foobar;"
 
-	l := lexer.New(input)
+	l := lexer.New(input, "identtest")
 	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements: expected=1. got=%d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0]: expected=*ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	testIdentifier(t, stmt.Expression, "foobar")
+}
+
+func TestIntegerLiteralExpression(t *testing.T) {
+	input := "5;"
 
+	l := lexer.New(input, "inttest")
+	p := New(l)
 	program := p.ParseProgram()
 	checkParserErrors(t, p)
-	if program == nil {
-		t.Fatalf("ParseProgram() returned nil\n")
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements: expected=1. got=%d", len(program.Statements))
 	}
-	if len(program.Statements) != 3 {
-		t.Fatalf("Expected 3 statements. got=%d\n", len(program.Statements))
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0]: expected=*ast.ExpressionStatement. got=%T",
+			program.Statements[0])
 	}
 
+	testIntegerLiteral(t, stmt.Expression, 5)
+}
+
+func TestPrefixExpressions(t *testing.T) {
 	tests := []struct {
-		expIdent string
+		input    string
+		operator string
+		value    interface{}
 	}{
-		{"x"},
-		{"y"},
-		{"foobar"},
+		{"!5;", "!", 5},
+		{"-15;", "-", 15},
+		{"~7;", "~", 7},
+		{"!true;", "!", true},
+		{"!false;", "!", false},
+		{"-x;", "-", "x"},
 	}
 
-	for index, testStruct := range tests {
-		stmt := program.Statements[index]
-		if !testStringStatement(t, stmt, testStruct.expIdent, p) {
+	for _, tt := range tests {
+		l := lexer.New(tt.input, "prefixtest")
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements: expected=1. got=%d", len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0]: expected=*ast.ExpressionStatement. got=%T",
+				program.Statements[0])
+		}
+
+		exp, ok := stmt.Expression.(*ast.PrefixExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression: expected=*ast.PrefixExpression. got=%T", stmt.Expression)
+		}
+		if exp.Operator != tt.operator {
+			t.Fatalf("exp.Operator: expected='%s'. got='%s'", tt.operator, exp.Operator)
+		}
+		if !testLiteralExpression(t, exp.Right, tt.value) {
 			return
 		}
 	}
 }
 
-func testStringStatement(t *testing.T, s ast.Statement, name string, p *Parser) bool {
-	if s.TokenLiteral() != "string" {
-        t.Errorf("%s:%d: s.TokenLiteral not 'string', got=%q\n",
-            p.l.Filename, p.l.Line,s.TokenLiteral())
+func TestInfixExpressions(t *testing.T) {
+	tests := []struct {
+		input      string
+		leftValue  interface{}
+		operator   string
+		rightValue interface{}
+	}{
+		{"5 + 5;", 5, "+", 5},
+		{"5 - 5;", 5, "-", 5},
+		{"5 * 5;", 5, "*", 5},
+		{"5 / 5;", 5, "/", 5},
+		{"5 ** 5;", 5, "**", 5},
+		{"5 > 5;", 5, ">", 5},
+		{"5 >= 5;", 5, ">=", 5},
+		{"5 < 5;", 5, "<", 5},
+		{"5 <= 5;", 5, "<=", 5},
+		{"5 == 5;", 5, "==", 5},
+		{"5 != 5;", 5, "!=", 5},
+		{"5 & 5;", 5, "&", 5},
+		{"5 | 5;", 5, "|", 5},
+		{"5 ^ 5;", 5, "^", 5},
+		{"5 << 5;", 5, "<<", 5},
+		{"5 >> 5;", 5, ">>", 5},
+		{"x ?? 5;", "x", "??", 5},
+		{"true && false;", true, "&&", false},
+		{"true || false;", true, "||", false},
+		{"true ^^ false;", true, "^^", false},
+		{"true == true;", true, "==", true},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input, "infixtest")
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements: expected=1. got=%d", len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0]: expected=*ast.ExpressionStatement. got=%T",
+				program.Statements[0])
+		}
+
+		if !testInfixExpression(t, stmt.Expression, tt.leftValue, tt.operator, tt.rightValue) {
+			return
+		}
+	}
+}
+
+func TestOperatorPrecedenceParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"-a * b", "((-a) * b)"},
+		{"!-a", "(!(-a))"},
+		{"a + b + c", "((a + b) + c)"},
+		{"a + b - c", "((a + b) - c)"},
+		{"a * b * c", "((a * b) * c)"},
+		{"a + b / c", "(a + (b / c))"},
+		{"a + b * c + d / e - f", "(((a + (b * c)) + (d / e)) - f)"},
+		{"3 + 4; -5 * 5", "(3 + 4); ((-5) * 5)"},
+		{"a * [1, 2, 3, 4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
+		{"add(a * b[2], b[1], 2 * [1, 2][1])", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))"},
+		{"-a[1:]", "(-(a[1:]))"},
+		{"f(x)[0](y)", "(f(x)[0])(y)"},
+		{"5 > 4 == 3 < 4", "((5 > 4) == (3 < 4))"},
+		{"5 <= 4 != 3 >= 4", "((5 <= 4) != (3 >= 4))"},
+		{"3 + 4 * 5 == 3 * 1 + 4 * 5", "((3 + (4 * 5)) == ((3 * 1) + (4 * 5)))"},
+		{"2 ** 3 ** 2", "(2 ** (3 ** 2))"},
+		{"-2 ** 2", "(-(2 ** 2))"},
+		{"2 * 3 ** 2", "(2 * (3 ** 2))"},
+		{"2 ** -1", "(2 ** (-1))"},
+		{"a << 1 + 2", "(a << (1 + 2))"},
+		{"a & b << 1", "(a & (b << 1))"},
+		{"a | b ^ c & d", "(a | (b ^ (c & d)))"},
+		{"a == b | c", "(a == (b | c))"},
+		{"~a & b", "((~a) & b)"},
+		{"a && b || c && d", "((a && b) || (c && d))"},
+		{"a || b ^^ c && d", "(a || (b ^^ (c && d)))"},
+		{"a ?? b ?? c", "(a ?? (b ?? c))"},
+		{"a ?? b || c", "(a ?? (b || c))"},
+		{"a = b + c", "a = (b + c)"},
+		{"a = b = c ?? d", "a = b = (c ?? d)"},
+		{"true", "true"},
+		{"null", "null"},
+		{"3 > 5 == false", "((3 > 5) == false)"},
+		{"1 + (2 + 3) + 4", "((1 + (2 + 3)) + 4)"},
+		{"(5 + 5) * 2", "((5 + 5) * 2)"},
+		{"-(5 + 5)", "(-(5 + 5))"},
+		{"!(true == true)", "(!(true == true))"},
+		{"(2 ** 3) ** 2", "((2 ** 3) ** 2)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input, "precedencetest")
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestStatementValues(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1 + 2;", "let x = (1 + 2);"},
+		{"int y = 2 ** 8;", "int y = (2 ** 8);"},
+		{"bool z = !true;", "bool z = (!true);"},
+		{"return a ?? 0;", "return (a ?? 0);"},
+		{"return;", "return;"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input, "valuetest")
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func testInfixExpression(t *testing.T, exp ast.Expression, left interface{},
+	operator string, right interface{}) bool {
+
+	opExp, ok := exp.(*ast.InfixExpression)
+	if !ok {
+		t.Errorf("exp: expected=*ast.InfixExpression. got=%T(%s)", exp, exp)
+		return false
+	}
+
+	if !testLiteralExpression(t, opExp.Left, left) {
+		return false
+	}
+
+	if opExp.Operator != operator {
+		t.Errorf("exp.Operator: expected='%s'. got=%q", operator, opExp.Operator)
+		return false
+	}
+
+	if !testLiteralExpression(t, opExp.Right, right) {
+		return false
+	}
+
+	return true
+}
+
+func testLiteralExpression(t *testing.T, exp ast.Expression, expected interface{}) bool {
+	switch v := expected.(type) {
+	case int:
+		return testIntegerLiteral(t, exp, int64(v))
+	case int64:
+		return testIntegerLiteral(t, exp, v)
+	case string:
+		return testIdentifier(t, exp, v)
+	case bool:
+		return testBooleanLiteral(t, exp, v)
+	}
+	t.Errorf("type of exp not handled. got=%T", exp)
+	return false
+}
+
+func testIntegerLiteral(t *testing.T, il ast.Expression, value int64) bool {
+	integ, ok := il.(*ast.IntegerLiteral)
+	if !ok {
+		t.Errorf("il: expected=*ast.IntegerLiteral. got=%T", il)
+		return false
+	}
+
+	if integ.Value != value {
+		t.Errorf("integ.Value: expected=%d. got=%d", value, integ.Value)
+		return false
+	}
+
+	if integ.TokenLiteral() != fmt.Sprintf("%d", value) {
+		t.Errorf("integ.TokenLiteral: expected=%d. got=%s", value, integ.TokenLiteral())
+		return false
+	}
+
+	return true
+}
+
+func testIdentifier(t *testing.T, exp ast.Expression, value string) bool {
+	ident, ok := exp.(*ast.Identifier)
+	if !ok {
+		t.Errorf("exp: expected=*ast.Identifier. got=%T", exp)
+		return false
+	}
+
+	if ident.Value != value {
+		t.Errorf("ident.Value: expected=%s. got=%s", value, ident.Value)
+		return false
+	}
+
+	if ident.TokenLiteral() != value {
+		t.Errorf("ident.TokenLiteral: expected=%s. got=%s", value, ident.TokenLiteral())
 		return false
 	}
 
-	stringStmt, ok := s.(*ast.StringStatement)
+	return true
+}
+
+func testBooleanLiteral(t *testing.T, exp ast.Expression, value bool) bool {
+	bo, ok := exp.(*ast.Boolean)
 	if !ok {
-        t.Errorf("%s:%d: s not *ast.StringStatement, got=%T\n",
-            p.l.Filename, p.l.Line,s)
+		t.Errorf("exp: expected=*ast.Boolean. got=%T", exp)
 		return false
 	}
 
-	if stringStmt.Name.Value != name {
-        t.Errorf("%s:%d: stringStmt.Name.Value not '%s'. got=%s\n",
-            p.l.Filename, p.l.Line,name, stringStmt.Name.Value)
+	if bo.Value != value {
+		t.Errorf("bo.Value: expected=%t. got=%t", value, bo.Value)
 		return false
 	}
 
-	if stringStmt.Name.TokenLiteral() != name {
-        t.Errorf(%s:%d: "stringStmt.Name.TokenLiteral not '%s'. got=%s\n",
-			p.l.Filename, p.l.Line,name, stringStmt.Name.TokenLiteral())
+	if bo.TokenLiteral() != fmt.Sprintf("%t", value) {
+		t.Errorf("bo.TokenLiteral: expected=%t. got=%s", value, bo.TokenLiteral())
 		return false
 	}
 
 	return true
 }
-*/
+
+func TestNodePositions(t *testing.T) {
+	input := `let x = 1;
+let y = -x + 20;`
+
+	l := lexer.New(input, "postest")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("program.Statements: expected=2. got=%d", len(program.Statements))
+	}
+
+	stmt := program.Statements[1].(*ast.LetStatement)
+	if stmt.Pos().String() != "postest:2:1" {
+		t.Errorf("stmt.Pos(): expected=postest:2:1. got=%s", stmt.Pos())
+	}
+
+	value := stmt.Value.(*ast.InfixExpression)
+	if value.Pos().String() != "postest:2:9" {
+		t.Errorf("value.Pos(): expected=postest:2:9. got=%s", value.Pos())
+	}
+	if value.End().String() != "postest:2:16" {
+		t.Errorf("value.End(): expected=postest:2:16. got=%s", value.End())
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	input := `let x = 1;
+let = 5;`
+
+	l := lexer.New(input, "errtest")
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected parser errors. got none")
+	}
+
+	expected := "errtest:2:5: error[P0001]: expected 'IDENT', got '=' instead"
+	if errors[0].Error() != expected {
+		t.Errorf("errors[0]: expected=%q. got=%q", expected, errors[0].Error())
+	}
+}
+
+func TestStringLiteralExpression(t *testing.T) {
+	input := `"hello\tworld";`
+
+	l := lexer.New(input, "stringtest")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression: expected=*ast.StringLiteral. got=%T", stmt.Expression)
+	}
+
+	if literal.Value != "hello\tworld" {
+		t.Errorf("literal.Value: expected=%q. got=%q", "hello\tworld", literal.Value)
+	}
+	if literal.String() != input[:len(input)-1] {
+		t.Errorf("literal.String(): expected=%s. got=%s", input[:len(input)-1], literal.String())
+	}
+}
+
+func TestInterpolatedStringExpression(t *testing.T) {
+	input := `"sum: ${a + b}, first: ${a}!";`
+
+	l := lexer.New(input, "interptest")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	str, ok := stmt.Expression.(*ast.InterpolatedString)
+	if !ok {
+		t.Fatalf("stmt.Expression: expected=*ast.InterpolatedString. got=%T", stmt.Expression)
+	}
+
+	if len(str.Parts) != 5 {
+		t.Fatalf("str.Parts: expected=5. got=%d", len(str.Parts))
+	}
+	testInfixExpression(t, str.Parts[1], "a", "+", "b")
+	testIdentifier(t, str.Parts[3], "a")
+
+	expected := `"sum: ${(a + b)}, first: ${a}!"`
+	if str.String() != expected {
+		t.Errorf("str.String(): expected=%s. got=%s", expected, str.String())
+	}
+}
+
+func TestUnclosedParenNote(t *testing.T) {
+	l := lexer.New("let x = (1 + 2;", "notetest")
+	p := New(l)
+	p.ParseProgram()
+
+	if len(p.Errors()) == 0 {
+		t.Fatalf("expected parser errors. got none")
+	}
+
+	err := p.Errors()[0]
+	if err.Fix == nil || err.Fix.Replacement != ")" {
+		t.Errorf("err.Fix: expected to insert ')'. got=%+v", err.Fix)
+	}
+	if len(err.Notes) != 1 || err.Notes[0].Span.Start.Column != 9 {
+		t.Errorf("err.Notes: expected a note at column 9. got=%+v", err.Notes)
+	}
+}
+
+func TestNumberLiteralExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"1_000_000", int64(1000000)},
+		{"0xFF", int64(255)},
+		{"0o755", int64(493)},
+		{"0b1010", int64(10)},
+		{"1.5e-3", 1.5e-3},
+		{"2_000.25", 2000.25},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input, "numtest")
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		switch expected := tt.expected.(type) {
+		case int64:
+			lit, ok := stmt.Expression.(*ast.IntegerLiteral)
+			if !ok {
+				t.Fatalf("stmt.Expression: expected=*ast.IntegerLiteral. got=%T", stmt.Expression)
+			}
+			if lit.Value != expected {
+				t.Errorf("lit.Value: expected=%d. got=%d", expected, lit.Value)
+			}
+		case float64:
+			lit, ok := stmt.Expression.(*ast.FloatLiteral)
+			if !ok {
+				t.Fatalf("stmt.Expression: expected=*ast.FloatLiteral. got=%T", stmt.Expression)
+			}
+			if lit.Value != expected {
+				t.Errorf("lit.Value: expected=%g. got=%g", expected, lit.Value)
+			}
+		}
+	}
+}
+
+func TestIntegerOverflow(t *testing.T) {
+	l := lexer.New("9223372036854775808;", "overflowtest")
+	p := New(l)
+	p.ParseProgram()
+
+	if len(p.Errors()) != 1 {
+		t.Fatalf("p.Errors(): expected 1 error. got=%v", p.Errors())
+	}
+	if p.Errors()[0].Code != diag.InvalidNumber {
+		t.Errorf("p.Errors()[0].Code: expected=%s. got=%s", diag.InvalidNumber, p.Errors()[0].Code)
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	input := `let = 5;
+let y 10;
+let ok = 1;
+int z = ;
+bool b = (true;
+let ok2 = 2;
+`
+
+	l := lexer.New(input, "recoverytest")
+	p := New(l)
+	program := p.ParseProgram()
+
+	expLines := []int{1, 2, 4, 5}
+	errors := p.Errors()
+	if len(errors) != len(expLines) {
+		t.Fatalf("p.Errors(): expected %d errors. got=%d (%v)", len(expLines), len(errors), errors)
+	}
+	for i, line := range expLines {
+		if errors[i].Span.Start.Line != line {
+			t.Errorf("errors[%d]: expected on line %d. got=%s", i, line, errors[i])
+		}
+	}
+
+	if program.String() != "let ok = 1; let ok2 = 2;" {
+		t.Errorf("program.String(): expected only the valid statements. got=%q", program.String())
+	}
+}
+
+func TestNoCascadingErrors(t *testing.T) {
+	input := "let x = 1 + * 2 + ) + ( ; let y = 2;"
+
+	l := lexer.New(input, "cascadetest")
+	p := New(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 1 {
+		t.Fatalf("p.Errors(): expected 1 error. got=%d (%v)", len(p.Errors()), p.Errors())
+	}
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements: expected=1. got=%d", len(program.Statements))
+	}
+	testLetStatement(t, program.Statements[0], "y", p)
+}
+
+func TestParsingStopsAtEOF(t *testing.T) {
+	tests := []string{
+		"let x = 5",
+		"let x =",
+		"let",
+		"return",
+		"(1 + ",
+		"1 +",
+		"\"a ${1 + ",
+		"}}}",
+		"let x = 1 }",
+	}
+
+	for _, input := range tests {
+		done := make(chan struct{})
+		go func() {
+			p := New(lexer.New(input, "eoftest"))
+			p.ParseProgram()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("ParseProgram(%q) did not terminate", input)
+		}
+	}
+
+	p := New(lexer.New("let x = 5", "eoftest"))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if program.String() != "let x = 5;" {
+		t.Errorf("program.String(): expected=%q. got=%q", "let x = 5;", program.String())
+	}
+}
+
+func TestIfExpression(t *testing.T) {
+	input := `if x < y { x } else if x > y { y } else { 0 }`
+
+	l := lexer.New(input, "iftest")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements: expected=1. got=%d", len(program.Statements))
+	}
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression: expected=*ast.IfExpression. got=%T", stmt.Expression)
+	}
+
+	if !testInfixExpression(t, exp.Condition, "x", "<", "y") {
+		return
+	}
+	if len(exp.Consequence.Statements) != 1 {
+		t.Fatalf("exp.Consequence.Statements: expected=1. got=%d", len(exp.Consequence.Statements))
+	}
+	consequence := exp.Consequence.Statements[0].(*ast.ExpressionStatement)
+	testIdentifier(t, consequence.Expression, "x")
+
+	elseIf, ok := exp.Alternative.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.IfExpression)
+	if !ok {
+		t.Fatalf("exp.Alternative: expected a nested *ast.IfExpression. got=%s", exp.Alternative)
+	}
+	testInfixExpression(t, elseIf.Condition, "x", ">", "y")
+	if elseIf.Alternative == nil {
+		t.Errorf("elseIf.Alternative: expected a block. got=nil")
+	}
+}
+
+func TestFunctionLiteralParsing(t *testing.T) {
+	input := `fn(int x, bool y, z) { return x + y; }`
+
+	l := lexer.New(input, "fntest")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	function, ok := stmt.Expression.(*ast.FunctionLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression: expected=*ast.FunctionLiteral. got=%T", stmt.Expression)
+	}
+
+	expParams := []struct {
+		typ  string
+		name string
+	}{
+		{"int", "x"},
+		{"bool", "y"},
+		{"", "z"},
+	}
+	if len(function.Parameters) != len(expParams) {
+		t.Fatalf("function.Parameters: expected=%d. got=%d", len(expParams), len(function.Parameters))
+	}
+	for i, exp := range expParams {
+		param := function.Parameters[i]
+		testIdentifier(t, param.Name, exp.name)
+		if exp.typ == "" {
+			if param.Type != nil {
+				t.Errorf("param.Type: expected=nil. got=%s", param.Type)
+			}
+			continue
+		}
+		if param.Type.String() != exp.typ {
+			t.Errorf("param.Type: expected=%s. got=%s", exp.typ, param.Type)
+		}
+	}
+
+	if len(function.Body.Statements) != 1 {
+		t.Fatalf("function.Body.Statements: expected=1. got=%d", len(function.Body.Statements))
+	}
+	ret := function.Body.Statements[0].(*ast.ReturnStatement)
+	testInfixExpression(t, ret.ReturnValue, "x", "+", "y")
+}
+
+func TestCallExpressionParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"add(1, 2 * 3, 4 + 5);", "add(1, (2 * 3), (4 + 5))"},
+		{"a + add(b * c) + d", "((a + add((b * c))) + d)"},
+		{"add(a, b, 1, 2 * 3, 4 + 5, add(6, 7 * 8))", "add(a, b, 1, (2 * 3), (4 + 5), add(6, (7 * 8)))"},
+		{"-f()", "(-f())"},
+		{"f()(1)", "f()(1)"},
+		{"fn(x) { x }(5)", "fn(x) { x }(5)"},
+		{"fn(int x) int { return x; }(5)", "fn(int x) int { return x; }(5)"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input, "calltest")
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+}
+
+func TestParsingArrayLiterals(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	l := lexer.New(input, "arraytest")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T", program.Statements[0])
+	}
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+
+	if end := array.End().Offset; end != len(input) {
+		t.Errorf("array.End().Offset: expected=%d. got=%d", len(input), end)
+	}
+}
+
+func TestParsingIndexExpressions(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	l := lexer.New(input, "indextest")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt, _ := program.Statements[0].(*ast.ExpressionStatement)
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	}
+
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+	if !testInfixExpression(t, indexExp.Index, 1, "+", 1) {
+		return
+	}
+}
+
+func TestParsingSliceExpressions(t *testing.T) {
+	tests := []struct {
+		input string
+		low   interface{}
+		high  interface{}
+	}{
+		{"a[1:2]", 1, 2},
+		{"a[1:]", 1, nil},
+		{"a[:2]", nil, 2},
+		{"a[:]", nil, nil},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input, "slicetest")
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt, _ := program.Statements[0].(*ast.ExpressionStatement)
+		slice, ok := stmt.Expression.(*ast.SliceExpression)
+		if !ok {
+			t.Fatalf("%q: exp not *ast.SliceExpression. got=%T", tt.input, stmt.Expression)
+		}
+
+		testIdentifier(t, slice.Left, "a")
+		for _, bound := range []struct {
+			exp      ast.Expression
+			expected interface{}
+		}{{slice.Low, tt.low}, {slice.High, tt.high}} {
+			if bound.expected == nil {
+				if bound.exp != nil {
+					t.Errorf("%q: expected a missing bound. got=%s", tt.input, bound.exp)
+				}
+				continue
+			}
+			testLiteralExpression(t, bound.exp, bound.expected)
+		}
+	}
+}
+
+func TestParsingHashLiterals(t *testing.T) {
+	input := `{"one": 1, "two": 10 - 8, true: three}`
+
+	l := lexer.New(input, "hashtest")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 3 {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+
+	keys := []string{`"one"`, `"two"`, "true"}
+	for i, pair := range hash.Pairs {
+		if pair.Key.String() != keys[i] {
+			t.Errorf("hash.Pairs[%d].Key: expected=%s. got=%s", i, keys[i], pair.Key)
+		}
+	}
+	testIntegerLiteral(t, hash.Pairs[0].Value, 1)
+	testInfixExpression(t, hash.Pairs[1].Value, 10, "-", 8)
+	testIdentifier(t, hash.Pairs[2].Value, "three")
+}
+
+func TestParsingEmptyHashLiteral(t *testing.T) {
+	l := lexer.New("{}", "hashtest")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	if len(hash.Pairs) != 0 {
+		t.Errorf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+}
+
+func TestBlockRecovery(t *testing.T) {
+	input := `let f = fn(int x) {
+    let = 1;
+    return x;
+};
+let g = fn() { return (1; };
+let ok = 1;`
+
+	l := lexer.New(input, "blocktest")
+	p := New(l)
+	program := p.ParseProgram()
+
+	if len(p.Errors()) != 2 {
+		t.Fatalf("p.Errors(): expected 2 errors. got=%d (%v)", len(p.Errors()), p.Errors())
+	}
+	if len(program.Statements) != 3 {
+		t.Fatalf("program.Statements: expected=3. got=%d (%s)", len(program.Statements), program)
+	}
+}
+
+func TestRecoverySkipsOpenedBraces(t *testing.T) {
+	tests := []string{
+		"let f = fn( { let x = 1; }; let y = 2;",
+		"let f = fn(int x { let z = x; }; let y = 2;",
+		"if x { let a = ; let b = 1; } let y = 2;",
+	}
+
+	for _, input := range tests {
+		p := New(lexer.New(input, "bracetest"))
+		program := p.ParseProgram()
+
+		if len(p.Errors()) != 1 {
+			t.Errorf("%q: expected 1 error. got=%d (%v)", input, len(p.Errors()), p.Errors())
+			continue
+		}
+		last := program.Statements[len(program.Statements)-1]
+		if last.String() != "let y = 2;" {
+			t.Errorf("%q: expected the last statement to be %q. got=%q", input, "let y = 2;", last.String())
+		}
+	}
+}
+
+func TestInvalidAssignmentTarget(t *testing.T) {
+	p := New(lexer.New("a + b = c;", "assigntest"))
+	p.ParseProgram()
+
+	errs := p.Errors()
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error. got=%v", errs)
+	}
+	expected := "assigntest:1:1: error[P0004]: cannot assign to (a + b)"
+	if errs[0].Error() != expected {
+		t.Errorf("expected=%q. got=%q", expected, errs[0].Error())
+	}
+}
+
+func TestInvalidPostfixTarget(t *testing.T) {
+	p := New(lexer.New("f()++;", "postfixtest"))
+	p.ParseProgram()
+
+	errs := p.Errors()
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error. got=%v", errs)
+	}
+	expected := "postfixtest:1:1: error[P0004]: cannot assign to f()"
+	if errs[0].Error() != expected {
+		t.Errorf("expected=%q. got=%q", expected, errs[0].Error())
+	}
+}
+
+func TestStringRoundTrip(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 5;", "let x = 5;"},
+		{"int x = 0x1F; bool b = !true; let s = `raw`;", "int x = 0x1F; bool b = (!true); let s = `raw`;"},
+		{"a + b * c; -d", "(a + (b * c)); (-d)"},
+		{"x = y = 1_000", "x = y = 1_000"},
+		{"null ?? 1.5e3", "(null ?? 1.5e3)"},
+		{`"tab\t ${name}! \${not}"`, `"tab\t ${name}! \${not}"`},
+		{"if x < y { x } else { y }", "if (x < y) { x } else { y }"},
+		{"if a { 1 } else if b { 2 } else { 3 }", "if a { 1 } else if b { 2 } else { 3 }"},
+		{"if a { 1 } else { if b { 2 } }", "if a { 1 } else { if b { 2 } }"},
+		{"if a { let x = 1; x; y } 2", "if a { let x = 1; x; y }; 2"},
+		{"let f = fn(int a, b) int { return a; };", "let f = fn(int a, b) int { return a; };"},
+		{"fn() { return; }", "fn() { return; }"},
+		{"fn() {}", "fn() { }"},
+		{`string s = "hi"; ?int n = null;`, `string s = "hi"; ?int n = null;`},
+		{"Map<K, List<V>> m = f(); fn(int) !T g = h;", "Map<K, List<V>> m = f(); fn(int) !T g = h;"},
+		{"let f = fn(fn(int) bool p, ?T x) fn() T { p };", "let f = fn(fn(int) bool p, ?T x) fn() T { p };"},
+		{"add(1, f(2))(3)", "add(1, f(2))(3)"},
+		{"fn(x) { x }(5)", "fn(x) { x }(5)"},
+		{"[1, [2]][0][1:]", "(([1, [2]][0])[1:])"},
+		{`{"a": 1, 2: [3]}["a"]; {}`, `({"a": 1, 2: [3]}["a"]); {}`},
+		{"a[:]; a[:n - 1]", "(a[:]); (a[:(n - 1)])"},
+		{"x++; -y--", "(x++); (-(y--))"},
+	}
+
+	for _, tt := range tests {
+		program := parseRoundTrip(t, tt.input)
+		if program == nil {
+			continue
+		}
+		printed := program.String()
+		if printed != tt.expected {
+			t.Errorf("%q: expected=%q. got=%q", tt.input, tt.expected, printed)
+		}
+
+		reparsed := parseRoundTrip(t, printed)
+		if reparsed == nil {
+			continue
+		}
+		if reparsed.String() != printed {
+			t.Errorf("%q: printed form does not round-trip. first=%q, second=%q", tt.input, printed, reparsed.String())
+		}
+	}
+}
+
+func parseRoundTrip(t *testing.T, input string) *ast.Program {
+	t.Helper()
+
+	l := lexer.New(input, "roundtrip")
+	p := New(l)
+	program := p.ParseProgram()
+	if errs := append(l.Errors, p.Errors()...); len(errs) > 0 {
+		t.Errorf("%q: parse errors: %v", input, errs)
+		return nil
+	}
+	return program
+}
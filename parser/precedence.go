@@ -0,0 +1,108 @@
+package parser
+
+import "chimp/token"
+
+// Operator precedence, from loosest to tightest binding. Every binary
+// operator is left-associative except '=', '**' and '??', which group to
+// the right:
+//
+//	LOWEST
+//	ASSIGN       =               right
+//	COALESCE     ??              right
+//	BOOLOR       ||              left
+//	BOOLXOR      ^^              left
+//	BOOLAND      &&              left
+//	EQUALS       == !=           left
+//	LESSGREATER  < <= > >=       left
+//	BITOR        |               left
+//	BITXOR       ^               left
+//	BITAND       &               left
+//	SHIFT        << >>           left
+//	SUM          + -             left
+//	PRODUCT      * /             left
+//	PREFIX       -x !x ~x
+//	POWER        **              right
+//	POSTFIX
+//	CALL         f(x)
+//	INDEX        a[i] a[i:j]
+//
+// POWER binds tighter than the prefix operators, so -2 ** 2 is -(2 ** 2).
+const (
+	_ int = iota
+	LOWEST
+	ASSIGN
+	COALESCE
+	BOOLOR
+	BOOLXOR
+	BOOLAND
+	EQUALS
+	LESSGREATER
+	BITOR
+	BITXOR
+	BITAND
+	SHIFT
+	SUM
+	PRODUCT
+	PREFIX
+	POWER
+	POSTFIX
+	CALL
+	INDEX
+)
+
+var precedences = map[token.TokenType]int{
+	token.ASSIGN:     ASSIGN,
+	token.COALESCE:   COALESCE,
+	token.BOOLOR:     BOOLOR,
+	token.BOOLXOR:    BOOLXOR,
+	token.BOOLAND:    BOOLAND,
+	token.EQ:         EQUALS,
+	token.NOTEQ:      EQUALS,
+	token.LT:         LESSGREATER,
+	token.LTEQ:       LESSGREATER,
+	token.GT:         LESSGREATER,
+	token.GTEQ:       LESSGREATER,
+	token.BITOR:      BITOR,
+	token.BITXOR:     BITXOR,
+	token.BITAND:     BITAND,
+	token.LBITSHIFT:  SHIFT,
+	token.RBITSHIFT:  SHIFT,
+	token.PLUS:       SUM,
+	token.MINUS:      SUM,
+	token.STAR:       PRODUCT,
+	token.SLASH:      PRODUCT,
+	token.DOUBLESTAR: POWER,
+	token.LPAREN:     CALL,
+	token.LBRACKET:   INDEX,
+}
+
+var rightAssoc = map[token.TokenType]bool{
+	token.ASSIGN:     true,
+	token.DOUBLESTAR: true,
+	token.COALESCE:   true,
+}
+
+// Precedence returns the precedence of the binary operator t, or LOWEST if
+// t is not one, and whether the operator groups to the right.
+func Precedence(t token.TokenType) (prec int, right bool) {
+	if prec, ok := precedences[t]; ok {
+		return prec, rightAssoc[t]
+	}
+	return LOWEST, false
+}
+
+func (p *Parser) peekPrecedence() int {
+	if prec, ok := precedences[p.peekToken.Type]; ok {
+		return prec
+	}
+
+	return LOWEST
+}
+
+func (p *Parser) curPrecedence() int {
+	if prec, ok := precedences[p.curToken.Type]; ok {
+		return prec
+	}
+
+	return LOWEST
+}
@@ -0,0 +1,109 @@
+package parser
+
+import (
+	"chimp/ast"
+	"chimp/diag"
+	"chimp/token"
+	"reflect"
+)
+
+// errorf records a diagnostic and puts the parser in panic mode. While
+// panicking, further errors are assumed to be fallout from the first one
+// and are dropped until synchronize finds the end of the statement.
+func (p *Parser) errorf(code diag.Code, span diag.Span, format string, args ...interface{}) *diag.Diagnostic {
+	if p.panicking || p.speculating > 0 {
+		p.panicking = true
+		return &diag.Diagnostic{}
+	}
+	p.panicking = true
+
+	return p.errors.Add(code, span, format, args...)
+}
+
+// syncKeywords are the tokens that can only begin a statement, so a
+// statement that has gone wrong certainly ends before them.
+var syncKeywords = map[token.TokenType]bool{
+	token.LET:       true,
+	token.INT_KW:    true,
+	token.BOOL_KW:   true,
+	token.STRING_KW: true,
+	token.RETURN:    true,
+	token.PACKAGE:   true,
+	token.IMPORT:    true,
+	token.CLASS:     true,
+	token.TYPE:      true,
+	token.ENUM:      true,
+	token.UNION:     true,
+}
+
+// synchronize skips the rest of a statement that failed to parse and
+// leaves panic mode. It stops with curToken on the statement's ';', or
+// just before a '}' closing the enclosing block, a keyword that starts
+// the next statement, or EOF. Braces the statement opened, before or
+// after the error, are skipped as a whole. start is the value braces had
+// when the statement began.
+func (p *Parser) synchronize(start int) {
+	depth := p.braces - start
+	switch p.curToken.Type {
+	case token.LBRACE:
+		depth++
+	case token.RBRACE:
+		depth--
+	}
+	if depth < 0 {
+		depth = 0
+	}
+
+	for !p.curTokenIs(token.EOF) {
+		if depth == 0 {
+			if p.curTokenIs(token.SEMICOLON) || p.peekTokenIs(token.RBRACE) || syncKeywords[p.peekToken.Type] {
+				break
+			}
+		}
+		if p.peekTokenIs(token.EOF) {
+			break
+		}
+
+		switch p.peekToken.Type {
+		case token.LBRACE:
+			depth++
+		case token.RBRACE:
+			depth--
+		}
+		p.nextToken()
+	}
+
+	p.panicking = false
+}
+
+// skipBraces skips to the '}' closing a brace that is already open, so
+// that synchronize does not take it for the end of an enclosing block. It
+// stops early, before a keyword that starts a statement, if the brace is
+// never closed.
+func (p *Parser) skipBraces() {
+	depth := 1
+
+	for !p.peekTokenIs(token.EOF) {
+		switch {
+		case p.peekTokenIs(token.LBRACE):
+			depth++
+		case p.peekTokenIs(token.RBRACE):
+			depth--
+		case depth == 1 && syncKeywords[p.peekToken.Type]:
+			return
+		}
+		p.nextToken()
+		if depth == 0 {
+			return
+		}
+	}
+}
+
+// nilIfFailed converts a nil *ast.XStatement into a nil ast.Statement,
+// rather than an interface holding a nil pointer.
+func nilIfFailed(stmt ast.Statement) ast.Statement {
+	if stmt == nil || reflect.ValueOf(stmt).IsNil() {
+		return nil
+	}
+	return stmt
+}
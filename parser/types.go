@@ -0,0 +1,185 @@
+package parser
+
+import (
+	"chimp/ast"
+	"chimp/diag"
+	"chimp/token"
+)
+
+// typeStart lists the tokens a type expression can begin with.
+var typeStart = map[token.TokenType]bool{
+	token.IDENT:     true,
+	token.INT_KW:    true,
+	token.BOOL_KW:   true,
+	token.STRING_KW: true,
+	token.QUESTION:  true,
+	token.BANG:      true,
+	token.FUNCTION:  true,
+}
+
+// parseType parses the type expression starting at curToken, leaving
+// curToken on its last token. named says whether a name follows the type,
+// as in a declaration or a parameter, which decides whether the identifier
+// after a function type is its result or that name.
+func (p *Parser) parseType(named bool) ast.TypeExpr {
+	t := p.parseTypeExpr(named)
+	if t != nil && p.owedGT {
+		p.owedGT = false
+		p.errorf(diag.UnexpectedToken, diag.TokenSpan(p.curToken),
+			"unexpected '>' after type %s", t)
+		return nil
+	}
+	return t
+}
+
+func (p *Parser) parseTypeExpr(named bool) ast.TypeExpr {
+	switch p.curToken.Type {
+	case token.QUESTION:
+		t := &ast.NullableType{Token: p.curToken}
+		p.nextToken()
+		if t.Elem = p.parseTypeExpr(named); t.Elem == nil {
+			return nil
+		}
+		return t
+	case token.BANG:
+		t := &ast.ErrorUnionType{Token: p.curToken}
+		p.nextToken()
+		if t.Value = p.parseTypeExpr(named); t.Value == nil {
+			return nil
+		}
+		return t
+	case token.FUNCTION:
+		return p.parseFunctionType(named)
+	case token.IDENT, token.INT_KW, token.BOOL_KW, token.STRING_KW:
+		var t ast.TypeExpr = &ast.NamedType{Token: p.curToken, Name: p.curToken.Literal}
+		if p.peekTokenIs(token.LT) {
+			p.nextToken()
+			if t = p.parseGenericType(t.(*ast.NamedType)); t == nil {
+				return nil
+			}
+		}
+		if p.peekTokenIs(token.BANG) && !p.owedGT {
+			p.nextToken()
+			eu := &ast.ErrorUnionType{Error: t, Token: p.curToken}
+			p.nextToken()
+			if eu.Value = p.parseTypeExpr(named); eu.Value == nil {
+				return nil
+			}
+			return eu
+		}
+		return t
+	}
+
+	p.errorf(diag.UnexpectedToken, diag.TokenSpan(p.curToken),
+		"expected a type, got '%s' instead", p.curToken.Type)
+	return nil
+}
+
+// parseGenericType parses the type arguments of base, with curToken on the
+// '<'. A '>>' closes two generic types: the first '>' closes this one and
+// the second is owed to the enclosing one.
+func (p *Parser) parseGenericType(base *ast.NamedType) ast.TypeExpr {
+	t := &ast.GenericType{Base: base}
+
+	for {
+		p.nextToken()
+		arg := p.parseTypeExpr(false)
+		if arg == nil {
+			return nil
+		}
+		t.Args = append(t.Args, arg)
+
+		if p.owedGT {
+			p.owedGT = false
+			t.Rangle = halfShift(p.curToken)
+			t.Rangle.Pos.Column++
+			t.Rangle.Pos.Offset++
+			return t
+		}
+		if !p.peekTokenIs(token.COMMA) {
+			break
+		}
+		p.nextToken()
+	}
+
+	switch {
+	case p.peekTokenIs(token.GT):
+		p.nextToken()
+		t.Rangle = p.curToken
+	case p.peekTokenIs(token.RBITSHIFT):
+		p.nextToken()
+		t.Rangle = halfShift(p.curToken)
+		t.Rangle.End.Column--
+		t.Rangle.End.Offset--
+		p.owedGT = true
+	default:
+		p.peekError(token.GT)
+		return nil
+	}
+
+	return t
+}
+
+// halfShift turns a '>>' token into a '>' spanning it; callers narrow the
+// span to the half they mean.
+func halfShift(tok token.Token) token.Token {
+	tok.Type = token.GT
+	tok.Literal = ">"
+	return tok
+}
+
+// parseFunctionType parses fn(T, U) R with curToken on 'fn'.
+func (p *Parser) parseFunctionType(named bool) ast.TypeExpr {
+	t := &ast.FunctionType{Token: p.curToken, Params: []ast.TypeExpr{}}
+
+	if !p.expPeek(token.LPAREN) {
+		return nil
+	}
+
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+	} else {
+		for {
+			p.nextToken()
+			param := p.parseType(false)
+			if param == nil {
+				return nil
+			}
+			t.Params = append(t.Params, param)
+
+			if !p.peekTokenIs(token.COMMA) {
+				break
+			}
+			p.nextToken()
+		}
+		if !p.expPeek(token.RPAREN) {
+			return nil
+		}
+	}
+	t.Rparen = p.curToken
+
+	if p.hasResultType(named) {
+		p.nextToken()
+		if t.Result = p.parseTypeExpr(named); t.Result == nil {
+			return nil
+		}
+	}
+
+	return t
+}
+
+// hasResultType reports whether peekToken begins the result type of a
+// function type. When a name follows the type, an identifier followed by
+// '=', ',' or ')' is that name rather than the result.
+func (p *Parser) hasResultType(named bool) bool {
+	if !typeStart[p.peekToken.Type] {
+		return false
+	}
+	if named && p.peekTokenIs(token.IDENT) {
+		switch p.lookahead(1).Type {
+		case token.ASSIGN, token.COMMA, token.RPAREN:
+			return false
+		}
+	}
+	return true
+}
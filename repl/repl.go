@@ -1,16 +1,54 @@
 package repl
 
 import (
+	"chimp/compiler"
+	"chimp/diag"
+	"chimp/evaluator"
 	"chimp/lexer"
-	"chimp/token"
+	"chimp/object"
+	"chimp/parser"
+	"chimp/types"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
 
 	"github.com/chzyer/readline"
 )
 
 const PROMPT = ">> "
 
-func Start() {
+// session is the state a REPL keeps between lines. Every line is also
+// compiled, so that :disasm knows the globals defined so far.
+type session struct {
+	color   bool
+	env     *object.Environment
+	checker *types.Checker
+
+	symbols   *compiler.SymbolTable
+	constants []object.Object
+	disasm    bool
+}
+
+// command is a REPL command. usage shows the argument it takes, if any;
+// one that takes none rejects an argument.
+type command struct {
+	usage string
+	help  string
+	run   func(s *session, arg string)
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		":builtins": {"", "list the builtin functions and their signatures", (*session).builtins},
+		":disasm":   {"[code]", "toggle printing the bytecode of each line, or run code with it", (*session).toggleDisasm},
+		":help":     {"", "list the REPL commands", (*session).help},
+	}
+}
+
+func Start(color bool) {
 	rl, err := readline.NewEx(&readline.Config{
 		Prompt:            PROMPT,
 		InterruptPrompt:   "^C",
@@ -22,23 +60,120 @@ func Start() {
 	}
 	defer rl.Close()
 
+	s := &session{
+		color:   color,
+		env:     object.NewEnvironment(),
+		checker: types.NewChecker(),
+		symbols: compiler.NewSymbolTable(),
+	}
+	for i, b := range object.Builtins {
+		s.symbols.DefineBuiltin(i, b.Name)
+	}
+
 	for {
 		input, err := rl.Readline()
 		if err != nil {
 			fmt.Println("Keyboard Interrupt")
 			return
 		}
-		if len(input) == 0 {
-			input = string(rune(0))
+
+		if strings.HasPrefix(input, ":") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(input), " ")
+			cmd, ok := commands[name]
+			if !ok {
+				fmt.Printf("unknown command %s; try :help\n", name)
+				continue
+			}
+			arg = strings.TrimSpace(arg)
+			if arg != "" && cmd.usage == "" {
+				fmt.Printf("%s takes no argument\n", name)
+				continue
+			}
+			cmd.run(s, arg)
+			continue
 		}
-		l := lexer.New(input, "stdin")
-		var tok token.Token
-		for tok.Type != token.EOF {
-			tok = l.NextToken()
-			fmt.Printf("Token%+v\n", tok)
+
+		s.eval(input)
+	}
+}
+
+func (s *session) eval(input string) {
+	l := lexer.New(input, "stdin")
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	diags := append(l.Errors, p.Errors()...)
+	if !diags.HasErrors() {
+		diags = append(diags, s.checker.Check(program)...)
+	}
+	if len(diags) > 0 {
+		r := diag.NewRenderer(os.Stdout, s.color)
+		r.AddSource("stdin", input)
+		r.RenderAll(diags)
+		return
+	}
+
+	comp := compiler.NewWithState(s.symbols, s.constants)
+	if err := comp.Compile(program); err == nil {
+		bytecode := comp.Bytecode()
+		s.constants = bytecode.Constants
+		if s.disasm {
+			compiler.Disassemble(os.Stdout, bytecode, input)
 		}
-		for _, err := range l.Errors {
-			fmt.Println(err)
+	} else if s.disasm {
+		fmt.Printf("compile error: %s\n", err)
+	}
+
+	evaluated := evaluator.Eval(program, s.env)
+	if evaluated != nil {
+		fmt.Println(evaluated.Inspect())
+	}
+	if err, ok := evaluated.(*object.Error); ok {
+		fmt.Print(object.FormatStackTrace(err.Trace))
+	}
+}
+
+// toggleDisasm turns disassembly on or off. Given code, it instead runs
+// the code with disassembly on for that line alone.
+func (s *session) toggleDisasm(code string) {
+	if code != "" {
+		on := s.disasm
+		s.disasm = true
+		s.eval(code)
+		s.disasm = on
+		return
+	}
+
+	s.disasm = !s.disasm
+	if s.disasm {
+		fmt.Println("disassembly on")
+	} else {
+		fmt.Println("disassembly off")
+	}
+}
+
+func (s *session) help(string) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		cmd := commands[name]
+		fmt.Printf("%-16s %s\n", strings.TrimSpace(name+" "+cmd.usage), cmd.help)
+	}
+}
+
+// builtins lists each builtin with its signatures, one per line.
+func (s *session) builtins(string) {
+	for _, b := range types.Builtins {
+		for i, sig := range b.Overloads {
+			name := b.Name
+			if i > 0 {
+				name = ""
+			}
+			fmt.Printf("%-10s %s\n", name, sig)
 		}
 	}
 }
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"chimp/ast"
+	"chimp/compiler"
+	"chimp/evaluator"
+	"chimp/format"
+	"chimp/lexer"
+	"chimp/object"
+	"chimp/parser"
+	"chimp/types"
+	"chimp/vm"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// check parses and type-checks a program, rendering any diagnostics to
+// stderr. It returns nil if the program has errors.
+func check(input string, filename string, color bool) *ast.Program {
+	return checkSource(lexer.New(input, filename), func() string { return input }, color)
+}
+
+// checkSource is check for a program read by l. source is only called if
+// there are diagnostics to show, to get the text to show them in.
+func checkSource(l *lexer.Lexer, source func() string, color bool) *ast.Program {
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if err := l.Err(); err != nil {
+		fmt.Printf("CLI: %s\n", err)
+		return nil
+	}
+
+	diags := append(l.Errors, p.Errors()...)
+	if !diags.HasErrors() {
+		diags = append(diags, types.Check(program)...)
+	}
+	if len(diags) > 0 {
+		renderDiags(source(), l.Filename, color, diags)
+		if diags.HasErrors() {
+			return nil
+		}
+	}
+
+	return program
+}
+
+// run checks and runs the program read by l on the given engine, "eval"
+// or "vm". source is as for checkSource.
+func run(l *lexer.Lexer, source func() string, color bool, engine string) int {
+	program := checkSource(l, source, color)
+	if program == nil {
+		if l.Err() != nil {
+			return exitIOErr
+		}
+		return exitDataErr
+	}
+
+	if engine == "vm" {
+		bytecode, status := compile(program)
+		if bytecode == nil {
+			return status
+		}
+		return runBytecode(bytecode)
+	}
+
+	result := evaluator.Eval(program, object.NewEnvironment())
+	if err, ok := result.(*object.Error); ok {
+		fmt.Fprintln(os.Stderr, err.Inspect())
+		fmt.Fprint(os.Stderr, object.FormatStackTrace(err.Trace))
+		return exitSoftware
+	}
+
+	return 0
+}
+
+func compile(program *ast.Program) (*compiler.Bytecode, int) {
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		fmt.Fprintf(os.Stderr, "compile error: %s\n", err)
+		return nil, exitSoftware
+	}
+	return comp.Bytecode(), 0
+}
+
+func runBytecode(bytecode *compiler.Bytecode) int {
+	machine := vm.New(bytecode)
+	if err := machine.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if rerr, ok := err.(*vm.RuntimeError); ok {
+			fmt.Fprint(os.Stderr, object.FormatStackTrace(rerr.Trace))
+		}
+		return exitSoftware
+	}
+
+	return 0
+}
+
+// build compiles the source file filename into the object file out.
+func build(input []byte, filename string, out string, color bool) int {
+	program := check(string(input), filename, color)
+	if program == nil {
+		return exitDataErr
+	}
+	bytecode, status := compile(program)
+	if bytecode == nil {
+		return status
+	}
+
+	sourcePath, err := relativeTo(out, filename)
+	if err != nil {
+		fmt.Printf("CLI: %s\n", err)
+		return exitIOErr
+	}
+
+	var buf bytes.Buffer
+	err = compiler.WriteObjectFile(&buf, &compiler.ObjectFile{
+		Filename:   filename,
+		SourcePath: sourcePath,
+		SourceHash: compiler.HashSource(input),
+		Bytecode:   bytecode,
+	})
+	if err == nil {
+		err = os.WriteFile(out, buf.Bytes(), 0644)
+	}
+	if err != nil {
+		fmt.Printf("CLI: %s\n", err)
+		return exitIOErr
+	}
+
+	return 0
+}
+
+// runObjectFile runs a program built by build. If its source is still
+// next to it, the source must not have changed since.
+func runObjectFile(data []byte, path string) int {
+	f, err := compiler.ReadObjectFile(bytes.NewReader(data))
+	if err != nil {
+		fmt.Printf("CLI: %s: %s\n", path, err)
+		return exitDataErr
+	}
+
+	source := filepath.Join(filepath.Dir(path), f.SourcePath)
+	if src, err := os.ReadFile(source); err == nil && compiler.HashSource(src) != f.SourceHash {
+		fmt.Printf("CLI: %s is stale: %s has changed since it was built; rebuild it with 'chimp build'\n",
+			path, source)
+		return exitDataErr
+	}
+
+	return runBytecode(f.Bytecode)
+}
+
+// relativeTo returns the path of target relative to the directory of the
+// file from.
+func relativeTo(from, target string) (string, error) {
+	dir, err := filepath.Abs(filepath.Dir(from))
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(target)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Rel(dir, abs)
+}
+
+// disasm prints the bytecode of a source or object file. The listing of
+// an object file shows source lines if its source is still beside it.
+func disasm(contents []byte, filename string, color bool) int {
+	if compiler.IsObjectFile(contents) {
+		f, err := compiler.ReadObjectFile(bytes.NewReader(contents))
+		if err != nil {
+			fmt.Printf("CLI: %s: %s\n", filename, err)
+			return exitDataErr
+		}
+
+		source := ""
+		src, err := os.ReadFile(filepath.Join(filepath.Dir(filename), f.SourcePath))
+		if err == nil && compiler.HashSource(src) == f.SourceHash {
+			source = string(src)
+		}
+
+		compiler.Disassemble(os.Stdout, f.Bytecode, source)
+		return 0
+	}
+
+	program := check(string(contents), filename, color)
+	if program == nil {
+		return exitDataErr
+	}
+	bytecode, status := compile(program)
+	if bytecode == nil {
+		return status
+	}
+
+	compiler.Disassemble(os.Stdout, bytecode, string(contents))
+	return 0
+}
+
+// formatFile formats a file and prints the result, or a diff of the
+// changes if diff is set. If write is set it rewrites the file instead of
+// printing the result.
+func formatFile(filename string, write, diff, color bool) int {
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		fmt.Printf("CLI: %s\n", err)
+		return exitIOErr
+	}
+
+	out, diags := format.Source(src, filename)
+	if diags != nil {
+		renderDiags(string(src), filename, color, diags)
+		return exitDataErr
+	}
+
+	if diff {
+		fmt.Print(unifiedDiff(filename, src, out))
+	}
+	if write && !bytes.Equal(src, out) {
+		info, err := os.Stat(filename)
+		if err == nil {
+			err = os.WriteFile(filename, out, info.Mode().Perm())
+		}
+		if err != nil {
+			fmt.Printf("CLI: %s\n", err)
+			return exitIOErr
+		}
+	}
+	if !write && !diff {
+		os.Stdout.Write(out)
+	}
+
+	return 0
+}
@@ -0,0 +1,39 @@
+package token
+
+import "fmt"
+
+// Position is a location in a source file. Line and Column are 1-based;
+// Offset is the 0-based byte offset from the start of the file. Column
+// counts characters, so a multi-byte character advances it by one; so
+// does each byte of invalid UTF-8.
+//
+// In JSON a position leaves out its filename, which is given once for the
+// whole file instead.
+type Position struct {
+	Filename string `json:"-"`
+	Offset   int    `json:"offset"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+}
+
+// IsValid reports whether the position has been set.
+func (pos Position) IsValid() bool {
+	return pos.Line > 0
+}
+
+// String returns the position as "file:line:col", dropping whichever
+// parts are unknown.
+func (pos Position) String() string {
+	s := pos.Filename
+	if pos.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+
+	return s
+}
@@ -3,18 +3,35 @@ package token
 type TokenType string
 
 type Token struct {
-	Type    TokenType
-	Literal string
+	Type    TokenType `json:"type"`
+	Literal string    `json:"literal"`
+	Pos     Position  `json:"pos"` // position of the first character of the token
+	End     Position  `json:"end"` // position immediately after the token
 }
 
 const (
 	ILLEGAL TokenType = "ILLEGAL"
 	EOF               = "EOF"
 
+	// COMMENT is only returned by a lexer in comment-scanning mode. Its
+	// literal is the whole comment, including the // or /* */.
+	COMMENT = "COMMENT"
+
 	// Identifiers + Literals
-	IDENT  = "IDENT"
-	INT    = "INT"
-	STRING = "STRING"
+	IDENT      = "IDENT"
+	INT        = "INT"
+	HEX_INT    = "HEX_INT"
+	OCT_INT    = "OCT_INT"
+	BIN_INT    = "BIN_INT"
+	FLOAT      = "FLOAT"
+	STRING     = "STRING"
+	RAW_STRING = "RAW_STRING"
+
+	// Interpolated strings are split around each ${...}: "a${x}b${y}c" is
+	// STRING_HEAD("a") x STRING_MID("b") y STRING_TAIL("c")
+	STRING_HEAD = "STRING_HEAD"
+	STRING_MID  = "STRING_MID"
+	STRING_TAIL = "STRING_TAIL"
 
 	// Operators
 	ASSIGN     = "="
@@ -47,14 +64,35 @@ const (
 	QUESTION = "?"
 	COALESCE = "??"
 
+	ARROW     = "->"
+	FAT_ARROW = "=>"
+	INCREMENT = "++"
+	DECREMENT = "--"
+
+	// Compound assignment
+	PLUS_ASSIGN       = "+="
+	MINUS_ASSIGN      = "-="
+	STAR_ASSIGN       = "*="
+	SLASH_ASSIGN      = "/="
+	DOUBLESTAR_ASSIGN = "**="
+	BITAND_ASSIGN     = "&="
+	BITOR_ASSIGN      = "|="
+	BITXOR_ASSIGN     = "^="
+	LBITSHIFT_ASSIGN  = "<<="
+	RBITSHIFT_ASSIGN  = ">>="
+
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
+	DOT       = "."
 
-	LPAREN = "("
-	RPAREN = ")"
-	LBRACE = "{"
-	RBRACE = "}"
+	LPAREN   = "("
+	RPAREN   = ")"
+	LBRACE   = "{"
+	RBRACE   = "}"
+	LBRACKET = "["
+	RBRACKET = "]"
 
 	// Keywords
 	PACKAGE   = "PACKAGE"
@@ -0,0 +1,237 @@
+package types
+
+import (
+	"chimp/ast"
+	"chimp/diag"
+	"strconv"
+	"strings"
+)
+
+// TypeParam is a type variable in the signature of a builtin. Each call
+// binds it to the type of the argument in its place.
+type TypeParam struct {
+	Name string
+}
+
+func (tp *TypeParam) String() string { return tp.Name }
+
+// Builtin is the type of a builtin function. Some builtins accept
+// arguments of several types, so it has one signature for each.
+type Builtin struct {
+	Name      string
+	Overloads []*Signature
+}
+
+// String is the builtin's signature, if it has only one.
+func (b *Builtin) String() string {
+	if len(b.Overloads) == 1 {
+		return b.Overloads[0].String()
+	}
+	return "builtin " + b.Name
+}
+
+var (
+	tpT = &TypeParam{"T"}
+	tpK = &TypeParam{"K"}
+	tpV = &TypeParam{"V"}
+)
+
+// Builtins declares the signatures of the builtin functions of
+// object.Builtins, in the same order.
+var Builtins = []*Builtin{
+	{"len", []*Signature{
+		{Params: []Type{String}, Result: Int},
+		{Params: []Type{&Array{Elem: tpT}}, Result: Int},
+		{Params: []Type{&Map{Key: tpK, Value: tpV}}, Result: Int},
+	}},
+	{"puts", []*Signature{{Params: []Type{Any}, Variadic: true, Result: Null}}},
+	{"print", []*Signature{{Params: []Type{Any}, Variadic: true, Result: Null}}},
+	{"first", []*Signature{{Params: []Type{&Array{Elem: tpT}}, Result: &Nullable{Elem: tpT}}}},
+	{"last", []*Signature{{Params: []Type{&Array{Elem: tpT}}, Result: &Nullable{Elem: tpT}}}},
+	{"rest", []*Signature{{Params: []Type{&Array{Elem: tpT}}, Result: &Array{Elem: tpT}}}},
+	{"push", []*Signature{{Params: []Type{&Array{Elem: tpT}, tpT}, Result: &Array{Elem: tpT}}}},
+	{"type_of", []*Signature{{Params: []Type{Any}, Result: String}}},
+	{"to_string", []*Signature{{Params: []Type{Any}, Result: String}}},
+	{"parse_int", []*Signature{{Params: []Type{String}, Result: &Nullable{Elem: Int}}}},
+	{"assert", []*Signature{
+		{Params: []Type{Bool}, Result: Null},
+		{Params: []Type{Bool, String}, Result: Null},
+	}},
+}
+
+// builtinScope holds the builtins, around the scope of every program.
+var builtinScope = NewScope(nil)
+
+func init() {
+	for _, b := range Builtins {
+		builtinScope.Insert(b.Name, b)
+	}
+}
+
+// accepts reports whether sig takes n arguments.
+func accepts(sig *Signature, n int) bool {
+	if sig.Variadic {
+		return n >= len(sig.Params)-1
+	}
+	return n == len(sig.Params)
+}
+
+// param returns the type of the i'th parameter of sig.
+func param(sig *Signature, i int) Type {
+	if sig.Variadic && i >= len(sig.Params)-1 {
+		return sig.Params[len(sig.Params)-1]
+	}
+	return sig.Params[i]
+}
+
+// callBuiltin checks a call to a builtin against each of its signatures
+// that takes as many arguments, and returns the result type of the first
+// one the arguments fit.
+func (c *Checker) callBuiltin(e *ast.CallExpression, b *Builtin, args []Type) Type {
+	var candidates []*Signature
+	for _, sig := range b.Overloads {
+		if accepts(sig, len(args)) {
+			candidates = append(candidates, sig)
+		}
+	}
+
+	if len(candidates) == 0 {
+		c.errorf(diag.WrongArgCount, e, "wrong number of arguments in call to %s: want %s, got %d",
+			e.Function, arities(b), len(args))
+		return Invalid
+	}
+
+	for _, sig := range candidates {
+		bind := bindings{}
+		bad := -1
+		for i := range args {
+			if !bind.match(param(sig, i), args[i]) {
+				bad = i
+				break
+			}
+		}
+		if bad < 0 {
+			return bind.subst(sig.Result, Unknown)
+		}
+		if len(candidates) == 1 {
+			c.errorf(diag.TypeMismatch, e.Arguments[bad], "cannot use %s (type %s) as type %s in argument %d to %s",
+				e.Arguments[bad], args[bad], bind.subst(param(sig, bad), nil), bad+1, e.Function)
+			return Invalid
+		}
+	}
+
+	argTypes := []string{}
+	for _, t := range args {
+		argTypes = append(argTypes, t.String())
+	}
+	sigs := []string{}
+	for _, sig := range candidates {
+		sigs = append(sigs, sig.String())
+	}
+	c.errorf(diag.TypeMismatch, e, "cannot call %s with (%s): want %s",
+		e.Function, strings.Join(argTypes, ", "), strings.Join(sigs, " or "))
+	return Invalid
+}
+
+// arities describes the numbers of arguments b takes: "1", "1 or 2" or
+// "at least 1".
+func arities(b *Builtin) string {
+	counts := []string{}
+	for _, sig := range b.Overloads {
+		n := strconv.Itoa(len(sig.Params))
+		if sig.Variadic {
+			n = "at least " + strconv.Itoa(len(sig.Params)-1)
+		}
+		if len(counts) == 0 || counts[len(counts)-1] != n {
+			counts = append(counts, n)
+		}
+	}
+	return strings.Join(counts, " or ")
+}
+
+// bindings maps the type parameters of a signature to the types a call
+// binds them to.
+type bindings map[*TypeParam]Type
+
+// match reports whether an argument of type v fits the parameter type t,
+// binding the type parameters in t as it goes. A type parameter bound to
+// T and then matched against null becomes ?T.
+func (b bindings) match(t, v Type) bool {
+	if isLoose(v) || t == Any {
+		return true
+	}
+
+	switch t := t.(type) {
+	case *TypeParam:
+		bound, ok := b[t]
+		switch {
+		case !ok || isLoose(bound) || AssignableTo(bound, v) && bound != Null:
+			b[t] = v
+		case AssignableTo(v, bound):
+		case v == Null:
+			b[t] = nullable(bound)
+		case bound == Null:
+			b[t] = nullable(v)
+		default:
+			return false
+		}
+		return true
+	case *Array:
+		av, ok := v.(*Array)
+		return ok && b.match(t.Elem, av.Elem)
+	case *Map:
+		mv, ok := v.(*Map)
+		return ok && b.match(t.Key, mv.Key) && b.match(t.Value, mv.Value)
+	case *Nullable:
+		if v == Null {
+			return true
+		}
+		if nv, ok := v.(*Nullable); ok {
+			return b.match(t.Elem, nv.Elem)
+		}
+		return b.match(t.Elem, v)
+	}
+
+	return AssignableTo(v, t)
+}
+
+// subst replaces the type parameters in t with the types they are bound
+// to. Unbound ones are replaced with unbound, or kept if it is nil.
+func (b bindings) subst(t Type, unbound Type) Type {
+	switch t := t.(type) {
+	case *TypeParam:
+		if bound, ok := b[t]; ok {
+			return bound
+		}
+		if unbound != nil {
+			return unbound
+		}
+		return t
+	case *Array:
+		return &Array{Elem: b.subst(t.Elem, unbound)}
+	case *Map:
+		return &Map{Key: b.subst(t.Key, unbound), Value: b.subst(t.Value, unbound)}
+	case *Nullable:
+		return nullable(b.subst(t.Elem, unbound))
+	}
+	return t
+}
+
+// fits reports whether one of the signatures of b can stand in for a
+// function of type sig.
+func (b *Builtin) fits(sig *Signature) bool {
+	for _, o := range b.Overloads {
+		if o.Variadic || len(o.Params) != len(sig.Params) {
+			continue
+		}
+		bind := bindings{}
+		ok := true
+		for i, p := range sig.Params {
+			ok = ok && bind.match(o.Params[i], p)
+		}
+		if ok && AssignableTo(bind.subst(o.Result, Unknown), sig.Result) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,343 @@
+package types
+
+import (
+	"chimp/ast"
+	"chimp/diag"
+)
+
+// Checker type-checks programs. Top-level declarations are kept between
+// calls to Check, so a REPL can check its input one line at a time. A
+// program with errors declares nothing, as it will not be run.
+type Checker struct {
+	errors diag.List
+	scope  *Scope
+	fn     *funcContext
+	info   *Info
+
+	// headers holds the signature put in scope for each function literal
+	// before its body was checked, so that funcLit can fill in its result.
+	headers map[*ast.FunctionLiteral]*Signature
+}
+
+// funcContext tracks the function literal whose body is being checked.
+type funcContext struct {
+	result      Type        // the declared result type, or nil
+	firstReturn *returnInfo // the first return statement that fixes the result
+}
+
+type returnInfo struct {
+	stmt *ast.ReturnStatement
+	typ  Type
+}
+
+func NewChecker() *Checker {
+	return &Checker{
+		scope:   NewScope(builtinScope),
+		info:    newInfo(),
+		headers: map[*ast.FunctionLiteral]*Signature{},
+	}
+}
+
+// Info returns the types recorded by every call to Check so far.
+func (c *Checker) Info() *Info {
+	return c.info
+}
+
+// Check type-checks a whole program with a fresh checker.
+func Check(program *ast.Program) diag.List {
+	return NewChecker().Check(program)
+}
+
+// Check type-checks program and returns the errors it found.
+func (c *Checker) Check(program *ast.Program) diag.List {
+	c.errors = nil
+	c.openScope()
+	c.stmts(program.Statements)
+	declared := c.scope
+	c.closeScope()
+
+	if !c.errors.HasErrors() {
+		for name, t := range declared.names {
+			c.scope.Insert(name, t)
+		}
+	}
+	return c.errors
+}
+
+func (c *Checker) errorf(code diag.Code, node ast.Node, format string, args ...interface{}) *diag.Diagnostic {
+	return c.errors.Add(code, diag.SpanOf(node), format, args...)
+}
+
+func (c *Checker) stmt(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		c.declare(stmt.Name, nil, stmt.Value)
+	case *ast.VarDecl:
+		c.declare(stmt.Name, c.resolve(stmt.Type), stmt.Value)
+	case *ast.ReturnStatement:
+		c.returnStmt(stmt)
+	case *ast.ExpressionStatement:
+		if ie, ok := stmt.Expression.(*ast.IfExpression); ok {
+			c.info.Types[ie] = c.ifExpr(ie, false)
+			return
+		}
+		c.expr(stmt.Expression)
+	case *ast.BlockStatement:
+		c.openScope()
+		c.stmts(stmt.Statements)
+		c.closeScope()
+	}
+}
+
+func (c *Checker) stmts(list []ast.Statement) {
+	for i, stmt := range list {
+		if i == 0 || funcDecl(list[i-1]) == nil {
+			c.hoist(list[i:])
+		}
+		c.stmt(stmt)
+	}
+}
+
+// hoist declares the functions declared one after another at the start
+// of list before any of them is checked, so that they can call each
+// other.
+func (c *Checker) hoist(list []ast.Statement) {
+	for _, stmt := range list {
+		fl := funcDecl(stmt)
+		if fl == nil {
+			return
+		}
+		if vd, ok := stmt.(*ast.VarDecl); ok {
+			c.scope.Insert(vd.Name.Value, c.typeOf(vd.Type, false))
+		} else {
+			c.scope.Insert(stmt.(*ast.LetStatement).Name.Value, c.header(fl))
+		}
+	}
+}
+
+// funcDecl returns the function literal stmt declares a name for, or nil
+// if it does not declare a function.
+func funcDecl(stmt ast.Statement) *ast.FunctionLiteral {
+	var value ast.Expression
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		value = stmt.Value
+	case *ast.VarDecl:
+		value = stmt.Value
+	}
+	fl, _ := value.(*ast.FunctionLiteral)
+	return fl
+}
+
+func (c *Checker) openScope() {
+	c.scope = NewScope(c.scope)
+}
+
+func (c *Checker) closeScope() {
+	c.scope = c.scope.outer
+}
+
+// declare checks a declaration of name with the initializer value.
+// declared is the type written in the declaration, or nil for let, which
+// takes the type of its initializer.
+func (c *Checker) declare(name *ast.Identifier, declared Type, value ast.Expression) {
+	// A function may call itself, so its name is in scope in its body.
+	if fl, ok := value.(*ast.FunctionLiteral); ok {
+		if declared != nil {
+			c.scope.Insert(name.Value, declared)
+		} else {
+			c.scope.Insert(name.Value, c.header(fl))
+		}
+	}
+
+	vt := c.expr(value)
+
+	if declared == nil {
+		if vt == Null {
+			c.errorf(diag.CannotInfer, value, "cannot infer type of %s from null", name.Value)
+			vt = Invalid
+		} else if empty := emptyLiteral(value); empty != nil && hasUnknownElem(vt) {
+			c.errorf(diag.CannotInfer, empty, "cannot infer type of %s from empty literal %s", name.Value, empty)
+			vt = Invalid
+		}
+		c.bind(name, vt)
+		return
+	}
+
+	if !AssignableTo(vt, declared) {
+		c.errorf(diag.TypeMismatch, value, "cannot use %s (type %s) as type %s in declaration of %s",
+			value, vt, declared, name.Value)
+	}
+	c.bind(name, declared)
+}
+
+// emptyLiteral returns an empty array or map literal in e, or nil if there
+// is none.
+func emptyLiteral(e ast.Expression) ast.Expression {
+	switch e := e.(type) {
+	case *ast.ArrayLiteral:
+		if len(e.Elements) == 0 {
+			return e
+		}
+		for _, el := range e.Elements {
+			if empty := emptyLiteral(el); empty != nil {
+				return empty
+			}
+		}
+	case *ast.HashLiteral:
+		if len(e.Pairs) == 0 {
+			return e
+		}
+		for _, pair := range e.Pairs {
+			if empty := emptyLiteral(pair.Value); empty != nil {
+				return empty
+			}
+		}
+	}
+	return nil
+}
+
+// hasUnknownElem reports whether the elements of an array or map type t
+// are, at any depth, of unknown type.
+func hasUnknownElem(t Type) bool {
+	switch t := t.(type) {
+	case *Array:
+		return t.Elem == Unknown || hasUnknownElem(t.Elem)
+	case *Map:
+		return t.Key == Unknown || t.Value == Unknown || hasUnknownElem(t.Value)
+	}
+	return false
+}
+
+func (c *Checker) bind(name *ast.Identifier, t Type) {
+	c.scope.Insert(name.Value, t)
+	c.info.Defs[name] = t
+}
+
+func (c *Checker) returnStmt(stmt *ast.ReturnStatement) {
+	vt := Type(Null)
+	if stmt.ReturnValue != nil {
+		vt = c.expr(stmt.ReturnValue)
+	}
+
+	if c.fn == nil {
+		return
+	}
+
+	if c.fn.result != nil {
+		if !AssignableTo(vt, c.fn.result) {
+			c.errorf(diag.ReturnMismatch, stmt, "cannot use %s as type %s in return statement",
+				describe(stmt.ReturnValue, vt), c.fn.result)
+		}
+		return
+	}
+
+	// The first return with a known type fixes an undeclared result;
+	// later ones must agree with it.
+	first := c.fn.firstReturn
+	if first == nil || isLoose(first.typ) && !isLoose(vt) {
+		c.fn.firstReturn = &returnInfo{stmt: stmt, typ: vt}
+		return
+	}
+	if !AssignableTo(vt, first.typ) {
+		c.errorf(diag.ReturnMismatch, stmt, "inconsistent return types: %s here, %s before",
+			vt, first.typ).Notes = []diag.Note{
+			{Span: diag.SpanOf(first.stmt), Message: "first returns " + first.typ.String() + " here"},
+		}
+	}
+}
+
+// describe names a returned value for an error message.
+func describe(value ast.Expression, t Type) string {
+	if value == nil {
+		return "empty return"
+	}
+	return value.String() + " (type " + t.String() + ")"
+}
+
+// terminates reports whether every path through block ends in a return
+// statement.
+func terminates(block *ast.BlockStatement) bool {
+	if block == nil || len(block.Statements) == 0 {
+		return false
+	}
+
+	switch last := block.Statements[len(block.Statements)-1].(type) {
+	case *ast.ReturnStatement:
+		return true
+	case *ast.ExpressionStatement:
+		ie, ok := last.Expression.(*ast.IfExpression)
+		return ok && ie.Alternative != nil && terminates(ie.Consequence) && terminates(ie.Alternative)
+	case *ast.BlockStatement:
+		return terminates(last)
+	}
+
+	return false
+}
+
+// resolve returns the type that t denotes, reporting it if there is none.
+func (c *Checker) resolve(t ast.TypeExpr) Type {
+	return c.typeOf(t, true)
+}
+
+// typeOf returns the type that t denotes, or Invalid if it denotes none,
+// reporting why when report is set.
+func (c *Checker) typeOf(t ast.TypeExpr, report bool) Type {
+	errorf := func(code diag.Code, node ast.Node, format string, args ...interface{}) Type {
+		if report {
+			c.errorf(code, node, format, args...)
+		}
+		return Invalid
+	}
+
+	switch t := t.(type) {
+	case *ast.NamedType:
+		if typ, ok := universe[t.Name]; ok {
+			return typ
+		}
+		return errorf(diag.UnknownType, t, "undefined type: %s", t.Name)
+	case *ast.NullableType:
+		elem := c.typeOf(t.Elem, report)
+		if elem == Invalid {
+			return Invalid
+		}
+		if _, ok := elem.(*Nullable); ok {
+			return elem
+		}
+		return &Nullable{Elem: elem}
+	case *ast.FunctionType:
+		sig := &Signature{Result: Null}
+		for _, param := range t.Params {
+			sig.Params = append(sig.Params, c.typeOf(param, report))
+		}
+		if t.Result != nil {
+			sig.Result = c.typeOf(t.Result, report)
+		}
+		return sig
+	case *ast.GenericType:
+		want, ok := generics[t.Base.Name]
+		if !ok {
+			return errorf(diag.UnknownType, t.Base, "undefined generic type: %s", t.Base.Name)
+		}
+		if len(t.Args) != want {
+			return errorf(diag.UnknownType, t, "wrong number of type arguments for %s: want %d, got %d",
+				t.Base.Name, want, len(t.Args))
+		}
+		args := make([]Type, len(t.Args))
+		for i, arg := range t.Args {
+			if args[i] = c.typeOf(arg, report); args[i] == Invalid {
+				return Invalid
+			}
+		}
+		if t.Base.Name == "Array" {
+			return &Array{Elem: args[0]}
+		}
+		if !isHashable(args[0]) {
+			return errorf(diag.InvalidMapKey, t.Args[0], "invalid map key type %s", args[0])
+		}
+		return &Map{Key: args[0], Value: args[1]}
+	case *ast.ErrorUnionType:
+		return errorf(diag.UnsupportedType, t, "error union types are not supported yet: %s", t)
+	}
+	return Invalid
+}
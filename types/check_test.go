@@ -0,0 +1,253 @@
+package types
+
+import (
+	"chimp/ast"
+	"chimp/lexer"
+	"chimp/object"
+	"chimp/parser"
+	"strings"
+
+	"testing"
+)
+
+func checkInput(t *testing.T, input string) []string {
+	l := lexer.New(input, "checktest")
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+
+	msgs := []string{}
+	for _, err := range Check(program) {
+		msgs = append(msgs, err.Message)
+	}
+	return msgs
+}
+
+func TestWellTypedPrograms(t *testing.T) {
+	tests := []string{
+		"int x = 5; bool b = x > 2 && true; int y = x * 2 ** 3 + ~x;",
+		"let f = fn(int a, int b) int { return a + b; }; int r = f(1, 2);",
+		"let g = fn(int a) { return a; }; g(1);",
+		"let h = fn(bool c) int { if c { return 1; } else { return 2; } };",
+		"int z = if true { 1 } else { 2 };",
+		"let s = \"a\" + \"b\"; bool e = 1 == 2 || 1.5 < 2.5;",
+		"let fact = fn(int n) int { if n < 2 { return 1; } return n * fact(n - 1); };",
+		"let untyped = fn(x, y) { return x + y; }; untyped(1, true);",
+		"int n = null ?? 5; bool isNull = n == null;",
+		"if 1 > 2 { 1 } else { true }",
+		"let nothing = fn() { return; }; nothing();",
+		"let x = 5; x = 6; int y = x;",
+		"let half = fn(float f) { return f / 2.0; }; let h = half(3.0) + 1.0;",
+		"let fib = fn(int n) { if n < 2 { return n; } return fib(n - 1) + fib(n - 2); }; int f = fib(10);",
+		"let count = fn(int n) { if n == 0 { return count(n); } return n; }; int c = count(3);",
+		`string s = "meow"; float f = 1.5;`,
+		"?int n = null; ?int m = 5; int k = n ?? m ?? 0; bool same = n == 5;",
+		"let apply = fn(fn(int) int f, int x) int { return f(x); }; int r = apply(fn(int a) int { return a; }, 1);",
+		"fn(int, int) int add = fn(int a, int b) int { return a + b; }; int s = add(1, 2);",
+		"Array<int> xs = [1, 2, 3]; int x = xs[0]; Array<int> ys = xs[1:]; Array<int> none = [];",
+		"Array<?int> xs = [1, null, 3]; let ys = [null, 1]; Array<?int> zs = ys;",
+		`Map<string, int> m = {"a": 1, "b": 2}; ?int a = m["a"]; int b = m["b"] ?? 0;`,
+		`let m = {1: [true], 2: []}; Array<bool> bs = m[1] ?? [];`,
+		"let nested = [[1], []]; Array<Array<int>> ns = nested;",
+		"let f = fn(int x) { if x > 0 { return 1; } }; ?int y = f(-1); int z = f(1) ?? 0;",
+		"let fact = fn(int n) { if n < 2 { return 1; } return n * fact(n - 1); }; int r = fact(5);",
+		`string s = "hello"[1:3] + "hello"[0]; Map<bool, string> empty = {};`,
+		"let first = fn(Array<int> xs) int { return xs[0]; }; int f = first([1, 2]);",
+		"let untyped = fn(x) { return x[0] + x[1:][0]; };",
+		`int n = len("abc") + len([1, 2]) + len({"a": true}); puts(n, "x", [1]); puts(); print(1);`,
+		"?int f = first([1, 2]); ?string l = last([\"a\"]); Array<int> r = rest([1, 2]);",
+		"Array<int> xs = push([], 1); Array<?int> ys = push([1, 2], null); Array<string> zs = push(rest([\"a\"]), \"b\");",
+		`string t = type_of(1) + to_string([1]); ?int p = parse_int("12"); assert(p != null); assert(true, "ok");`,
+		"let f = fn(x) { return len(x) + first(x); };",
+		"let apply = fn(fn(Array<int>) ?int f) ?int { return f([1]); }; ?int r = apply(first);",
+		"let show = fn(fn(int) string f) string { return f(1); }; string s = show(to_string);",
+		"let len = fn(int a) int { return a; }; int n = len(2);",
+		"let isEven = fn(int n) bool { if n == 0 { return true; } return isOdd(n - 1); }; let isOdd = fn(int n) bool { if n == 0 { return false; } return isEven(n - 1); }; bool b = isEven(10);",
+		"let f = fn() { let ping = fn(int n) int { return pong(n); }; fn(int) int pong = fn(int n) int { return ping(n); }; return ping(1); };",
+		"let twice = fn(fn(int) int f) fn(int) int { return fn(int x) int { return f(f(x)); }; }; int r = twice(fn(int x) int { return x + 1; })(1);",
+	}
+
+	for _, input := range tests {
+		if errs := checkInput(t, input); len(errs) != 0 {
+			t.Errorf("%q: expected no errors. got=%q", input, errs)
+		}
+	}
+}
+
+func TestTypeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"int x = true;", "cannot use true (type bool) as type int in declaration of x"},
+		{"bool b = 1 + 2;", "cannot use (1 + 2) (type int) as type bool in declaration of b"},
+		{"int y = z;", "undefined: z"},
+		{"1 + true;", "invalid operation: (1 + true) (mismatched types int and bool)"},
+		{"1 + 1.5;", "invalid operation: (1 + 1.5) (mismatched types int and float)"},
+		{"true + false;", "invalid operation: operator + not defined on true (type bool)"},
+		{"\"a\" - \"b\";", "invalid operation: operator - not defined on \"a\" (type string)"},
+		{"1.5 & 2.5;", "invalid operation: operator & not defined on 1.5 (type float)"},
+		{"1 && true;", "invalid operation: (1 && true) (mismatched types int and bool)"},
+		{"-true;", "invalid operation: operator - not defined on true (type bool)"},
+		{"!1;", "invalid operation: operator ! not defined on 1 (type int)"},
+		{"let s = \"a\"; s++;", "invalid operation: operator ++ not defined on s (type string)"},
+		{"if 1 { 2 }", "non-boolean condition in if expression: 1 (type int)"},
+		{"int v = if true { 1 } else { false };", "if branches have different types: int and bool"},
+		{"let f = fn(int a) int { return a; }; f(1, 2);", "wrong number of arguments in call to f: want 1, got 2"},
+		{"let f = fn(int a) int { return a; }; f(true);", "cannot use true (type bool) as type int in argument 1 to f"},
+		{"int n = 1; n(2);", "cannot call non-function n (type int)"},
+		{"let f = fn() int { return true; };", "cannot use true (type bool) as type int in return statement"},
+		{"let f = fn() int { return; };", "cannot use empty return as type int in return statement"},
+		{"let f = fn(int a) { if a > 0 { return 1; } return false; };", "inconsistent return types: bool here, int before"},
+		{"let f = fn(int a) int { if a > 0 { return 1; } };", "missing return at end of function returning int"},
+		{"let f = fn(int x) { if (x > 0) { return 1; } }; let y = f(-1) + 1;",
+			"invalid operation: (f((-1)) + 1) (mismatched types ?int and int)"},
+		{`let f = fn(int n) { if (n == 0) { return "s"; } int y = f(n - 1); return to_string(y + 1); };`,
+			"cannot use f((n - 1)) (type string) as type int in declaration of y"},
+		{"let f = fn(widget w) { return w; };", "undefined type: widget"},
+		{"?int n = true;", "cannot use true (type bool) as type ?int in declaration of n"},
+		{"?int n = null; int m = n;", "cannot use n (type ?int) as type int in declaration of m"},
+		{"fn(int) bool f = fn(int a) int { return a; };", "cannot use fn(int a) int { return a; } (type fn(int) int) as type fn(int) bool in declaration of f"},
+		{"List<int> xs = 1;", "undefined generic type: List"},
+		{"Array<int, int> xs = [];", "wrong number of type arguments for Array: want 1, got 2"},
+		{"Map<float, int> m = {};", "invalid map key type float"},
+		{"Array<int> xs = [1, true];", "cannot use true (type bool) as type int in array literal"},
+		{`Array<string> xs = [1, 2];`, "cannot use [1, 2] (type Array<int>) as type Array<string> in declaration of xs"},
+		{`let m = {"a": 1, 2: 3};`, "cannot use 2 (type int) as type string in map literal"},
+		{`let m = {1.5: 1};`, "invalid map key 1.5 (type float)"},
+		{`let m = {"a": 1}; int v = m["a"];`, `cannot use (m["a"]) (type ?int) as type int in declaration of v`},
+		{`let m = {"a": 1}; m[1];`, "cannot use 1 (type int) as type string in map index"},
+		{`let xs = [1]; xs["a"];`, `invalid index "a" (type string): must be int`},
+		{`let xs = [1]; xs[0:true];`, "invalid index true (type bool): must be int"},
+		{"let n = 5; n[0];", "cannot index n (type int)"},
+		{`let m = {"a": 1}; m[0:1];`, "cannot slice m (type Map<string, int>)"},
+		{"len(1);", "cannot call len with (int): want fn(string) int or fn(Array<T>) int or fn(Map<K, V>) int"},
+		{"len();", "wrong number of arguments in call to len: want 1, got 0"},
+		{"assert(true, \"a\", \"b\");", "wrong number of arguments in call to assert: want 1 or 2, got 3"},
+		{"assert(1);", "cannot use 1 (type int) as type bool in argument 1 to assert"},
+		{"first(5);", "cannot use 5 (type int) as type Array<T> in argument 1 to first"},
+		{`push([1], "a");`, `cannot use "a" (type string) as type int in argument 2 to push`},
+		{"int n = first([1]);", "cannot use first([1]) (type ?int) as type int in declaration of n"},
+		{`parse_int(5);`, "cannot use 5 (type int) as type string in argument 1 to parse_int"},
+		{"let x = puts(1);", "cannot infer type of x from null"},
+		{"len = 5;", "cannot use 5 (type int) as type builtin len in assignment to len"},
+		{"let f = fn() { return g(); }; let x = 1; let g = fn() { return 1; };", "undefined: g"},
+		{"let f = fn() int { return g(); }; let g = fn() bool { return true; };", "cannot use g() (type bool) as type int in return statement"},
+		{"let show = fn(fn(int) int f) int { return f(1); }; show(to_string);",
+			"cannot use to_string (type fn(any) string) as type fn(int) int in argument 1 to show"},
+		{"IOError!int r = 1;", "error union types are not supported yet: IOError!int"},
+		{"let x = 5; x = \"hi\";", "cannot use \"hi\" (type string) as type int in assignment to x"},
+		{"let s = \"a\"; int n = s;", "cannot use s (type string) as type int in declaration of n"},
+		{"let f = fn() { return 1.5; }; int n = f();", "cannot use f() (type float) as type int in declaration of n"},
+		{"let g = fn() {}; int n = g();", "cannot use g() (type null) as type int in declaration of n"},
+		{"let z = null;", "cannot infer type of z from null"},
+		{"let a = [];", "cannot infer type of a from empty literal []"},
+		{"let m = {};", "cannot infer type of m from empty literal {}"},
+		{"let a = [[]];", "cannot infer type of a from empty literal []"},
+		{`let m = {"k": []};`, "cannot infer type of m from empty literal []"},
+		{"Array<int> a = []; a = [true];", "cannot use [true] (type Array<bool>) as type Array<int> in assignment to a"},
+		{`Map<string, int> m = {}; m = {"k": "v"};`, `cannot use {"k": "v"} (type Map<string, string>) as type Map<string, int> in assignment to m`},
+		{"let f = fn(int a) int { return a; }; int r = f(1) ?? true;", "invalid operation: (f(1) ?? true) (mismatched types int and bool)"},
+	}
+
+	for _, tt := range tests {
+		errs := checkInput(t, tt.input)
+		if len(errs) != 1 {
+			t.Errorf("%q: expected 1 error. got=%q", tt.input, errs)
+			continue
+		}
+		if errs[0] != tt.expected {
+			t.Errorf("%q: expected=%q. got=%q", tt.input, tt.expected, errs[0])
+		}
+	}
+}
+
+func TestErrorSpans(t *testing.T) {
+	input := "int a = 1;\nint b = a + true;"
+
+	l := lexer.New(input, "spantest")
+	p := parser.New(l)
+	errs := Check(p.ParseProgram())
+
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error. got=%v", errs)
+	}
+	span := errs[0].Span
+	if span.Start.String() != "spantest:2:9" || span.End.String() != "spantest:2:17" {
+		t.Errorf("span: expected spantest:2:9-spantest:2:17. got=%s-%s", span.Start, span.End)
+	}
+}
+
+func TestCheckerKeepsScope(t *testing.T) {
+	c := NewChecker()
+
+	for _, line := range []string{"int x = 1;", "let f = fn(int a) int { return a; };", "f(x);"} {
+		p := parser.New(lexer.New(line, "repl"))
+		if errs := c.Check(p.ParseProgram()); len(errs) != 0 {
+			t.Fatalf("%q: expected no errors. got=%v", line, errs)
+		}
+	}
+
+	p := parser.New(lexer.New("f(true);", "repl"))
+	errs := c.Check(p.ParseProgram())
+	if len(errs) != 1 || !strings.Contains(errs[0].Message, "as type int in argument 1") {
+		t.Errorf("expected an argument error. got=%v", errs)
+	}
+}
+
+func TestCheckerForgetsFailedLines(t *testing.T) {
+	c := NewChecker()
+
+	p := parser.New(lexer.New(`let y = 2; let z = "a" + 1;`, "repl"))
+	if errs := c.Check(p.ParseProgram()); len(errs) != 1 {
+		t.Fatalf("expected 1 error. got=%v", errs)
+	}
+
+	p = parser.New(lexer.New("y;", "repl"))
+	errs := c.Check(p.ParseProgram())
+	if len(errs) != 1 || errs[0].Message != "undefined: y" {
+		t.Errorf("expected y to be undefined. got=%v", errs)
+	}
+}
+
+func TestTypeOf(t *testing.T) {
+	input := `
+let x = 5;
+let s = "a" + "b";
+let add = fn(int a, int b) { return a + b; };
+let y = add(x, 2) > 3;
+`
+	l := lexer.New(input, "infotest")
+	p := parser.New(l)
+	program := p.ParseProgram()
+
+	c := NewChecker()
+	if errs := c.Check(program); len(errs) != 0 {
+		t.Fatalf("expected no errors. got=%v", errs)
+	}
+	info := c.Info()
+
+	expected := []string{"int", "string", "fn(int, int) int", "bool"}
+	for i, stmt := range program.Statements {
+		let := stmt.(*ast.LetStatement)
+		if got := info.TypeOf(let.Name); got == nil || got.String() != expected[i] {
+			t.Errorf("TypeOf(%s): expected=%s. got=%v", let.Name, expected[i], got)
+		}
+		if got := info.TypeOf(let.Value); got == nil || got.String() != expected[i] {
+			t.Errorf("TypeOf(%s): expected=%s. got=%v", let.Value, expected[i], got)
+		}
+	}
+}
+
+func TestBuiltinsMatchRuntime(t *testing.T) {
+	if len(Builtins) != len(object.Builtins) {
+		t.Fatalf("len(Builtins): expected=%d. got=%d", len(object.Builtins), len(Builtins))
+	}
+	for i, b := range Builtins {
+		if b.Name != object.Builtins[i].Name {
+			t.Errorf("Builtins[%d]: expected=%s. got=%s", i, object.Builtins[i].Name, b.Name)
+		}
+	}
+}
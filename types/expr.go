@@ -0,0 +1,480 @@
+package types
+
+import (
+	"chimp/ast"
+	"chimp/diag"
+)
+
+// expr returns the type of e, reporting any errors inside it.
+func (c *Checker) expr(e ast.Expression) Type {
+	t := c.exprInternal(e)
+	c.info.Types[e] = t
+	return t
+}
+
+func (c *Checker) exprInternal(e ast.Expression) Type {
+	switch e := e.(type) {
+	case *ast.IntegerLiteral:
+		return Int
+	case *ast.FloatLiteral:
+		return Float
+	case *ast.StringLiteral:
+		return String
+	case *ast.InterpolatedString:
+		for _, part := range e.Parts {
+			c.expr(part)
+		}
+		return String
+	case *ast.Boolean:
+		return Bool
+	case *ast.Null:
+		return Null
+	case *ast.Identifier:
+		if t, ok := c.scope.Lookup(e.Value); ok {
+			return t
+		}
+		c.errorf(diag.Undefined, e, "undefined: %s", e.Value)
+		return Invalid
+	case *ast.PrefixExpression:
+		return c.prefix(e)
+	case *ast.InfixExpression:
+		return c.infix(e)
+	case *ast.PostfixExpression:
+		return c.postfix(e)
+	case *ast.IfExpression:
+		return c.ifExpr(e, true)
+	case *ast.FunctionLiteral:
+		return c.funcLit(e)
+	case *ast.CallExpression:
+		return c.call(e)
+	case *ast.AssignExpression:
+		return c.assign(e)
+	case *ast.ArrayLiteral:
+		return c.arrayLit(e)
+	case *ast.HashLiteral:
+		return c.hashLit(e)
+	case *ast.IndexExpression:
+		return c.index(e)
+	case *ast.SliceExpression:
+		return c.slice(e)
+	}
+
+	return Unknown
+}
+
+func (c *Checker) prefix(e *ast.PrefixExpression) Type {
+	t := c.expr(e.Right)
+	if isLoose(t) {
+		if e.Operator == "!" {
+			return Bool
+		}
+		return t
+	}
+
+	switch {
+	case e.Operator == "!" && t == Bool,
+		e.Operator == "-" && (t == Int || t == Float),
+		e.Operator == "~" && t == Int:
+		return t
+	}
+
+	c.errorf(diag.InvalidOperation, e, "invalid operation: operator %s not defined on %s (type %s)",
+		e.Operator, e.Right, t)
+	return Invalid
+}
+
+// postfix checks x++ and x--, which need x to be a number.
+func (c *Checker) postfix(e *ast.PostfixExpression) Type {
+	t := c.expr(e.Left)
+	if isLoose(t) || t == Int || t == Float {
+		return t
+	}
+
+	c.errorf(diag.InvalidOperation, e, "invalid operation: operator %s not defined on %s (type %s)",
+		e.Operator, e.Left, t)
+	return Invalid
+}
+
+// operandTypes lists the types each binary operator accepts. Both operands
+// must have the same type.
+var operandTypes = map[string][]Type{
+	"+":  {Int, Float, String},
+	"-":  {Int, Float},
+	"*":  {Int, Float},
+	"/":  {Int, Float},
+	"**": {Int, Float},
+	"&":  {Int},
+	"|":  {Int},
+	"^":  {Int},
+	"<<": {Int},
+	">>": {Int},
+	"<":  {Int, Float},
+	"<=": {Int, Float},
+	">":  {Int, Float},
+	">=": {Int, Float},
+	"&&": {Bool},
+	"||": {Bool},
+	"^^": {Bool},
+}
+
+var comparisons = map[string]bool{
+	"<": true, "<=": true, ">": true, ">=": true, "==": true, "!=": true,
+}
+
+func (c *Checker) infix(e *ast.InfixExpression) Type {
+	left := c.expr(e.Left)
+	right := c.expr(e.Right)
+
+	switch e.Operator {
+	case "??":
+		if left == Null {
+			return right
+		}
+		if n, ok := left.(*Nullable); ok {
+			if AssignableTo(right, n.Elem) {
+				return n.Elem
+			}
+			if AssignableTo(right, n) {
+				return n
+			}
+			c.mismatch(e, left, right)
+			return Invalid
+		}
+		if !AssignableTo(right, left) {
+			c.mismatch(e, left, right)
+			return Invalid
+		}
+		return left
+	case "==", "!=":
+		if left != Null && right != Null && !AssignableTo(left, right) && !AssignableTo(right, left) {
+			c.mismatch(e, left, right)
+			return Invalid
+		}
+		return Bool
+	}
+
+	result := left
+	if isLoose(left) {
+		result = right
+	}
+	if comparisons[e.Operator] {
+		result = Bool
+	}
+
+	if isLoose(left) || isLoose(right) {
+		return result
+	}
+	if !Identical(left, right) {
+		c.mismatch(e, left, right)
+		return Invalid
+	}
+	for _, t := range operandTypes[e.Operator] {
+		if t == left {
+			return result
+		}
+	}
+
+	c.errorf(diag.InvalidOperation, e, "invalid operation: operator %s not defined on %s (type %s)",
+		e.Operator, e.Left, left)
+	return Invalid
+}
+
+func (c *Checker) mismatch(e *ast.InfixExpression, left, right Type) {
+	c.errorf(diag.InvalidOperation, e, "invalid operation: %s (mismatched types %s and %s)", e, left, right)
+}
+
+// ifExpr checks an if expression. When its value is used, both branches
+// must produce the same type; an if without an else produces null.
+func (c *Checker) ifExpr(e *ast.IfExpression, used bool) Type {
+	if cond := c.expr(e.Condition); !AssignableTo(cond, Bool) {
+		c.errorf(diag.TypeMismatch, e.Condition, "non-boolean condition in if expression: %s (type %s)",
+			e.Condition, cond)
+	}
+
+	consequence := c.blockValue(e.Consequence)
+	if e.Alternative == nil {
+		return Null
+	}
+	alternative := c.blockValue(e.Alternative)
+
+	if !used || AssignableTo(alternative, consequence) {
+		if isLoose(consequence) {
+			return alternative
+		}
+		return consequence
+	}
+
+	c.errorf(diag.TypeMismatch, e, "if branches have different types: %s and %s", consequence, alternative)
+	return Invalid
+}
+
+// blockValue checks block in a new scope, and returns the type of the
+// value it produces as the branch of an if expression.
+func (c *Checker) blockValue(block *ast.BlockStatement) Type {
+	c.openScope()
+	defer c.closeScope()
+
+	c.stmts(block.Statements)
+
+	if len(block.Statements) == 0 {
+		return Null
+	}
+	last, ok := block.Statements[len(block.Statements)-1].(*ast.ExpressionStatement)
+	if !ok {
+		return Null
+	}
+	if last.Expression == nil {
+		return Null
+	}
+	return c.info.Types[last.Expression]
+}
+
+// header returns the signature of fl as far as it is spelled out, with
+// Unknown standing in for a result that has to be inferred from the body.
+// Bad types are left for funcLit to report.
+func (c *Checker) header(fl *ast.FunctionLiteral) *Signature {
+	if sig, ok := c.headers[fl]; ok {
+		return sig
+	}
+
+	lookup := func(t ast.TypeExpr) Type {
+		if t == nil {
+			return Unknown
+		}
+		return c.typeOf(t, false)
+	}
+
+	sig := &Signature{Result: lookup(fl.ReturnType)}
+	for _, param := range fl.Parameters {
+		sig.Params = append(sig.Params, lookup(param.Type))
+	}
+	c.headers[fl] = sig
+	return sig
+}
+
+// funcLit checks fl. Without a declared result type, the function returns
+// the type of its first typed return statement, or null if it has none.
+// If it can also fall off the end of its body, the result is nullable.
+func (c *Checker) funcLit(fl *ast.FunctionLiteral) Type {
+	sig := &Signature{}
+	for _, param := range fl.Parameters {
+		if param.Type == nil {
+			sig.Params = append(sig.Params, Unknown)
+			continue
+		}
+		sig.Params = append(sig.Params, c.resolve(param.Type))
+	}
+
+	ctx := &funcContext{}
+	if fl.ReturnType != nil {
+		ctx.result = c.resolve(fl.ReturnType)
+	}
+
+	errs := len(c.errors)
+	c.funcBody(fl, sig.Params, ctx)
+
+	if ctx.result != nil && ctx.result != Null && !terminates(fl.Body) {
+		c.errors.Add(diag.MissingReturn, diag.At(fl.Body.Rbrace.Pos),
+			"missing return at end of function returning %s", ctx.result)
+	}
+
+	switch {
+	case ctx.result != nil:
+		sig.Result = ctx.result
+	case ctx.firstReturn != nil:
+		sig.Result = ctx.firstReturn.typ
+		if !terminates(fl.Body) {
+			sig.Result = nullable(sig.Result)
+		}
+	default:
+		sig.Result = Null
+	}
+
+	// Calls the function made to itself had an unknown result while it
+	// was being inferred. Now that it is known, the body is checked again
+	// so that they are checked too.
+	if hdr := c.headers[fl]; hdr != nil && hdr.Result == Unknown && fl.ReturnType == nil {
+		hdr.Result = sig.Result
+		if len(c.errors) == errs && !isLoose(sig.Result) {
+			c.funcBody(fl, sig.Params, &funcContext{result: sig.Result})
+		}
+	}
+
+	return sig
+}
+
+// funcBody checks the body of fl in ctx, with its parameters of the types
+// in params.
+func (c *Checker) funcBody(fl *ast.FunctionLiteral, params []Type, ctx *funcContext) {
+	outer := c.fn
+	c.fn = ctx
+	c.openScope()
+	for i, param := range fl.Parameters {
+		c.scope.Insert(param.Name.Value, params[i])
+	}
+	c.stmts(fl.Body.Statements)
+	c.closeScope()
+	c.fn = outer
+}
+
+func (c *Checker) call(e *ast.CallExpression) Type {
+	ft := c.expr(e.Function)
+
+	args := make([]Type, len(e.Arguments))
+	for i, arg := range e.Arguments {
+		args[i] = c.expr(arg)
+	}
+
+	if b, ok := ft.(*Builtin); ok {
+		return c.callBuiltin(e, b, args)
+	}
+
+	sig, ok := ft.(*Signature)
+	if !ok {
+		if !isLoose(ft) {
+			c.errorf(diag.NotCallable, e.Function, "cannot call non-function %s (type %s)", e.Function, ft)
+			return Invalid
+		}
+		return Unknown
+	}
+
+	if len(args) != len(sig.Params) {
+		c.errorf(diag.WrongArgCount, e, "wrong number of arguments in call to %s: want %d, got %d",
+			e.Function, len(sig.Params), len(args))
+		return sig.Result
+	}
+
+	for i, arg := range e.Arguments {
+		if !AssignableTo(args[i], sig.Params[i]) {
+			c.errorf(diag.TypeMismatch, arg, "cannot use %s (type %s) as type %s in argument %d to %s",
+				arg, args[i], sig.Params[i], i+1, e.Function)
+		}
+	}
+
+	return sig.Result
+}
+
+func (c *Checker) assign(e *ast.AssignExpression) Type {
+	target := c.expr(e.Target)
+	vt := c.expr(e.Value)
+
+	if !AssignableTo(vt, target) {
+		c.errorf(diag.TypeMismatch, e.Value, "cannot use %s (type %s) as type %s in assignment to %s",
+			e.Value, vt, target, e.Target)
+		return Invalid
+	}
+
+	return target
+}
+
+// arrayLit checks an array literal. Its element type is the type every
+// element can be used as; an empty array's is unknown.
+func (c *Checker) arrayLit(e *ast.ArrayLiteral) Type {
+	var elem Type
+	for _, el := range e.Elements {
+		elem = c.join(elem, el, c.expr(el), "array literal")
+	}
+	if elem == nil {
+		elem = Unknown
+	}
+	return &Array{Elem: elem}
+}
+
+func (c *Checker) hashLit(e *ast.HashLiteral) Type {
+	var key, value Type
+	for _, pair := range e.Pairs {
+		kt := c.expr(pair.Key)
+		if !isHashable(kt) {
+			c.errorf(diag.InvalidMapKey, pair.Key, "invalid map key %s (type %s)", pair.Key, kt)
+			kt = Invalid
+		}
+		key = c.join(key, pair.Key, kt, "map literal")
+		value = c.join(value, pair.Value, c.expr(pair.Value), "map literal")
+	}
+	if key == nil {
+		key, value = Unknown, Unknown
+	}
+	return &Map{Key: key, Value: value}
+}
+
+// join returns the type that values of type t and the value e, of type et,
+// can all be used as, reporting e if there is none. t is nil before the
+// first value.
+func (c *Checker) join(t Type, e ast.Expression, et Type, context string) Type {
+	switch {
+	case t == nil:
+		return et
+	case isLoose(t):
+		return et
+	case AssignableTo(et, t):
+		return t
+	case AssignableTo(t, et):
+		return et
+	case t == Null:
+		return nullable(et)
+	case et == Null:
+		return nullable(t)
+	}
+
+	c.errorf(diag.TypeMismatch, e, "cannot use %s (type %s) as type %s in %s", e, et, t, context)
+	return t
+}
+
+// nullable returns ?t, or t itself if it already admits null.
+func nullable(t Type) Type {
+	if _, ok := t.(*Nullable); ok || t == Null || isLoose(t) {
+		return t
+	}
+	return &Nullable{Elem: t}
+}
+
+// index checks a[i]. Indexing a map gives null for a missing key, so its
+// type is nullable.
+func (c *Checker) index(e *ast.IndexExpression) Type {
+	left := c.expr(e.Left)
+	it := c.expr(e.Index)
+
+	switch left := left.(type) {
+	case *Array:
+		c.intIndex(e.Index, it)
+		return left.Elem
+	case *Map:
+		if !AssignableTo(it, left.Key) {
+			c.errorf(diag.TypeMismatch, e.Index, "cannot use %s (type %s) as type %s in map index",
+				e.Index, it, left.Key)
+		}
+		return nullable(left.Value)
+	}
+
+	if left == String {
+		c.intIndex(e.Index, it)
+		return String
+	}
+	if !isLoose(left) {
+		c.errorf(diag.InvalidIndex, e.Left, "cannot index %s (type %s)", e.Left, left)
+		return Invalid
+	}
+	return Unknown
+}
+
+// slice checks a[lo:hi], which has the type of a.
+func (c *Checker) slice(e *ast.SliceExpression) Type {
+	left := c.expr(e.Left)
+	for _, bound := range []ast.Expression{e.Low, e.High} {
+		if bound != nil {
+			c.intIndex(bound, c.expr(bound))
+		}
+	}
+
+	if _, ok := left.(*Array); ok || left == String || isLoose(left) {
+		return left
+	}
+	c.errorf(diag.InvalidIndex, e.Left, "cannot slice %s (type %s)", e.Left, left)
+	return Invalid
+}
+
+func (c *Checker) intIndex(index ast.Expression, t Type) {
+	if !AssignableTo(t, Int) {
+		c.errorf(diag.InvalidIndex, index, "invalid index %s (type %s): must be int", index, t)
+	}
+}
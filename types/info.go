@@ -0,0 +1,31 @@
+package types
+
+import "chimp/ast"
+
+// Info records the types the checker worked out, for tools that need more
+// than a yes or no answer.
+type Info struct {
+	// Types maps each checked expression to its type.
+	Types map[ast.Expression]Type
+
+	// Defs maps the name in each declaration to the type it was bound to.
+	Defs map[*ast.Identifier]Type
+}
+
+func newInfo() *Info {
+	return &Info{Types: make(map[ast.Expression]Type), Defs: make(map[*ast.Identifier]Type)}
+}
+
+// TypeOf returns the type of e, or nil if e was never checked. Declared
+// names report the type they were bound to.
+func (info *Info) TypeOf(e ast.Expression) Type {
+	if t, ok := info.Types[e]; ok {
+		return t
+	}
+	if ident, ok := e.(*ast.Identifier); ok {
+		if t, ok := info.Defs[ident]; ok {
+			return t
+		}
+	}
+	return nil
+}
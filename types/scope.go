@@ -0,0 +1,25 @@
+package types
+
+// Scope maps the names declared in one block to their types.
+type Scope struct {
+	names map[string]Type
+	outer *Scope
+}
+
+func NewScope(outer *Scope) *Scope {
+	return &Scope{names: make(map[string]Type), outer: outer}
+}
+
+// Lookup finds name in this scope or the nearest enclosing one.
+func (s *Scope) Lookup(name string) (Type, bool) {
+	for ; s != nil; s = s.outer {
+		if t, ok := s.names[name]; ok {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+func (s *Scope) Insert(name string, t Type) {
+	s.names[name] = t
+}
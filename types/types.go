@@ -0,0 +1,177 @@
+// Package types implements Chimp's static type checker.
+package types
+
+import (
+	"bytes"
+	"strings"
+)
+
+type Type interface {
+	String() string
+}
+
+// Basic is a predeclared type.
+type Basic struct {
+	name string
+}
+
+func (b *Basic) String() string { return b.name }
+
+var (
+	Int    = &Basic{"int"}
+	Float  = &Basic{"float"}
+	Bool   = &Basic{"bool"}
+	String = &Basic{"string"}
+	Null   = &Basic{"null"}
+
+	// Any is the type of a builtin's parameter that takes a value of any
+	// type.
+	Any = &Basic{"any"}
+
+	// Unknown is the type of anything the checker cannot see the type of,
+	// such as an untyped parameter. It is compatible with every type, so
+	// it never causes an error.
+	Unknown = &Basic{"unknown"}
+
+	// Invalid is the type of an expression that already had an error. It
+	// is compatible with every type so the error is reported only once.
+	Invalid = &Basic{"invalid"}
+)
+
+// Signature is the type of a function. The last parameter of a variadic
+// signature, which only builtins have, takes any number of arguments.
+type Signature struct {
+	Params   []Type
+	Variadic bool
+	Result   Type
+}
+
+func (s *Signature) String() string {
+	var out bytes.Buffer
+
+	params := []string{}
+	for _, p := range s.Params {
+		params = append(params, p.String())
+	}
+	if s.Variadic {
+		params[len(params)-1] = "..." + params[len(params)-1]
+	}
+
+	out.WriteString("fn(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(")")
+	if s.Result != nil {
+		out.WriteString(" " + s.Result.String())
+	}
+
+	return out.String()
+}
+
+// Nullable is ?T, the type of a value that is either a T or null.
+type Nullable struct {
+	Elem Type
+}
+
+func (n *Nullable) String() string { return "?" + n.Elem.String() }
+
+// Array is Array<T>, the type of an array of Ts.
+type Array struct {
+	Elem Type
+}
+
+func (a *Array) String() string { return "Array<" + a.Elem.String() + ">" }
+
+// Map is Map<K, V>, the type of a hash from Ks to Vs.
+type Map struct {
+	Key, Value Type
+}
+
+func (m *Map) String() string { return "Map<" + m.Key.String() + ", " + m.Value.String() + ">" }
+
+// Identical reports whether a and b are the same type.
+func Identical(a, b Type) bool {
+	if a == b {
+		return true
+	}
+
+	switch a := a.(type) {
+	case *Nullable:
+		nb, ok := b.(*Nullable)
+		return ok && Identical(a.Elem, nb.Elem)
+	case *Array:
+		ab, ok := b.(*Array)
+		return ok && Identical(a.Elem, ab.Elem)
+	case *Map:
+		mb, ok := b.(*Map)
+		return ok && Identical(a.Key, mb.Key) && Identical(a.Value, mb.Value)
+	}
+
+	sa, ok := a.(*Signature)
+	if !ok {
+		return false
+	}
+	sb, ok := b.(*Signature)
+	if !ok || len(sa.Params) != len(sb.Params) || sa.Variadic != sb.Variadic {
+		return false
+	}
+	for i := range sa.Params {
+		if !Identical(sa.Params[i], sb.Params[i]) {
+			return false
+		}
+	}
+	return Identical(sa.Result, sb.Result)
+}
+
+// AssignableTo reports whether a value of type v may be stored in a
+// variable of type t. Arrays and maps cannot be changed in place, so an
+// Array<int> may be used as an Array<?int>.
+func AssignableTo(v, t Type) bool {
+	if isLoose(v) || isLoose(t) {
+		return true
+	}
+	if n, ok := t.(*Nullable); ok && !Identical(v, t) {
+		return v == Null || AssignableTo(v, n.Elem)
+	}
+
+	if b, ok := v.(*Builtin); ok {
+		sig, ok := t.(*Signature)
+		return ok && b.fits(sig)
+	}
+
+	switch t := t.(type) {
+	case *Array:
+		av, ok := v.(*Array)
+		return ok && AssignableTo(av.Elem, t.Elem)
+	case *Map:
+		mv, ok := v.(*Map)
+		return ok && (isLoose(mv.Key) || isLoose(t.Key) || Identical(mv.Key, t.Key)) &&
+			AssignableTo(mv.Value, t.Value)
+	}
+	return Identical(v, t)
+}
+
+// isLoose reports whether t stands for a type the checker does not know,
+// and so must not be complained about.
+func isLoose(t Type) bool {
+	return t == Unknown || t == Invalid
+}
+
+// isHashable reports whether t may be the key type of a map.
+func isHashable(t Type) bool {
+	return t == Int || t == Bool || t == String || isLoose(t)
+}
+
+// universe holds the predeclared type names.
+var universe = map[string]Type{
+	"int":    Int,
+	"float":  Float,
+	"bool":   Bool,
+	"string": String,
+}
+
+// generics holds the predeclared generic type names and the number of
+// type arguments each takes.
+var generics = map[string]int{
+	"Array": 1,
+	"Map":   2,
+}
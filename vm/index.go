@@ -0,0 +1,41 @@
+package vm
+
+import "chimp/object"
+
+// buildHash makes a hash of the keys and values on the stack from start
+// up to end.
+func (vm *VM) buildHash(start, end int) (object.Object, error) {
+	hash := object.NewHash()
+
+	for i := start; i < end; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, err := object.HashableKey(key)
+		if err != nil {
+			return nil, vm.errorf("%s", err)
+		}
+		hash.Set(hashKey, value)
+	}
+
+	return hash, nil
+}
+
+func (vm *VM) executeIndexExpression(left, index object.Object) error {
+	result, err := object.Index(left, index)
+	if err != nil {
+		return vm.errorf("%s", err)
+	}
+	if result == nil {
+		return vm.push(Null)
+	}
+	return vm.push(result)
+}
+
+func (vm *VM) executeSliceExpression(left, low, high object.Object) error {
+	result, err := object.Slice(left, low, high)
+	if err != nil {
+		return vm.errorf("%s", err)
+	}
+	return vm.push(result)
+}
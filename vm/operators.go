@@ -0,0 +1,221 @@
+package vm
+
+import (
+	"chimp/code"
+	"chimp/object"
+	"math"
+)
+
+var binaryOperators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpPow:          "**",
+	code.OpBitAnd:       "&",
+	code.OpBitOr:        "|",
+	code.OpBitXor:       "^",
+	code.OpShl:          "<<",
+	code.OpShr:          ">>",
+	code.OpBoolXor:      "^^",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpLessThan:     "<",
+	code.OpLessEqual:    "<=",
+	code.OpGreaterThan:  ">",
+	code.OpGreaterEqual: ">=",
+}
+
+func (vm *VM) executePrefixOperation(op code.Opcode) error {
+	operand := vm.pop()
+
+	switch op {
+	case code.OpBang:
+		return vm.push(nativeBoolToBooleanObject(!isTruthy(operand)))
+	case code.OpMinus:
+		switch operand := operand.(type) {
+		case *object.Integer:
+			return vm.push(&object.Integer{Value: -operand.Value})
+		case *object.Float:
+			return vm.push(&object.Float{Value: -operand.Value})
+		}
+		return vm.errorf("unknown operator: -%s", operand.Type())
+	case code.OpBitNot:
+		if operand, ok := operand.(*object.Integer); ok {
+			return vm.push(&object.Integer{Value: ^operand.Value})
+		}
+		return vm.errorf("unknown operator: ~%s", operand.Type())
+	case code.OpIncrement, code.OpDecrement:
+		delta, operator := int64(1), "++"
+		if op == code.OpDecrement {
+			delta, operator = -1, "--"
+		}
+		switch operand := operand.(type) {
+		case *object.Integer:
+			return vm.push(&object.Integer{Value: operand.Value + delta})
+		case *object.Float:
+			return vm.push(&object.Float{Value: operand.Value + float64(delta)})
+		}
+		return vm.errorf("unknown operator: %s%s", operand.Type(), operator)
+	}
+
+	return vm.errorf("unknown prefix opcode: %d", op)
+}
+
+// executeBinaryOperation applies a binary operator to the two values on top
+// of the stack. Both operands must have the same type, except that
+// anything may be compared against null.
+func (vm *VM) executeBinaryOperation(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+	operator := binaryOperators[op]
+
+	switch {
+	case op == code.OpBoolXor:
+		return vm.push(nativeBoolToBooleanObject(isTruthy(left) != isTruthy(right)))
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return vm.executeIntegerOperation(operator, left.(*object.Integer).Value, right.(*object.Integer).Value)
+	case left.Type() == object.FLOAT_OBJ && right.Type() == object.FLOAT_OBJ:
+		return vm.executeFloatOperation(operator, left.(*object.Float).Value, right.(*object.Float).Value)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return vm.executeStringOperation(operator, left.(*object.String).Value, right.(*object.String).Value)
+	case left.Type() == object.BOOLEAN_OBJ && right.Type() == object.BOOLEAN_OBJ:
+		return vm.executeBooleanOperation(operator, left.(*object.Boolean).Value, right.(*object.Boolean).Value)
+	case left == Null || right == Null:
+		switch operator {
+		case "==":
+			return vm.push(nativeBoolToBooleanObject(left == right))
+		case "!=":
+			return vm.push(nativeBoolToBooleanObject(left != right))
+		}
+	case left.Type() != right.Type():
+		return vm.errorf("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+	}
+
+	return vm.errorf("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+}
+
+func (vm *VM) executeIntegerOperation(operator string, left, right int64) error {
+	var result int64
+
+	switch operator {
+	case "+":
+		result = left + right
+	case "-":
+		result = left - right
+	case "*":
+		result = left * right
+	case "/":
+		if right == 0 {
+			return vm.errorf("division by zero")
+		}
+		result = left / right
+	case "**":
+		if right < 0 {
+			return vm.errorf("negative exponent: %d ** %d", left, right)
+		}
+		result = intPow(left, right)
+	case "&":
+		result = left & right
+	case "|":
+		result = left | right
+	case "^":
+		result = left ^ right
+	case "<<", ">>":
+		if right < 0 {
+			return vm.errorf("negative shift count: %d", right)
+		}
+		if operator == "<<" {
+			result = left << uint64(right)
+		} else {
+			result = left >> uint64(right)
+		}
+	case "<":
+		return vm.push(nativeBoolToBooleanObject(left < right))
+	case "<=":
+		return vm.push(nativeBoolToBooleanObject(left <= right))
+	case ">":
+		return vm.push(nativeBoolToBooleanObject(left > right))
+	case ">=":
+		return vm.push(nativeBoolToBooleanObject(left >= right))
+	case "==":
+		return vm.push(nativeBoolToBooleanObject(left == right))
+	case "!=":
+		return vm.push(nativeBoolToBooleanObject(left != right))
+	default:
+		return vm.errorf("unknown operator: %s %s %s", object.INTEGER_OBJ, operator, object.INTEGER_OBJ)
+	}
+
+	return vm.push(&object.Integer{Value: result})
+}
+
+// intPow computes base**exp by repeated squaring, wrapping around on
+// overflow like the evaluator does.
+func intPow(base, exp int64) int64 {
+	result := int64(1)
+	for exp > 0 {
+		if exp&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exp >>= 1
+	}
+	return result
+}
+
+func (vm *VM) executeFloatOperation(operator string, left, right float64) error {
+	var result float64
+
+	switch operator {
+	case "+":
+		result = left + right
+	case "-":
+		result = left - right
+	case "*":
+		result = left * right
+	case "/":
+		result = left / right
+	case "**":
+		result = math.Pow(left, right)
+	case "<":
+		return vm.push(nativeBoolToBooleanObject(left < right))
+	case "<=":
+		return vm.push(nativeBoolToBooleanObject(left <= right))
+	case ">":
+		return vm.push(nativeBoolToBooleanObject(left > right))
+	case ">=":
+		return vm.push(nativeBoolToBooleanObject(left >= right))
+	case "==":
+		return vm.push(nativeBoolToBooleanObject(left == right))
+	case "!=":
+		return vm.push(nativeBoolToBooleanObject(left != right))
+	default:
+		return vm.errorf("unknown operator: %s %s %s", object.FLOAT_OBJ, operator, object.FLOAT_OBJ)
+	}
+
+	return vm.push(&object.Float{Value: result})
+}
+
+func (vm *VM) executeStringOperation(operator string, left, right string) error {
+	switch operator {
+	case "+":
+		return vm.push(&object.String{Value: left + right})
+	case "==":
+		return vm.push(nativeBoolToBooleanObject(left == right))
+	case "!=":
+		return vm.push(nativeBoolToBooleanObject(left != right))
+	}
+
+	return vm.errorf("unknown operator: %s %s %s", object.STRING_OBJ, operator, object.STRING_OBJ)
+}
+
+func (vm *VM) executeBooleanOperation(operator string, left, right bool) error {
+	switch operator {
+	case "==":
+		return vm.push(nativeBoolToBooleanObject(left == right))
+	case "!=":
+		return vm.push(nativeBoolToBooleanObject(left != right))
+	}
+
+	return vm.errorf("unknown operator: %s %s %s", object.BOOLEAN_OBJ, operator, object.BOOLEAN_OBJ)
+}
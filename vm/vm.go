@@ -0,0 +1,523 @@
+package vm
+
+import (
+	"chimp/code"
+	"chimp/compiler"
+	"chimp/object"
+	"chimp/token"
+	"fmt"
+	"strings"
+)
+
+// StackSize leaves room for 16 slots for each frame, so that recursion
+// usually runs out of frames first and overflows at the same depth as on
+// the evaluator.
+const StackSize = 16 * MaxFrames
+const GlobalsSize = 65536
+const MaxFrames = 1024
+
+var True = &object.Boolean{Value: true}
+var False = &object.Boolean{Value: false}
+var Null = &object.Null{}
+
+type VM struct {
+	constants []object.Object
+
+	stack []object.Object
+	sp    int // Always points to the next value. Top of stack is stack[sp-1]
+
+	globals []object.Object
+
+	frames      []*Frame
+	framesIndex int
+
+	// openUpvalues holds the upvalues that still refer to a stack slot,
+	// keyed by the slot's index.
+	openUpvalues map[int]*object.Upvalue
+
+	result object.Object
+}
+
+// RuntimeError is an error raised while running a program. It reads like
+// the runtime errors of the evaluator, and has the same stack trace.
+type RuntimeError struct {
+	Message string
+	Pos     token.Position
+	Trace   []object.StackFrame
+}
+
+func (e *RuntimeError) Error() string {
+	if e.Pos.IsValid() {
+		return fmt.Sprintf("%s: runtime error: %s", e.Pos, e.Message)
+	}
+	return "runtime error: " + e.Message
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		Positions:    bytecode.Positions,
+	}
+	mainClosure := &object.Closure{Fn: mainFn}
+	mainFrame := NewFrame(mainClosure, 0)
+
+	frames := make([]*Frame, MaxFrames)
+	frames[0] = mainFrame
+
+	return &VM{
+		constants: bytecode.Constants,
+
+		stack: make([]object.Object, StackSize),
+		sp:    0,
+
+		globals: make([]object.Object, GlobalsSize),
+
+		frames:      frames,
+		framesIndex: 1,
+
+		openUpvalues: make(map[int]*object.Upvalue),
+	}
+}
+
+// NewWithGlobalsStore returns a VM that shares globals with an earlier
+// one, as the REPL needs.
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
+	vm := New(bytecode)
+	vm.globals = s
+	return vm
+}
+
+// Result returns the value of the program's final expression statement,
+// or of the return statement that ended it. It is nil if the program ended
+// with a declaration.
+func (vm *VM) Result() object.Object {
+	return vm.result
+}
+
+func (vm *VM) Run() error {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			if err := vm.push(vm.constants[constIndex]); err != nil {
+				return err
+			}
+
+		case code.OpPop:
+			vm.pop()
+
+		case code.OpTrue:
+			if err := vm.push(True); err != nil {
+				return err
+			}
+
+		case code.OpFalse:
+			if err := vm.push(False); err != nil {
+				return err
+			}
+
+		case code.OpNull:
+			if err := vm.push(Null); err != nil {
+				return err
+			}
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpPow,
+			code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpShl, code.OpShr,
+			code.OpBoolXor, code.OpEqual, code.OpNotEqual,
+			code.OpLessThan, code.OpLessEqual, code.OpGreaterThan, code.OpGreaterEqual:
+			if err := vm.executeBinaryOperation(op); err != nil {
+				return err
+			}
+
+		case code.OpMinus, code.OpBang, code.OpBitNot, code.OpIncrement, code.OpDecrement:
+			if err := vm.executePrefixOperation(op); err != nil {
+				return err
+			}
+
+		case code.OpBool:
+			vm.push(nativeBoolToBooleanObject(isTruthy(vm.pop())))
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
+
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			condition := vm.pop()
+			if !isTruthy(condition) {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpJumpNotNull:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if vm.stack[vm.sp-1] != Null {
+				vm.currentFrame().ip = pos - 1
+			} else {
+				vm.pop()
+			}
+
+		case code.OpInterpolate:
+			numParts := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			var out strings.Builder
+			for _, part := range vm.stack[vm.sp-numParts : vm.sp] {
+				out.WriteString(part.Inspect())
+			}
+			vm.sp -= numParts
+
+			if err := vm.push(&object.String{Value: out.String()}); err != nil {
+				return err
+			}
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			elements := make([]object.Object, numElements)
+			copy(elements, vm.stack[vm.sp-numElements:vm.sp])
+			vm.sp -= numElements
+
+			if err := vm.push(&object.Array{Elements: elements}); err != nil {
+				return err
+			}
+
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			hash, err := vm.buildHash(vm.sp-numElements, vm.sp)
+			if err != nil {
+				return err
+			}
+			vm.sp -= numElements
+
+			if err := vm.push(hash); err != nil {
+				return err
+			}
+
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+
+			if err := vm.executeIndexExpression(left, index); err != nil {
+				return err
+			}
+
+		case code.OpSlice:
+			high := vm.pop()
+			low := vm.pop()
+			left := vm.pop()
+
+			if err := vm.executeSliceExpression(left, low, high); err != nil {
+				return err
+			}
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			if err := vm.push(vm.globals[globalIndex]); err != nil {
+				return err
+			}
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			vm.globals[globalIndex] = vm.pop()
+
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+
+			if err := vm.push(vm.stack[frame.basePointer+int(localIndex)]); err != nil {
+				return err
+			}
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			frame := vm.currentFrame()
+
+			vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
+
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			currentClosure := vm.currentFrame().cl
+			if err := vm.push(currentClosure.Free[freeIndex].Get()); err != nil {
+				return err
+			}
+
+		case code.OpSetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			currentClosure := vm.currentFrame().cl
+			currentClosure.Free[freeIndex].Set(vm.pop())
+
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			if err := vm.push(object.Builtins[builtinIndex]); err != nil {
+				return err
+			}
+
+		case code.OpCaptureLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			slot := vm.currentFrame().basePointer + int(localIndex)
+			if err := vm.push(vm.captureUpvalue(slot)); err != nil {
+				return err
+			}
+
+		case code.OpCaptureFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			currentClosure := vm.currentFrame().cl
+			if err := vm.push(currentClosure.Free[freeIndex]); err != nil {
+				return err
+			}
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+
+			if err := vm.pushClosure(int(constIndex), int(numFree)); err != nil {
+				return err
+			}
+
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+
+			if err := vm.executeCall(int(numArgs)); err != nil {
+				return err
+			}
+
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+
+			if vm.returnFrom(returnValue) {
+				return nil
+			}
+
+		case code.OpReturn:
+			// Only the main program returns nothing at all; functions
+			// return null.
+			if vm.framesIndex == 1 {
+				vm.returnFrom(nil)
+				return nil
+			}
+			vm.returnFrom(Null)
+
+		default:
+			def, _ := code.Lookup(byte(op))
+			return vm.errorf("unsupported instruction %s", def.Name)
+		}
+	}
+
+	return nil
+}
+
+// returnFrom pops the current frame and hands val to its caller. It
+// reports whether that was the main program, which ends the run.
+func (vm *VM) returnFrom(val object.Object) bool {
+	frame := vm.popFrame()
+	vm.closeUpvalues(frame.basePointer)
+
+	if vm.framesIndex == 0 {
+		vm.result = val
+		return true
+	}
+
+	vm.sp = frame.basePointer - 1
+	vm.push(val)
+	return false
+}
+
+func (vm *VM) executeCall(numArgs int) error {
+	callee := vm.stack[vm.sp-1-numArgs]
+	if builtin, ok := callee.(*object.Builtin); ok {
+		return vm.callBuiltin(builtin, numArgs)
+	}
+
+	cl, ok := callee.(*object.Closure)
+	if !ok {
+		return vm.errorf("not a function: %s", callee.Type())
+	}
+
+	if numArgs != cl.Fn.NumParameters {
+		return vm.errorf("wrong number of arguments: want=%d, got=%d",
+			cl.Fn.NumParameters, numArgs)
+	}
+
+	basePointer := vm.sp - numArgs
+	if vm.framesIndex >= MaxFrames || basePointer+cl.Fn.NumLocals >= StackSize {
+		return vm.errorf("stack overflow at depth %d", vm.framesIndex)
+	}
+
+	frame := NewFrame(cl, basePointer)
+	vm.pushFrame(frame)
+
+	for i := vm.sp; i < basePointer+cl.Fn.NumLocals; i++ {
+		vm.stack[i] = nil
+	}
+	vm.sp = frame.basePointer + cl.Fn.NumLocals
+
+	return nil
+}
+
+// callBuiltin calls a builtin with the arguments on top of the stack and
+// replaces them and the builtin with its result.
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
+	args := vm.stack[vm.sp-numArgs : vm.sp]
+
+	result := builtin.Fn(args...)
+	vm.sp = vm.sp - numArgs - 1
+
+	switch result := result.(type) {
+	case nil:
+		return vm.push(Null)
+	case *object.Error:
+		return vm.errorf("%s", result.Message)
+	default:
+		return vm.push(result)
+	}
+}
+
+func (vm *VM) pushClosure(constIndex int, numFree int) error {
+	constant := vm.constants[constIndex]
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return vm.errorf("not a function: %+v", constant)
+	}
+
+	free := make([]*object.Upvalue, numFree)
+	for i := 0; i < numFree; i++ {
+		free[i] = vm.stack[vm.sp-numFree+i].(*object.Upvalue)
+	}
+	vm.sp = vm.sp - numFree
+
+	closure := &object.Closure{Fn: function, Free: free}
+	return vm.push(closure)
+}
+
+// captureUpvalue returns the upvalue for a stack slot, so that every
+// closure capturing the same variable shares it.
+func (vm *VM) captureUpvalue(slot int) *object.Upvalue {
+	if uv, ok := vm.openUpvalues[slot]; ok {
+		return uv
+	}
+
+	uv := &object.Upvalue{Location: &vm.stack[slot]}
+	vm.openUpvalues[slot] = uv
+	return uv
+}
+
+// closeUpvalues closes the upvalues of every slot from base upwards, as
+// the frame owning them is about to go away.
+func (vm *VM) closeUpvalues(base int) {
+	for slot, uv := range vm.openUpvalues {
+		if slot >= base {
+			uv.Close()
+			delete(vm.openUpvalues, slot)
+		}
+	}
+}
+
+func (vm *VM) push(o object.Object) error {
+	if vm.sp >= StackSize {
+		return vm.errorf("stack overflow at depth %d", vm.framesIndex-1)
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) {
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+// errorf returns a runtime error positioned at the current instruction.
+// Unless it happened in the main program, it carries the calls in
+// progress as a stack trace.
+func (vm *VM) errorf(format string, a ...interface{}) *RuntimeError {
+	frame := vm.currentFrame()
+	err := &RuntimeError{
+		Message: fmt.Sprintf(format, a...),
+		Pos:     frame.cl.Fn.Positions.Lookup(frame.ip),
+	}
+
+	if vm.framesIndex > 1 {
+		for i := vm.framesIndex - 1; i >= 0; i-- {
+			f := vm.frames[i]
+			name := f.cl.Fn.Name
+			if i == 0 {
+				name = "main"
+			}
+			err.Trace = append(err.Trace, object.StackFrame{Function: name, Pos: f.cl.Fn.Positions.Lookup(f.ip)})
+		}
+	}
+
+	return err
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return True
+	}
+	return False
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case Null, False:
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,575 @@
+package vm
+
+import (
+	"chimp/ast"
+	"chimp/compiler"
+	"chimp/evaluator"
+	"chimp/lexer"
+	"chimp/object"
+	"chimp/parser"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input, "vmtest")
+	p := parser.New(l)
+	return p.ParseProgram()
+}
+
+type vmTestCase struct {
+	input    string
+	expected interface{}
+}
+
+func runVmTests(t *testing.T, tests []vmTestCase) {
+	t.Helper()
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		err := comp.Compile(program)
+		if err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		err = vm.Run()
+		if err != nil {
+			t.Fatalf("vm error for %q: %s", tt.input, err)
+		}
+
+		testExpectedObject(t, tt.input, tt.expected, vm.Result())
+	}
+}
+
+func testExpectedObject(t *testing.T, input string, expected interface{}, actual object.Object) {
+	t.Helper()
+
+	switch expected := expected.(type) {
+	case int:
+		result, ok := actual.(*object.Integer)
+		if !ok || result.Value != int64(expected) {
+			t.Errorf("%q: expected=%d. got=%#v", input, expected, actual)
+		}
+	case float64:
+		result, ok := actual.(*object.Float)
+		if !ok || result.Value != expected {
+			t.Errorf("%q: expected=%g. got=%#v", input, expected, actual)
+		}
+	case bool:
+		result, ok := actual.(*object.Boolean)
+		if !ok || result.Value != expected {
+			t.Errorf("%q: expected=%t. got=%#v", input, expected, actual)
+		}
+	case string:
+		result, ok := actual.(*object.String)
+		if !ok || result.Value != expected {
+			t.Errorf("%q: expected=%q. got=%#v", input, expected, actual)
+		}
+	case []int:
+		array, ok := actual.(*object.Array)
+		if !ok || len(array.Elements) != len(expected) {
+			t.Errorf("%q: expected=%v. got=%#v", input, expected, actual)
+			return
+		}
+		for i, el := range expected {
+			testExpectedObject(t, input, el, array.Elements[i])
+		}
+	case map[object.HashKey]int:
+		hash, ok := actual.(*object.Hash)
+		if !ok || len(hash.Pairs) != len(expected) {
+			t.Errorf("%q: expected=%v. got=%#v", input, expected, actual)
+			return
+		}
+		for key, value := range expected {
+			pair, ok := hash.Pairs[key]
+			if !ok {
+				t.Errorf("%q: no pair for key %v", input, key)
+				continue
+			}
+			testExpectedObject(t, input, value, pair.Value)
+		}
+	case *object.Null:
+		if actual != Null {
+			t.Errorf("%q: expected=null. got=%#v", input, actual)
+		}
+	case nil:
+		if actual != nil {
+			t.Errorf("%q: expected no result. got=%#v", input, actual)
+		}
+	}
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"1", 1},
+		{"1 + 2", 3},
+		{"4 / 2", 2},
+		{"50 / 2 * 2 + 10 - 5", 55},
+		{"5 * (2 + 10)", 60},
+		{"-50 + 100 + -50", 0},
+		{"2 ** 3 ** 2", 512},
+		{"6 & 3 | 8 ^ 1", 11},
+		{"1 << 4 >> 2", 4},
+		{"~5", -6},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestFloatArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"1.5 + 2.25", 3.75},
+		{"-1.5 * 2.0", -3.0},
+		{"2.0 ** 0.5 > 1.41", true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBooleanExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"true", true},
+		{"1 < 2", true},
+		{"1 <= 1", true},
+		{"1 > 2", false},
+		{"2 >= 3", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"true == false", false},
+		{"(1 < 2) == true", true},
+		{"!true", false},
+		{"!!5", true},
+		{"!null", true},
+		{"null == null", true},
+		{"1 == null", false},
+		{"true ^^ false", true},
+		{"1 && 0", true},
+		{"null || false", false},
+		{"false && (1 / 0 == 0)", false},
+		{"true || (1 / 0 == 0)", true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCoalesce(t *testing.T) {
+	tests := []vmTestCase{
+		{"null ?? 5", 5},
+		{"3 ?? 5", 3},
+		{"null ?? null ?? 7", 7},
+		{"false ?? 1", false},
+		{"1 ?? 1 / 0", 1},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []vmTestCase{
+		{"if true { 10 }", 10},
+		{"if true { 10 } else { 20 }", 10},
+		{"if false { 10 } else { 20 } ", 20},
+		{"if 1 < 2 { 10 }", 10},
+		{"if 1 > 2 { 10 }", Null},
+		{"if false { 10 } else if true { 15 } else { 20 }", 15},
+		{"if true { let x = 1; }", Null},
+		{"let x = 1; if true { let x = 2; }; x", 1},
+		{"let x = 1; if true { let x = 2; x } else { 3 }", 2},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStrings(t *testing.T) {
+	tests := []vmTestCase{
+		{`"monkey"`, "monkey"},
+		{`"mon" + "key" + "banana"`, "monkeybanana"},
+		{`let n = 4; "${n} + ${n + 1} = ${n + n + 1}"`, "4 + 5 = 9"},
+		{`"a" == "a"`, true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestGlobalDeclarations(t *testing.T) {
+	tests := []vmTestCase{
+		{"let one = 1; one", 1},
+		{"int one = 1; int two = one + one; one + two", 3},
+		{"", nil},
+		{"let x = 1;", nil},
+		{"let x = 1; x = x + 1; x", 2},
+		{"let x = 1; let x = x + 1; x", 2},
+		{"let caf\u00e9 = 1; let _x = cafe\u0301 + 1; _x", 2},
+		{"return 5; 10", 5},
+		{"return;", Null},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCallingFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let f = fn() { return 5 + 10; }; f()", 15},
+		{"let f = fn() { 5 + 10; }; f()", Null},
+		{"let f = fn() { return; }; f()", Null},
+		{"let f = fn() { if true { return 1; } return 2; }; f()", 1},
+		{"let sum = fn(int a, int b) { let c = a + b; return c; }; sum(1, 2) + sum(3, 4)", 10},
+		{"let g = 50; let f = fn() { let g = 1; return g; }; f() + g", 51},
+		{"let f = fn(a) { if a { let b = 1; return b; } let c = 2; return c; }; f(true) + f(false)", 3},
+		{"let a = fn() { return 1; }; let b = fn() { return a; }; b()()", 1},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{`
+let newAdder = fn(a, b) {
+    let c = a + b;
+    return fn(d) { return c + d; };
+};
+let adder = newAdder(1, 2);
+adder(8);`, 11},
+		{`
+let newAdderOuter = fn(a, b) {
+    let c = a + b;
+    return fn(d) {
+        let e = d + c;
+        return fn(f) { return e + f; };
+    };
+};
+newAdderOuter(1, 2)(3)(8);`, 14},
+		{`
+let counter = fn() {
+    let count = 0;
+    return fn() { count = count + 1; return count; };
+};
+let next = counter();
+next(); next();
+next();`, 3},
+		{`
+let pair = fn() {
+    let n = 0;
+    let inc = fn() { n = n + 1; };
+    let get = fn() { return n; };
+    inc(); inc();
+    return get;
+};
+pair()();`, 2},
+		{`
+let wrapper = fn() {
+    let countDown = fn(x) {
+        if x == 0 { return 0; }
+        return countDown(x - 1);
+    };
+    return countDown(5);
+};
+wrapper();`, 0},
+		{`
+let fibonacci = fn(x) {
+    if x < 2 { return x; }
+    return fibonacci(x - 1) + fibonacci(x - 2);
+};
+fibonacci(15);`, 610},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestArrayLiterals(t *testing.T) {
+	tests := []vmTestCase{
+		{"[]", []int{}},
+		{"[1, 2, 3]", []int{1, 2, 3}},
+		{"[1 + 2, 3 * 4, 5 + 6]", []int{3, 12, 11}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestHashLiterals(t *testing.T) {
+	tests := []vmTestCase{
+		{"{}", map[object.HashKey]int{}},
+		{"{1: 2, 2: 3}", map[object.HashKey]int{
+			(&object.Integer{Value: 1}).HashKey(): 2,
+			(&object.Integer{Value: 2}).HashKey(): 3,
+		}},
+		{`{1 + 1: 2 * 2, "a": 6 + 4}`, map[object.HashKey]int{
+			(&object.Integer{Value: 2}).HashKey():  4,
+			(&object.String{Value: "a"}).HashKey(): 10,
+		}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestIndexExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"[1, 2, 3][1]", 2},
+		{"[1, 2, 3][0 + 2]", 3},
+		{"[[1, 1, 1]][0][0]", 1},
+		{"{1: 1, 2: 2}[1]", 1},
+		{"{1: 1, 2: 2}[2]", 2},
+		{"{1: 1}[0]", Null},
+		{"{}[0]", Null},
+		{`{true: "yes"}[1 < 2]`, "yes"},
+		{`"héllo"[1]`, "é"},
+		{"[1, 2, 3, 4][1:3]", []int{2, 3}},
+		{"[1, 2, 3][:]", []int{1, 2, 3}},
+		{"let xs = [1, 2, 3]; xs[1:][1:]", []int{3}},
+		{"[1, 2, 3][3:]", []int{}},
+		{`"héllo"[1:]`, "éllo"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBuiltinFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{`len("")`, 0},
+		{`len("héllo")`, 5},
+		{"len([1, 2, 3])", 3},
+		{`len({"a": 1})`, 1},
+		{"first([1, 2, 3])", 1},
+		{"first([])", Null},
+		{"last([1, 2, 3])", 3},
+		{"rest([1, 2, 3])", []int{2, 3}},
+		{"rest([])", []int{}},
+		{"push([], 1)", []int{1}},
+		{"let a = [1]; push(a, 2); a", []int{1}},
+		{`type_of("s")`, "string"},
+		{`to_string({1: [2]})`, "{1: [2]}"},
+		{`parse_int("42") ?? 0`, 42},
+		{`parse_int("x") ?? 0`, 0},
+		{"assert(true)", Null},
+		{"puts()", Null},
+		{"let f = fn(g) { return g([5]); }; f(first)", 5},
+		{"let f = fn() { return len; }; f()([1, 2])", 2},
+		{"let len = fn(x) { return 7; }; len([])", 7},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 / 0", "vmtest:1:1: runtime error: division by zero"},
+		{"let f = fn(x) { return x; };\nf(1, 2)", "vmtest:2:1: runtime error: wrong number of arguments: want=1, got=2"},
+		{"let f = fn(x, y) { return x + y; };\nf(1, true)", "vmtest:1:27: runtime error: type mismatch: INTEGER + BOOLEAN"},
+		{"let f = fn(x) { return -x; }; f(true)", "vmtest:1:24: runtime error: unknown operator: -BOOLEAN"},
+		{"let f = fn(x) { return x(); }; f(1)", "vmtest:1:24: runtime error: not a function: INTEGER"},
+		{"let f = fn(x) { return f(x); }; f(1)", "vmtest:1:24: runtime error: stack overflow at depth 1024"},
+		{"let xs = [1, 2, 3];\nxs[3]", "vmtest:2:1: runtime error: index out of range: 3 (length 3)"},
+		{"[1][-1]", "vmtest:1:1: runtime error: index out of range: -1 (length 1)"},
+		{"[1, 2][1:3]", "vmtest:1:1: runtime error: slice bounds out of range: [1:3] (length 2)"},
+		{`{"a": 1}[[1]]`, "vmtest:1:1: runtime error: unusable as hash key: ARRAY"},
+		{"{fn() {}: 1}", "vmtest:1:1: runtime error: unusable as hash key: FUNCTION"},
+		{"1[0]", "vmtest:1:1: runtime error: index operator not supported: INTEGER"},
+		{"let xs = [];\nassert(len(xs) > 0, \"empty\")", "vmtest:2:1: runtime error: assertion failed: empty"},
+		{"len(1)", "vmtest:1:1: runtime error: argument to len not supported, got INTEGER"},
+		{"first([], [])", "vmtest:1:1: runtime error: wrong number of arguments to first: want=1, got=2"},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		err := New(comp.Bytecode()).Run()
+		if err == nil {
+			t.Errorf("%q: expected error %q", tt.input, tt.expected)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("%q: expected=%q. got=%q", tt.input, tt.expected, err.Error())
+		}
+	}
+}
+
+func TestStackTraces(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"1 / 0", nil},
+		{`let inner = fn(x) { return 1 / x; };
+let outer = fn() { return inner(0); };
+outer();`, []string{
+			"at inner (vmtest:1:28)",
+			"at outer (vmtest:2:27)",
+			"at main (vmtest:3:1)",
+		}},
+		{"let f = fn() { return fn() { assert(false); }; };\nf()()", []string{
+			"at <anonymous> (vmtest:1:30)",
+			"at main (vmtest:2:1)",
+		}},
+	}
+
+	for _, tt := range tests {
+		comp := compiler.New()
+		if err := comp.Compile(parse(tt.input)); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		err, ok := New(comp.Bytecode()).Run().(*RuntimeError)
+		if !ok {
+			t.Fatalf("%q: expected a runtime error. got=%v", tt.input, err)
+		}
+
+		got := []string{}
+		for _, f := range err.Trace {
+			got = append(got, f.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+			t.Errorf("%q: wrong trace.\nexpected=%q\ngot=     %q", tt.input, tt.expected, got)
+		}
+
+		evaluated, ok := evaluator.Eval(parse(tt.input), object.NewEnvironment()).(*object.Error)
+		if !ok || object.FormatStackTrace(evaluated.Trace) != object.FormatStackTrace(err.Trace) {
+			t.Errorf("%q: evaluator trace differs:\n%s", tt.input, object.FormatStackTrace(evaluated.Trace))
+		}
+	}
+}
+
+func TestStackOverflowTrace(t *testing.T) {
+	input := "let isEven = fn(n) { if n == 0 { return true; } return isOdd(n - 1); };\n" +
+		"let isOdd = fn(n) { if n == 0 { return false; } return isEven(n - 1); };\n" +
+		"isEven(-1)"
+
+	comp := compiler.New()
+	if err := comp.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	err, ok := New(comp.Bytecode()).Run().(*RuntimeError)
+	if !ok {
+		t.Fatalf("expected a runtime error. got=%v", err)
+	}
+
+	if err.Message != "stack overflow at depth 1024" {
+		t.Errorf("err.Message: expected=%q. got=%q", "stack overflow at depth 1024", err.Message)
+	}
+	if len(err.Trace) != MaxFrames {
+		t.Errorf("len(err.Trace): expected=%d. got=%d", MaxFrames, len(err.Trace))
+	}
+
+	expected := `    at isEven (vmtest:1:56)
+    at isOdd (vmtest:2:56)
+    at isEven (vmtest:1:56)
+    at isOdd (vmtest:2:56)
+    at isEven (vmtest:1:56)
+    at isOdd (vmtest:2:56)
+    at isEven (vmtest:1:56)
+    at isOdd (vmtest:2:56)
+    at isEven (vmtest:1:56)
+    at isOdd (vmtest:2:56)
+    ... 1004 more frames
+    at isEven (vmtest:1:56)
+    at isOdd (vmtest:2:56)
+    at isEven (vmtest:1:56)
+    at isOdd (vmtest:2:56)
+    at isEven (vmtest:1:56)
+    at isOdd (vmtest:2:56)
+    at isEven (vmtest:1:56)
+    at isOdd (vmtest:2:56)
+    at isEven (vmtest:1:56)
+    at main (vmtest:3:1)
+`
+	if got := object.FormatStackTrace(err.Trace); got != expected {
+		t.Errorf("wrong trace.\nexpected:\n%s\ngot:\n%s", expected, got)
+	}
+}
+
+// TestAgreesWithEvaluator runs programs on both engines and compares what
+// they produce.
+func TestAgreesWithEvaluator(t *testing.T) {
+	inputs := []string{
+		"1 + 2 * 3 - 4 / 2",
+		"let x = 5; x ** 2 - 3",
+		"2.5 * 4.0",
+		"let s = \"a\"; s + \"b\" + \"${1 + 1}\"",
+		"if 1 > 2 { 1 }",
+		"if 1 < 2 { 1 } else { 2 }",
+		"null ?? \"default\"",
+		"let x = 1; x = 2; x",
+		"let x = 1; x++; x",
+		"let x = 1; x--",
+		"let f = 1.5; f++; f",
+		"let s = \"a\"; s++",
+		"let i = 0; let next = fn() { i++; return i; }; next(); next()",
+		"let f = fn(a) { if a > 0 { return a; } }; f(-1)",
+		"let f = fn(a) { return fn(b) { return a + b; }; }; f(1)(2)",
+		"let fact = fn(n) { if n < 2 { return 1; } return n * fact(n - 1); }; fact(10)",
+		"let c = 0; let inc = fn() { c = c + 1; return c; }; inc(); inc()",
+		"let x = 1;",
+		"1 / 0",
+		"1 + true",
+		"let f = fn(x) { return x; }; f()",
+		"\"a\" - \"b\"",
+		"2 ** -1",
+		"1 << -1",
+		`[1, "two", [3.0, null]]`,
+		`{"a": [1], 2: {true: 3}}["a"][0]`,
+		`{"a": 1}["b"]`,
+		"[1, 2, 3][1:][0]",
+		`"chimp"[1:3]`,
+		"[1, 2, 3][5]",
+		"[1, 2, 3][2:1]",
+		`[1]["a"]`,
+		`{[]: 1}`,
+		"1[0:1]",
+		"len([1, 2]) + len(\"ab\")",
+		"push(rest([1, 2, 3]), last([4]))",
+		"type_of(1.5) + to_string(null)",
+		"assert(false)",
+		"first(\"a\")",
+		"let f = fn(x) { return f(x + 1); }; f(0)",
+		"let a = fn(n) { return b(n); }; let b = fn(n) { if n > 0 { return a(n - 1); } return n; }; a(5)",
+		"let apply = fn(f, x) { return f(x); }; apply(fn(x) { return x * 2; }, 21)",
+		"let compose = fn(f, g) { return fn(x) { return f(g(x)); }; }; compose(len, rest)([1, 2, 3])",
+		"let addTwo = fn(int x) { return x + 2; }; addTwo",
+		"fn(x) { return x; }",
+		"let f = fn() {}; to_string(f) + to_string(fn() {}) + to_string(len)",
+		"let f = fn() {}; [f, len]",
+	}
+
+	for _, input := range inputs {
+		program := parse(input)
+
+		expected := evaluator.Eval(program, object.NewEnvironment())
+
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+		vm := New(comp.Bytecode())
+
+		var got string
+		if err := vm.Run(); err != nil {
+			got = err.Error()
+		} else {
+			got = describe(vm.Result())
+		}
+
+		if describe(expected) != got {
+			t.Errorf("%q: evaluator=%s, vm=%s", input, describe(expected), got)
+		}
+	}
+}
+
+func describe(obj object.Object) string {
+	if obj == nil {
+		return "<nil>"
+	}
+	if err, ok := obj.(*object.Error); ok {
+		return err.Inspect()
+	}
+	return fmt.Sprintf("%s(%s)", obj.Type(), obj.Inspect())
+}